@@ -25,6 +25,86 @@ type Image struct {
 	ImageID string   `json:"image_id"`
 	Names   []string `json:"names,omitempty"`
 	Digests []string `json:"digests,omitempty"`
+	// Size is the size of the image on the node, in bytes.
+	Size int64 `json:"size,omitempty"`
+}
+
+// NodeResult is the outcome reported by the eraser container on a single node.
+type NodeResult struct {
+	// name of the node the eraser ran on
+	Node string `json:"node"`
+
+	// whether images were left in place because the job was a dry run
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// images that were removed, or would have been removed in a dry run
+	Removed []string `json:"removed,omitempty"`
+
+	// number of images that were removed, or would have been removed in a dry run, even if Removed was truncated
+	RemovedCount int64 `json:"removedCount,omitempty"`
+
+	// total size in bytes of the images that were removed, or would have been removed in a dry run
+	RemovedBytes int64 `json:"removedBytes,omitempty"`
+
+	// images that were kept because a container is using them
+	Running []string `json:"running,omitempty"`
+
+	// images that were kept because they matched an exclusion list
+	Excluded []string `json:"excluded,omitempty"`
+
+	// number of images protected by each ImageExclusion, by name
+	Exclusions map[string]int64 `json:"exclusions,omitempty"`
+
+	// images that were kept because they were used or created too recently for the prune policy
+	Retained []string `json:"retained,omitempty"`
+
+	// images from the list that were not found on the node
+	NotPresent []string `json:"notPresent,omitempty"`
+
+	// non-compliant images that were kept until their quarantine ends
+	Quarantined []string `json:"quarantined,omitempty"`
+
+	// images that could not be removed
+	Errors []ImageError `json:"errors,omitempty"`
+
+	// outcome reported by each scanner that ran on the node
+	Scans []ScanResult `json:"scans,omitempty"`
+
+	// set when the eraser failed before it could process the image list
+	Error string `json:"error,omitempty"`
+
+	// set when the result was too large to report in full
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ImageError records an image that the eraser failed to remove.
+type ImageError struct {
+	// image as given in the image list, or its name when pruning
+	Image string `json:"image"`
+
+	// why the image could not be removed
+	Reason string `json:"reason"`
+}
+
+// ScanResult is the outcome reported by a scanner on a single node.
+type ScanResult struct {
+	// name of the scanner
+	Scanner string `json:"scanner"`
+
+	// number of images given to the scanner
+	Scanned int64 `json:"scanned"`
+
+	// number of images the scanner found non-compliant
+	NonCompliant int64 `json:"nonCompliant"`
+
+	// number of images the scanner failed to scan
+	Failed int64 `json:"failed"`
+
+	// time the scan took
+	Duration metav1.Duration `json:"duration"`
+
+	// why the scan did not complete, if the scanner aborted or failed
+	Error string `json:"error,omitempty"`
 }
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -39,6 +119,20 @@ const (
 	PhaseFailed    JobPhase = "Failed"
 )
 
+// Condition types of ImageJobs and ImageLists.
+const (
+	// ConditionScheduled is true once the pods of the job have been started.
+	ConditionScheduled = "Scheduled"
+	// ConditionRunning is true while pods of the job are running.
+	ConditionRunning = "Running"
+	// ConditionSucceeded is true if enough pods succeeded for the success ratio.
+	ConditionSucceeded = "Succeeded"
+	// ConditionDegraded is true if pods failed, or nodes were left out.
+	ConditionDegraded = "Degraded"
+	// ConditionFailed is true if too few pods succeeded for the success ratio.
+	ConditionFailed = "Failed"
+)
+
 // ImageJobStatus defines the observed state of ImageJob.
 type ImageJobStatus struct {
 	// number of pods that failed
@@ -58,6 +152,21 @@ type ImageJobStatus struct {
 
 	// Time to delay deletion until
 	DeleteAfter *metav1.Time `json:"deleteAfter,omitempty"`
+
+	// results reported by the eraser on each node
+	Nodes []NodeResult `json:"nodes,omitempty"`
+
+	// nodes selected when the job started, the only nodes its pods are rolled out to
+	TargetNodes []string `json:"targetNodes,omitempty"`
+
+	// nodes the pods of the job do not fit on, which are not retried
+	UnfitNodes []string `json:"unfitNodes,omitempty"`
+
+	// latest observations of the state of the job
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // ImageJob is the Schema for the imagejobs API.
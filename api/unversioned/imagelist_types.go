@@ -22,6 +22,20 @@ import (
 type ImageListSpec struct {
 	// The list of non-compliant images to delete if non-running.
 	Images []string `json:"images"`
+	// If true, report which images would be removed without removing them.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Limits which non-running images are removed by "*".
+	Prune *PrunePolicy `json:"prune,omitempty"`
+}
+
+// PrunePolicy limits which images are removed when pruning with "*". An image
+// is only removed if it meets every condition that is set.
+type PrunePolicy struct {
+	// Only remove images that no container has used for at least this long.
+	// Images without any container on the node count from when they were created.
+	UnusedFor *metav1.Duration `json:"unusedFor,omitempty"`
+	// Only remove images that were created at least this long ago.
+	OlderThan *metav1.Duration `json:"olderThan,omitempty"`
 }
 
 // ImageListStatus defines the observed state of ImageList.
@@ -34,6 +48,13 @@ type ImageListStatus struct {
 	Failed int64 `json:"failed"`
 	// Number of nodes that were skipped due to a skip selector
 	Skipped int64 `json:"skipped"`
+	// Results reported by the eraser on each node
+	Nodes []NodeResult `json:"nodes,omitempty"`
+	// Latest observations of the state of the last job of the list
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // ImageList is the Schema for the imagelists API.
@@ -21,7 +21,9 @@ limitations under the License.
 
 package unversioned
 
-import ()
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Image) DeepCopyInto(out *Image) {
@@ -48,6 +50,21 @@ func (in *Image) DeepCopy() *Image {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageError) DeepCopyInto(out *ImageError) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageError.
+func (in *ImageError) DeepCopy() *ImageError {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageError)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageJob) DeepCopyInto(out *ImageJob) {
 	*out = *in
@@ -97,6 +114,30 @@ func (in *ImageJobStatus) DeepCopyInto(out *ImageJobStatus) {
 		in, out := &in.DeleteAfter, &out.DeleteAfter
 		*out = (*in).DeepCopy()
 	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TargetNodes != nil {
+		in, out := &in.TargetNodes, &out.TargetNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UnfitNodes != nil {
+		in, out := &in.UnfitNodes, &out.UnfitNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageJobStatus.
@@ -160,6 +201,11 @@ func (in *ImageListSpec) DeepCopyInto(out *ImageListSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Prune != nil {
+		in, out := &in.Prune, &out.Prune
+		*out = new(PrunePolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageListSpec.
@@ -179,6 +225,20 @@ func (in *ImageListStatus) DeepCopyInto(out *ImageListStatus) {
 		in, out := &in.Timestamp, &out.Timestamp
 		*out = (*in).DeepCopy()
 	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageListStatus.
@@ -190,3 +250,106 @@ func (in *ImageListStatus) DeepCopy() *ImageListStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeResult) DeepCopyInto(out *NodeResult) {
+	*out = *in
+	if in.Removed != nil {
+		in, out := &in.Removed, &out.Removed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Running != nil {
+		in, out := &in.Running, &out.Running
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Excluded != nil {
+		in, out := &in.Excluded, &out.Excluded
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclusions != nil {
+		in, out := &in.Exclusions, &out.Exclusions
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Retained != nil {
+		in, out := &in.Retained, &out.Retained
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NotPresent != nil {
+		in, out := &in.NotPresent, &out.NotPresent
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Quarantined != nil {
+		in, out := &in.Quarantined, &out.Quarantined
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]ImageError, len(*in))
+		copy(*out, *in)
+	}
+	if in.Scans != nil {
+		in, out := &in.Scans, &out.Scans
+		*out = make([]ScanResult, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeResult.
+func (in *NodeResult) DeepCopy() *NodeResult {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrunePolicy) DeepCopyInto(out *PrunePolicy) {
+	*out = *in
+	if in.UnusedFor != nil {
+		in, out := &in.UnusedFor, &out.UnusedFor
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.OlderThan != nil {
+		in, out := &in.OlderThan, &out.OlderThan
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrunePolicy.
+func (in *PrunePolicy) DeepCopy() *PrunePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PrunePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScanResult) DeepCopyInto(out *ScanResult) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScanResult.
+func (in *ScanResult) DeepCopy() *ScanResult {
+	if in == nil {
+		return nil
+	}
+	out := new(ScanResult)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ImageExclusionSpec defines the images that are protected from removal.
+type ImageExclusionSpec struct {
+	// Images to protect, by name, digest, or a repository prefix ending in "/*" or ":*".
+	// +kubebuilder:validation:MinItems=1
+	Images []ImagePattern `json:"images"`
+	// Limits the exclusion to nodes matching this selector. All nodes match if it is not set, or is invalid.
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	// The exclusion no longer applies after this time.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// ImagePattern is an image name, digest, or repository prefix.
+// +kubebuilder:validation:MinLength=1
+// +kubebuilder:validation:Pattern=`^[^\s]+$`
+type ImagePattern string
+
+// ImageExclusionStatus defines the observed state of ImageExclusion.
+type ImageExclusionStatus struct {
+	// Number of images the exclusion protected in the last run, summed over nodes.
+	Protected int64 `json:"protected"`
+	// Number of nodes the exclusion applied to in the last run.
+	Nodes int64 `json:"nodes"`
+	// When the last run that applied the exclusion completed.
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.spec.expiresAt`
+// +kubebuilder:printcolumn:name="Protected",type=integer,JSONPath=`.status.protected`
+// +kubebuilder:printcolumn:name="Last Run",type=date,JSONPath=`.status.lastRunTime`
+// ImageExclusion is the Schema for the imageexclusions API.
+type ImageExclusion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImageExclusionSpec   `json:"spec,omitempty"`
+	Status ImageExclusionStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// ImageExclusionList contains a list of ImageExclusion.
+type ImageExclusionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ImageExclusion `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ImageExclusion{}, &ImageExclusionList{})
+}
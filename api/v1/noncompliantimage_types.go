@@ -0,0 +1,72 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NonCompliantImageSpec holds the decisions of operators about a quarantined image.
+type NonCompliantImageSpec struct {
+	// Remove the image on the next run, before its quarantine ends.
+	Approved bool `json:"approved,omitempty"`
+}
+
+// NonCompliantImageStatus defines the observed state of NonCompliantImage.
+type NonCompliantImageStatus struct {
+	// ID of the image on the nodes, or its digest when the cluster is scanned as a whole.
+	Image string `json:"image"`
+	// Nodes that held the image back in the last run.
+	Nodes []string `json:"nodes,omitempty"`
+	// When a scanner first found the image non-compliant.
+	FirstFlagged metav1.Time `json:"firstFlagged"`
+	// When a scanner last found the image non-compliant.
+	LastFlagged metav1.Time `json:"lastFlagged"`
+	// When the quarantine ends and the image is removed.
+	RemoveAfter metav1.Time `json:"removeAfter"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope="Cluster"
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.status.image`
+// +kubebuilder:printcolumn:name="First Flagged",type=date,JSONPath=`.status.firstFlagged`
+// +kubebuilder:printcolumn:name="Remove After",type=string,JSONPath=`.status.removeAfter`
+// +kubebuilder:printcolumn:name="Approved",type=boolean,JSONPath=`.spec.approved`
+// NonCompliantImage is the Schema for the noncompliantimages API. It records
+// an image that a scanner found non-compliant while it is quarantined.
+type NonCompliantImage struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NonCompliantImageSpec   `json:"spec,omitempty"`
+	Status NonCompliantImageStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// NonCompliantImageList contains a list of NonCompliantImage.
+type NonCompliantImageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NonCompliantImage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NonCompliantImage{}, &NonCompliantImageList{})
+}
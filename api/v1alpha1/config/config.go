@@ -8,6 +8,7 @@ import (
 	v1alpha1 "github.com/Azure/eraser/api/v1alpha1"
 	"github.com/Azure/eraser/version"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 var defaultScannerConfig = `
@@ -90,6 +91,10 @@ func Default() *v1alpha1.EraserConfig {
 					DelayOnSuccess: noDelay,
 					DelayOnFailure: oneDay,
 				},
+				Rollout: v1alpha1.RolloutConfig{
+					MaxConcurrent:  intstr.FromString("100%"),
+					AbortOnFailure: true,
+				},
 			},
 			PullSecrets: []string{},
 			NodeFilter: v1alpha1.NodeFilterConfig{
@@ -98,6 +103,32 @@ func Default() *v1alpha1.EraserConfig {
 					"eraser.sh/cleanup.filter",
 				},
 			},
+			DiskPressure: v1alpha1.DiskPressureConfig{
+				Enabled:       false,
+				HighWatermark: 80,
+				LowWatermark:  70,
+				CheckInterval: noDelay,
+				Cooldown:      v1alpha1.Duration(time.Minute * 10),
+			},
+			Scan: v1alpha1.ScanConfig{
+				Mode: v1alpha1.ScanModeNode,
+				Cache: v1alpha1.ScanCacheConfig{
+					Enabled:  false,
+					HostPath: "/var/lib/eraser/scan-cache",
+				},
+				Verdicts: v1alpha1.VerdictConfig{
+					Combine:   v1alpha1.VerdictCombineAny,
+					Threshold: 1,
+				},
+				Quarantine: v1alpha1.QuarantineConfig{
+					Enabled:     false,
+					GracePeriod: v1alpha1.Duration(7 * 24 * time.Hour),
+				},
+				SharedDB: v1alpha1.SharedDBConfig{
+					Enabled:   false,
+					ClaimName: "eraser-trivy-db",
+				},
+			},
 		},
 		Components: v1alpha1.Components{
 			Collector: v1alpha1.OptionalContainerConfig{
@@ -118,22 +149,24 @@ func Default() *v1alpha1.EraserConfig {
 					Config: nil,
 				},
 			},
-			Scanner: v1alpha1.OptionalContainerConfig{
-				Enabled: false,
-				ContainerConfig: v1alpha1.ContainerConfig{
-					Image: v1alpha1.RepoTag{
-						Repo: repo("eraser-trivy-scanner"),
-						Tag:  version.BuildVersion,
-					},
-					Request: v1alpha1.ResourceRequirements{
-						Mem: resource.MustParse("500Mi"),
-						CPU: resource.MustParse("1000m"),
-					},
-					Limit: v1alpha1.ResourceRequirements{
-						Mem: resource.MustParse("2Gi"),
-						CPU: resource.MustParse("1500m"),
+			Scanner: v1alpha1.ScannerConfig{
+				OptionalContainerConfig: v1alpha1.OptionalContainerConfig{
+					Enabled: false,
+					ContainerConfig: v1alpha1.ContainerConfig{
+						Image: v1alpha1.RepoTag{
+							Repo: repo("eraser-trivy-scanner"),
+							Tag:  version.BuildVersion,
+						},
+						Request: v1alpha1.ResourceRequirements{
+							Mem: resource.MustParse("500Mi"),
+							CPU: resource.MustParse("1000m"),
+						},
+						Limit: v1alpha1.ResourceRequirements{
+							Mem: resource.MustParse("2Gi"),
+							CPU: resource.MustParse("1500m"),
+						},
+						Config: &defaultScannerConfig,
 					},
-					Config: &defaultScannerConfig,
 				},
 			},
 			Eraser: v1alpha1.ContainerConfig{
@@ -21,8 +21,10 @@ import (
 	"fmt"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
 )
 
@@ -37,6 +39,32 @@ const (
 	RuntimeCrio       Runtime = "crio"
 )
 
+const (
+	// ScannerPluginStdioV1 exchanges JSON with the scanner plugin process over
+	// its standard input and output.
+	ScannerPluginStdioV1 = "stdio/v1"
+)
+
+const (
+	// VerdictCombineAny removes an image that any scanner finds
+	// non-compliant.
+	VerdictCombineAny = "any"
+	// VerdictCombineAll removes an image that every scanner finds
+	// non-compliant.
+	VerdictCombineAll = "all"
+	// VerdictCombineWeighted removes an image once the weights of the
+	// scanners finding it non-compliant add up to the threshold.
+	VerdictCombineWeighted = "weighted"
+)
+
+const (
+	// ScanModeNode scans the images of each node with a scanner on the node.
+	ScanModeNode = "node"
+	// ScanModeCluster scans each image once for the whole cluster, from its
+	// registry.
+	ScanModeCluster = "cluster"
+)
+
 func (td *Duration) UnmarshalJSON(b []byte) error {
 	var str string
 	err := json.Unmarshal(b, &str)
@@ -78,6 +106,30 @@ type OptionalContainerConfig struct {
 	ContainerConfig `json:",inline"`
 }
 
+// ScannerConfig configures the scanner component. Env, Volumes and
+// VolumeMounts are added to the scanner container, whichever protocol it
+// implements.
+type ScannerConfig struct {
+	OptionalContainerConfig `json:",inline"`
+	// Name is the name of the scanner container. It is required for
+	// additional scanners.
+	Name string `json:"name,omitempty"`
+	// Weight counts towards the threshold of weighted verdicts. Defaults to 1.
+	Weight       *int                 `json:"weight,omitempty"`
+	Plugin       ScannerPluginConfig  `json:"plugin,omitempty"`
+	Env          []corev1.EnvVar      `json:"env,omitempty"`
+	Volumes      []corev1.Volume      `json:"volumes,omitempty"`
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+}
+
+// ScannerPluginConfig runs a scanner image that implements the scanner plugin
+// protocol instead of the ImageProvider template. Without a protocol, the
+// image is expected to use the template.
+type ScannerPluginConfig struct {
+	Protocol string   `json:"protocol,omitempty"`
+	Command  []string `json:"command,omitempty"`
+}
+
 type ContainerConfig struct {
 	Image   RepoTag              `json:"image,omitempty"`
 	Request ResourceRequirements `json:"request,omitempty"`
@@ -86,20 +138,92 @@ type ContainerConfig struct {
 }
 
 type ManagerConfig struct {
-	Runtime           Runtime          `json:"runtime,omitempty"`
-	OTLPEndpoint      string           `json:"otlpEndpoint,omitempty"`
-	LogLevel          string           `json:"logLevel,omitempty"`
-	Scheduling        ScheduleConfig   `json:"scheduling,omitempty"`
-	Profile           ProfileConfig    `json:"profile,omitempty"`
-	ImageJob          ImageJobConfig   `json:"imageJob,omitempty"`
-	PullSecrets       []string         `json:"pullSecrets,omitempty"`
-	NodeFilter        NodeFilterConfig `json:"nodeFilter,omitempty"`
-	PriorityClassName string           `json:"priorityClassName,omitempty"`
+	Runtime           Runtime            `json:"runtime,omitempty"`
+	OTLPEndpoint      string             `json:"otlpEndpoint,omitempty"`
+	LogLevel          string             `json:"logLevel,omitempty"`
+	Scheduling        ScheduleConfig     `json:"scheduling,omitempty"`
+	Profile           ProfileConfig      `json:"profile,omitempty"`
+	ImageJob          ImageJobConfig     `json:"imageJob,omitempty"`
+	PullSecrets       []string           `json:"pullSecrets,omitempty"`
+	NodeFilter        NodeFilterConfig   `json:"nodeFilter,omitempty"`
+	PriorityClassName string             `json:"priorityClassName,omitempty"`
+	DryRun            bool               `json:"dryRun,omitempty"`
+	DiskPressure      DiskPressureConfig `json:"diskPressure,omitempty"`
+	ImagePolicy       ImagePolicyConfig  `json:"imagePolicy,omitempty"`
+	// ProtectWorkloadImages keeps the images referenced by the pod templates
+	// of workloads, even when no pod of the workload is running.
+	ProtectWorkloadImages bool       `json:"protectWorkloadImages,omitempty"`
+	Scan                  ScanConfig `json:"scan,omitempty"`
+}
+
+type ScanConfig struct {
+	Mode     string          `json:"mode,omitempty"`
+	Cache    ScanCacheConfig `json:"cache,omitempty"`
+	Verdicts VerdictConfig   `json:"verdicts,omitempty"`
+	// Quarantine keeps non-compliant images for a grace period before
+	// they are removed.
+	Quarantine QuarantineConfig `json:"quarantine,omitempty"`
+	// SharedDB fetches the vulnerability DB of the scanner once per run,
+	// for every node to share.
+	SharedDB SharedDBConfig `json:"sharedDB,omitempty"`
+}
+
+// SharedDBConfig has a Job fetch the trivy DB into a PersistentVolumeClaim
+// before each run. The scanners copy the DB from the claim, mounted read-only,
+// instead of each downloading it. The claim must be ReadWriteMany for the
+// scanners of every node to mount it.
+type SharedDBConfig struct {
+	Enabled   bool   `json:"enabled,omitempty"`
+	ClaimName string `json:"claimName,omitempty"`
+}
+
+// QuarantineConfig delays the removal of the images found non-compliant by
+// the scanners. Each one is recorded as a NonCompliantImage and only removed
+// once GracePeriod has passed since it was first flagged, or once it is
+// approved.
+type QuarantineConfig struct {
+	Enabled     bool     `json:"enabled,omitempty"`
+	GracePeriod Duration `json:"gracePeriod,omitempty"`
+}
+
+// VerdictConfig decides which images are removed when several scanners run.
+type VerdictConfig struct {
+	Combine   string `json:"combine,omitempty"`
+	Threshold int    `json:"threshold,omitempty"`
+}
+
+// ScanCacheConfig keeps the scan results of each node in a host directory,
+// so that images are only scanned again when the vulnerability DB or the
+// scanner config changes.
+type ScanCacheConfig struct {
+	Enabled  bool   `json:"enabled,omitempty"`
+	HostPath string `json:"hostPath,omitempty"`
+}
+
+type ImagePolicyConfig struct {
+	Include string `json:"include,omitempty"`
+	Exclude string `json:"exclude,omitempty"`
+}
+
+type DiskPressureConfig struct {
+	Enabled       bool     `json:"enabled,omitempty"`
+	HighWatermark int      `json:"highWatermark,omitempty"`
+	LowWatermark  int      `json:"lowWatermark,omitempty"`
+	CheckInterval Duration `json:"checkInterval,omitempty"`
+	Cooldown      Duration `json:"cooldown,omitempty"`
 }
 
 type ScheduleConfig struct {
-	RepeatInterval   Duration `json:"repeatInterval,omitempty"`
-	BeginImmediately bool     `json:"beginImmediately,omitempty"`
+	RepeatInterval   Duration         `json:"repeatInterval,omitempty"`
+	BeginImmediately bool             `json:"beginImmediately,omitempty"`
+	Cron             string           `json:"cron,omitempty"`
+	TimeZone         string           `json:"timeZone,omitempty"`
+	Blackouts        []BlackoutWindow `json:"blackouts,omitempty"`
+}
+
+type BlackoutWindow struct {
+	Start    string   `json:"start,omitempty"`
+	Duration Duration `json:"duration,omitempty"`
 }
 
 type ProfileConfig struct {
@@ -110,6 +234,12 @@ type ProfileConfig struct {
 type ImageJobConfig struct {
 	SuccessRatio float64               `json:"successRatio,omitempty"`
 	Cleanup      ImageJobCleanupConfig `json:"cleanup,omitempty"`
+	Rollout      RolloutConfig         `json:"rollout,omitempty"`
+}
+
+type RolloutConfig struct {
+	MaxConcurrent  intstr.IntOrString `json:"maxConcurrent,omitempty"`
+	AbortOnFailure bool               `json:"abortOnFailure,omitempty"`
 }
 
 type ImageJobCleanupConfig struct {
@@ -134,8 +264,22 @@ type RepoTag struct {
 
 type Components struct {
 	Collector OptionalContainerConfig `json:"collector,omitempty"`
-	Scanner   OptionalContainerConfig `json:"scanner,omitempty"`
-	Eraser    ContainerConfig         `json:"eraser,omitempty"`
+	Scanner   ScannerConfig           `json:"scanner,omitempty"`
+	// AdditionalScanners run alongside Scanner, and their verdicts are
+	// combined according to the scan verdicts config.
+	AdditionalScanners []ScannerConfig `json:"additionalScanners,omitempty"`
+	Eraser             ContainerConfig `json:"eraser,omitempty"`
+}
+
+// ScannerNamed returns the additional scanner called name, or the main
+// scanner if there is none.
+func (c *Components) ScannerNamed(name string) *ScannerConfig {
+	for i := range c.AdditionalScanners {
+		if c.AdditionalScanners[i].Name == name {
+			return &c.AdditionalScanners[i]
+		}
+	}
+	return &c.Scanner
 }
 
 //+kubebuilder:object:root=true
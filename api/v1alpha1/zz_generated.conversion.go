@@ -46,6 +46,16 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddGeneratedConversionFunc((*ImageError)(nil), (*unversioned.ImageError)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_ImageError_To_unversioned_ImageError(a.(*ImageError), b.(*unversioned.ImageError), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*unversioned.ImageError)(nil), (*ImageError)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_unversioned_ImageError_To_v1alpha1_ImageError(a.(*unversioned.ImageError), b.(*ImageError), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddGeneratedConversionFunc((*ImageJob)(nil), (*unversioned.ImageJob)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha1_ImageJob_To_unversioned_ImageJob(a.(*ImageJob), b.(*unversioned.ImageJob), scope)
 	}); err != nil {
@@ -116,6 +126,36 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddGeneratedConversionFunc((*NodeResult)(nil), (*unversioned.NodeResult)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_NodeResult_To_unversioned_NodeResult(a.(*NodeResult), b.(*unversioned.NodeResult), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*unversioned.NodeResult)(nil), (*NodeResult)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_unversioned_NodeResult_To_v1alpha1_NodeResult(a.(*unversioned.NodeResult), b.(*NodeResult), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*PrunePolicy)(nil), (*unversioned.PrunePolicy)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_PrunePolicy_To_unversioned_PrunePolicy(a.(*PrunePolicy), b.(*unversioned.PrunePolicy), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*unversioned.PrunePolicy)(nil), (*PrunePolicy)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_unversioned_PrunePolicy_To_v1alpha1_PrunePolicy(a.(*unversioned.PrunePolicy), b.(*PrunePolicy), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*ScanResult)(nil), (*unversioned.ScanResult)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha1_ScanResult_To_unversioned_ScanResult(a.(*ScanResult), b.(*unversioned.ScanResult), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*unversioned.ScanResult)(nil), (*ScanResult)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_unversioned_ScanResult_To_v1alpha1_ScanResult(a.(*unversioned.ScanResult), b.(*ScanResult), scope)
+	}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -123,6 +163,7 @@ func autoConvert_v1alpha1_Image_To_unversioned_Image(in *Image, out *unversioned
 	out.ImageID = in.ImageID
 	out.Names = *(*[]string)(unsafe.Pointer(&in.Names))
 	out.Digests = *(*[]string)(unsafe.Pointer(&in.Digests))
+	out.Size = in.Size
 	return nil
 }
 
@@ -135,6 +176,7 @@ func autoConvert_unversioned_Image_To_v1alpha1_Image(in *unversioned.Image, out
 	out.ImageID = in.ImageID
 	out.Names = *(*[]string)(unsafe.Pointer(&in.Names))
 	out.Digests = *(*[]string)(unsafe.Pointer(&in.Digests))
+	out.Size = in.Size
 	return nil
 }
 
@@ -143,6 +185,28 @@ func Convert_unversioned_Image_To_v1alpha1_Image(in *unversioned.Image, out *Ima
 	return autoConvert_unversioned_Image_To_v1alpha1_Image(in, out, s)
 }
 
+func autoConvert_v1alpha1_ImageError_To_unversioned_ImageError(in *ImageError, out *unversioned.ImageError, s conversion.Scope) error {
+	out.Image = in.Image
+	out.Reason = in.Reason
+	return nil
+}
+
+// Convert_v1alpha1_ImageError_To_unversioned_ImageError is an autogenerated conversion function.
+func Convert_v1alpha1_ImageError_To_unversioned_ImageError(in *ImageError, out *unversioned.ImageError, s conversion.Scope) error {
+	return autoConvert_v1alpha1_ImageError_To_unversioned_ImageError(in, out, s)
+}
+
+func autoConvert_unversioned_ImageError_To_v1alpha1_ImageError(in *unversioned.ImageError, out *ImageError, s conversion.Scope) error {
+	out.Image = in.Image
+	out.Reason = in.Reason
+	return nil
+}
+
+// Convert_unversioned_ImageError_To_v1alpha1_ImageError is an autogenerated conversion function.
+func Convert_unversioned_ImageError_To_v1alpha1_ImageError(in *unversioned.ImageError, out *ImageError, s conversion.Scope) error {
+	return autoConvert_unversioned_ImageError_To_v1alpha1_ImageError(in, out, s)
+}
+
 func autoConvert_v1alpha1_ImageJob_To_unversioned_ImageJob(in *ImageJob, out *unversioned.ImageJob, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha1_ImageJobStatus_To_unversioned_ImageJobStatus(&in.Status, &out.Status, s); err != nil {
@@ -198,6 +262,10 @@ func autoConvert_v1alpha1_ImageJobStatus_To_unversioned_ImageJobStatus(in *Image
 	out.Skipped = in.Skipped
 	out.Phase = unversioned.JobPhase(in.Phase)
 	out.DeleteAfter = (*v1.Time)(unsafe.Pointer(in.DeleteAfter))
+	out.Nodes = *(*[]unversioned.NodeResult)(unsafe.Pointer(&in.Nodes))
+	out.TargetNodes = *(*[]string)(unsafe.Pointer(&in.TargetNodes))
+	out.UnfitNodes = *(*[]string)(unsafe.Pointer(&in.UnfitNodes))
+	out.Conditions = *(*[]v1.Condition)(unsafe.Pointer(&in.Conditions))
 	return nil
 }
 
@@ -213,6 +281,10 @@ func autoConvert_unversioned_ImageJobStatus_To_v1alpha1_ImageJobStatus(in *unver
 	out.Skipped = in.Skipped
 	out.Phase = JobPhase(in.Phase)
 	out.DeleteAfter = (*v1.Time)(unsafe.Pointer(in.DeleteAfter))
+	out.Nodes = *(*[]NodeResult)(unsafe.Pointer(&in.Nodes))
+	out.TargetNodes = *(*[]string)(unsafe.Pointer(&in.TargetNodes))
+	out.UnfitNodes = *(*[]string)(unsafe.Pointer(&in.UnfitNodes))
+	out.Conditions = *(*[]v1.Condition)(unsafe.Pointer(&in.Conditions))
 	return nil
 }
 
@@ -277,6 +349,8 @@ func Convert_unversioned_ImageListList_To_v1alpha1_ImageListList(in *unversioned
 
 func autoConvert_v1alpha1_ImageListSpec_To_unversioned_ImageListSpec(in *ImageListSpec, out *unversioned.ImageListSpec, s conversion.Scope) error {
 	out.Images = *(*[]string)(unsafe.Pointer(&in.Images))
+	out.DryRun = in.DryRun
+	out.Prune = (*unversioned.PrunePolicy)(unsafe.Pointer(in.Prune))
 	return nil
 }
 
@@ -287,6 +361,8 @@ func Convert_v1alpha1_ImageListSpec_To_unversioned_ImageListSpec(in *ImageListSp
 
 func autoConvert_unversioned_ImageListSpec_To_v1alpha1_ImageListSpec(in *unversioned.ImageListSpec, out *ImageListSpec, s conversion.Scope) error {
 	out.Images = *(*[]string)(unsafe.Pointer(&in.Images))
+	out.DryRun = in.DryRun
+	out.Prune = (*PrunePolicy)(unsafe.Pointer(in.Prune))
 	return nil
 }
 
@@ -300,6 +376,8 @@ func autoConvert_v1alpha1_ImageListStatus_To_unversioned_ImageListStatus(in *Ima
 	out.Success = in.Success
 	out.Failed = in.Failed
 	out.Skipped = in.Skipped
+	out.Nodes = *(*[]unversioned.NodeResult)(unsafe.Pointer(&in.Nodes))
+	out.Conditions = *(*[]v1.Condition)(unsafe.Pointer(&in.Conditions))
 	return nil
 }
 
@@ -313,6 +391,8 @@ func autoConvert_unversioned_ImageListStatus_To_v1alpha1_ImageListStatus(in *unv
 	out.Success = in.Success
 	out.Failed = in.Failed
 	out.Skipped = in.Skipped
+	out.Nodes = *(*[]NodeResult)(unsafe.Pointer(&in.Nodes))
+	out.Conditions = *(*[]v1.Condition)(unsafe.Pointer(&in.Conditions))
 	return nil
 }
 
@@ -320,3 +400,103 @@ func autoConvert_unversioned_ImageListStatus_To_v1alpha1_ImageListStatus(in *unv
 func Convert_unversioned_ImageListStatus_To_v1alpha1_ImageListStatus(in *unversioned.ImageListStatus, out *ImageListStatus, s conversion.Scope) error {
 	return autoConvert_unversioned_ImageListStatus_To_v1alpha1_ImageListStatus(in, out, s)
 }
+
+func autoConvert_v1alpha1_NodeResult_To_unversioned_NodeResult(in *NodeResult, out *unversioned.NodeResult, s conversion.Scope) error {
+	out.Node = in.Node
+	out.DryRun = in.DryRun
+	out.Removed = *(*[]string)(unsafe.Pointer(&in.Removed))
+	out.RemovedCount = in.RemovedCount
+	out.RemovedBytes = in.RemovedBytes
+	out.Running = *(*[]string)(unsafe.Pointer(&in.Running))
+	out.Excluded = *(*[]string)(unsafe.Pointer(&in.Excluded))
+	out.Exclusions = *(*map[string]int64)(unsafe.Pointer(&in.Exclusions))
+	out.Retained = *(*[]string)(unsafe.Pointer(&in.Retained))
+	out.NotPresent = *(*[]string)(unsafe.Pointer(&in.NotPresent))
+	out.Quarantined = *(*[]string)(unsafe.Pointer(&in.Quarantined))
+	out.Errors = *(*[]unversioned.ImageError)(unsafe.Pointer(&in.Errors))
+	out.Scans = *(*[]unversioned.ScanResult)(unsafe.Pointer(&in.Scans))
+	out.Error = in.Error
+	out.Truncated = in.Truncated
+	return nil
+}
+
+// Convert_v1alpha1_NodeResult_To_unversioned_NodeResult is an autogenerated conversion function.
+func Convert_v1alpha1_NodeResult_To_unversioned_NodeResult(in *NodeResult, out *unversioned.NodeResult, s conversion.Scope) error {
+	return autoConvert_v1alpha1_NodeResult_To_unversioned_NodeResult(in, out, s)
+}
+
+func autoConvert_unversioned_NodeResult_To_v1alpha1_NodeResult(in *unversioned.NodeResult, out *NodeResult, s conversion.Scope) error {
+	out.Node = in.Node
+	out.DryRun = in.DryRun
+	out.Removed = *(*[]string)(unsafe.Pointer(&in.Removed))
+	out.RemovedCount = in.RemovedCount
+	out.RemovedBytes = in.RemovedBytes
+	out.Running = *(*[]string)(unsafe.Pointer(&in.Running))
+	out.Excluded = *(*[]string)(unsafe.Pointer(&in.Excluded))
+	out.Exclusions = *(*map[string]int64)(unsafe.Pointer(&in.Exclusions))
+	out.Retained = *(*[]string)(unsafe.Pointer(&in.Retained))
+	out.NotPresent = *(*[]string)(unsafe.Pointer(&in.NotPresent))
+	out.Quarantined = *(*[]string)(unsafe.Pointer(&in.Quarantined))
+	out.Errors = *(*[]ImageError)(unsafe.Pointer(&in.Errors))
+	out.Scans = *(*[]ScanResult)(unsafe.Pointer(&in.Scans))
+	out.Error = in.Error
+	out.Truncated = in.Truncated
+	return nil
+}
+
+// Convert_unversioned_NodeResult_To_v1alpha1_NodeResult is an autogenerated conversion function.
+func Convert_unversioned_NodeResult_To_v1alpha1_NodeResult(in *unversioned.NodeResult, out *NodeResult, s conversion.Scope) error {
+	return autoConvert_unversioned_NodeResult_To_v1alpha1_NodeResult(in, out, s)
+}
+
+func autoConvert_v1alpha1_PrunePolicy_To_unversioned_PrunePolicy(in *PrunePolicy, out *unversioned.PrunePolicy, s conversion.Scope) error {
+	out.UnusedFor = (*v1.Duration)(unsafe.Pointer(in.UnusedFor))
+	out.OlderThan = (*v1.Duration)(unsafe.Pointer(in.OlderThan))
+	return nil
+}
+
+// Convert_v1alpha1_PrunePolicy_To_unversioned_PrunePolicy is an autogenerated conversion function.
+func Convert_v1alpha1_PrunePolicy_To_unversioned_PrunePolicy(in *PrunePolicy, out *unversioned.PrunePolicy, s conversion.Scope) error {
+	return autoConvert_v1alpha1_PrunePolicy_To_unversioned_PrunePolicy(in, out, s)
+}
+
+func autoConvert_unversioned_PrunePolicy_To_v1alpha1_PrunePolicy(in *unversioned.PrunePolicy, out *PrunePolicy, s conversion.Scope) error {
+	out.UnusedFor = (*v1.Duration)(unsafe.Pointer(in.UnusedFor))
+	out.OlderThan = (*v1.Duration)(unsafe.Pointer(in.OlderThan))
+	return nil
+}
+
+// Convert_unversioned_PrunePolicy_To_v1alpha1_PrunePolicy is an autogenerated conversion function.
+func Convert_unversioned_PrunePolicy_To_v1alpha1_PrunePolicy(in *unversioned.PrunePolicy, out *PrunePolicy, s conversion.Scope) error {
+	return autoConvert_unversioned_PrunePolicy_To_v1alpha1_PrunePolicy(in, out, s)
+}
+
+func autoConvert_v1alpha1_ScanResult_To_unversioned_ScanResult(in *ScanResult, out *unversioned.ScanResult, s conversion.Scope) error {
+	out.Scanner = in.Scanner
+	out.Scanned = in.Scanned
+	out.NonCompliant = in.NonCompliant
+	out.Failed = in.Failed
+	out.Duration = in.Duration
+	out.Error = in.Error
+	return nil
+}
+
+// Convert_v1alpha1_ScanResult_To_unversioned_ScanResult is an autogenerated conversion function.
+func Convert_v1alpha1_ScanResult_To_unversioned_ScanResult(in *ScanResult, out *unversioned.ScanResult, s conversion.Scope) error {
+	return autoConvert_v1alpha1_ScanResult_To_unversioned_ScanResult(in, out, s)
+}
+
+func autoConvert_unversioned_ScanResult_To_v1alpha1_ScanResult(in *unversioned.ScanResult, out *ScanResult, s conversion.Scope) error {
+	out.Scanner = in.Scanner
+	out.Scanned = in.Scanned
+	out.NonCompliant = in.NonCompliant
+	out.Failed = in.Failed
+	out.Duration = in.Duration
+	out.Error = in.Error
+	return nil
+}
+
+// Convert_unversioned_ScanResult_To_v1alpha1_ScanResult is an autogenerated conversion function.
+func Convert_unversioned_ScanResult_To_v1alpha1_ScanResult(in *unversioned.ScanResult, out *ScanResult, s conversion.Scope) error {
+	return autoConvert_unversioned_ScanResult_To_v1alpha1_ScanResult(in, out, s)
+}
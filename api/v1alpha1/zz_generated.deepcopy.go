@@ -22,14 +22,38 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlackoutWindow) DeepCopyInto(out *BlackoutWindow) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlackoutWindow.
+func (in *BlackoutWindow) DeepCopy() *BlackoutWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(BlackoutWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Components) DeepCopyInto(out *Components) {
 	*out = *in
 	in.Collector.DeepCopyInto(&out.Collector)
 	in.Scanner.DeepCopyInto(&out.Scanner)
+	if in.AdditionalScanners != nil {
+		in, out := &in.AdditionalScanners, &out.AdditionalScanners
+		*out = make([]ScannerConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.Eraser.DeepCopyInto(&out.Eraser)
 }
 
@@ -66,6 +90,21 @@ func (in *ContainerConfig) DeepCopy() *ContainerConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskPressureConfig) DeepCopyInto(out *DiskPressureConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskPressureConfig.
+func (in *DiskPressureConfig) DeepCopy() *DiskPressureConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskPressureConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EraserConfig) DeepCopyInto(out *EraserConfig) {
 	*out = *in
@@ -118,6 +157,21 @@ func (in *Image) DeepCopy() *Image {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageError) DeepCopyInto(out *ImageError) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageError.
+func (in *ImageError) DeepCopy() *ImageError {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageError)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageJob) DeepCopyInto(out *ImageJob) {
 	*out = *in
@@ -163,6 +217,7 @@ func (in *ImageJobCleanupConfig) DeepCopy() *ImageJobCleanupConfig {
 func (in *ImageJobConfig) DeepCopyInto(out *ImageJobConfig) {
 	*out = *in
 	out.Cleanup = in.Cleanup
+	out.Rollout = in.Rollout
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageJobConfig.
@@ -214,6 +269,30 @@ func (in *ImageJobStatus) DeepCopyInto(out *ImageJobStatus) {
 		in, out := &in.DeleteAfter, &out.DeleteAfter
 		*out = (*in).DeepCopy()
 	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TargetNodes != nil {
+		in, out := &in.TargetNodes, &out.TargetNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UnfitNodes != nil {
+		in, out := &in.UnfitNodes, &out.UnfitNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageJobStatus.
@@ -293,6 +372,11 @@ func (in *ImageListSpec) DeepCopyInto(out *ImageListSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Prune != nil {
+		in, out := &in.Prune, &out.Prune
+		*out = new(PrunePolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageListSpec.
@@ -312,6 +396,20 @@ func (in *ImageListStatus) DeepCopyInto(out *ImageListStatus) {
 		in, out := &in.Timestamp, &out.Timestamp
 		*out = (*in).DeepCopy()
 	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageListStatus.
@@ -324,10 +422,25 @@ func (in *ImageListStatus) DeepCopy() *ImageListStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImagePolicyConfig) DeepCopyInto(out *ImagePolicyConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagePolicyConfig.
+func (in *ImagePolicyConfig) DeepCopy() *ImagePolicyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImagePolicyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagerConfig) DeepCopyInto(out *ManagerConfig) {
 	*out = *in
-	out.Scheduling = in.Scheduling
+	in.Scheduling.DeepCopyInto(&out.Scheduling)
 	out.Profile = in.Profile
 	out.ImageJob = in.ImageJob
 	if in.PullSecrets != nil {
@@ -336,6 +449,9 @@ func (in *ManagerConfig) DeepCopyInto(out *ManagerConfig) {
 		copy(*out, *in)
 	}
 	in.NodeFilter.DeepCopyInto(&out.NodeFilter)
+	out.DiskPressure = in.DiskPressure
+	out.ImagePolicy = in.ImagePolicy
+	out.Scan = in.Scan
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagerConfig.
@@ -368,6 +484,68 @@ func (in *NodeFilterConfig) DeepCopy() *NodeFilterConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeResult) DeepCopyInto(out *NodeResult) {
+	*out = *in
+	if in.Removed != nil {
+		in, out := &in.Removed, &out.Removed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Running != nil {
+		in, out := &in.Running, &out.Running
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Excluded != nil {
+		in, out := &in.Excluded, &out.Excluded
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclusions != nil {
+		in, out := &in.Exclusions, &out.Exclusions
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Retained != nil {
+		in, out := &in.Retained, &out.Retained
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NotPresent != nil {
+		in, out := &in.NotPresent, &out.NotPresent
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Quarantined != nil {
+		in, out := &in.Quarantined, &out.Quarantined
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]ImageError, len(*in))
+		copy(*out, *in)
+	}
+	if in.Scans != nil {
+		in, out := &in.Scans, &out.Scans
+		*out = make([]ScanResult, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeResult.
+func (in *NodeResult) DeepCopy() *NodeResult {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OptionalContainerConfig) DeepCopyInto(out *OptionalContainerConfig) {
 	*out = *in
@@ -399,6 +577,47 @@ func (in *ProfileConfig) DeepCopy() *ProfileConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrunePolicy) DeepCopyInto(out *PrunePolicy) {
+	*out = *in
+	if in.UnusedFor != nil {
+		in, out := &in.UnusedFor, &out.UnusedFor
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.OlderThan != nil {
+		in, out := &in.OlderThan, &out.OlderThan
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrunePolicy.
+func (in *PrunePolicy) DeepCopy() *PrunePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PrunePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScanResult) DeepCopyInto(out *ScanResult) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScanResult.
+func (in *ScanResult) DeepCopy() *ScanResult {
+	if in == nil {
+		return nil
+	}
+	out := new(ScanResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RepoTag) DeepCopyInto(out *RepoTag) {
 	*out = *in
@@ -431,9 +650,144 @@ func (in *ResourceRequirements) DeepCopy() *ResourceRequirements {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuarantineConfig) DeepCopyInto(out *QuarantineConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuarantineConfig.
+func (in *QuarantineConfig) DeepCopy() *QuarantineConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(QuarantineConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutConfig) DeepCopyInto(out *RolloutConfig) {
+	*out = *in
+	out.MaxConcurrent = in.MaxConcurrent
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutConfig.
+func (in *RolloutConfig) DeepCopy() *RolloutConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScanCacheConfig) DeepCopyInto(out *ScanCacheConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScanCacheConfig.
+func (in *ScanCacheConfig) DeepCopy() *ScanCacheConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ScanCacheConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScanConfig) DeepCopyInto(out *ScanConfig) {
+	*out = *in
+	out.Cache = in.Cache
+	out.Verdicts = in.Verdicts
+	out.Quarantine = in.Quarantine
+	out.SharedDB = in.SharedDB
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScanConfig.
+func (in *ScanConfig) DeepCopy() *ScanConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ScanConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScannerConfig) DeepCopyInto(out *ScannerConfig) {
+	*out = *in
+	in.OptionalContainerConfig.DeepCopyInto(&out.OptionalContainerConfig)
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int)
+		**out = **in
+	}
+	in.Plugin.DeepCopyInto(&out.Plugin)
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]corev1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScannerConfig.
+func (in *ScannerConfig) DeepCopy() *ScannerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ScannerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScannerPluginConfig) DeepCopyInto(out *ScannerPluginConfig) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScannerPluginConfig.
+func (in *ScannerPluginConfig) DeepCopy() *ScannerPluginConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ScannerPluginConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScheduleConfig) DeepCopyInto(out *ScheduleConfig) {
 	*out = *in
+	if in.Blackouts != nil {
+		in, out := &in.Blackouts, &out.Blackouts
+		*out = make([]BlackoutWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleConfig.
@@ -445,3 +799,33 @@ func (in *ScheduleConfig) DeepCopy() *ScheduleConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedDBConfig) DeepCopyInto(out *SharedDBConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedDBConfig.
+func (in *SharedDBConfig) DeepCopy() *SharedDBConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedDBConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VerdictConfig) DeepCopyInto(out *VerdictConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerdictConfig.
+func (in *VerdictConfig) DeepCopy() *VerdictConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(VerdictConfig)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/Azure/eraser/api/v1alpha1/config"
 	"github.com/Azure/eraser/controllers/configmap"
+	"github.com/Azure/eraser/controllers/diskpressure"
 	"github.com/Azure/eraser/controllers/imagecollector"
 	"github.com/Azure/eraser/controllers/imagejob"
 	"github.com/Azure/eraser/controllers/imagelist"
@@ -23,6 +24,7 @@ var (
 		imagejob.Add,
 		imagecollector.Add,
 		configmap.Add,
+		diskpressure.Add,
 	}
 )
 
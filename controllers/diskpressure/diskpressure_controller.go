@@ -0,0 +1,379 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diskpressure
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	eraserv1 "github.com/Azure/eraser/api/v1"
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/api/v1alpha1/config"
+	"github.com/Azure/eraser/controllers/util"
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	ownerLabelValue = "diskpressure"
+
+	// periodicCheck is the request queued every check interval. It also starts
+	// a run on nodes that do not report disk pressure, but whose image
+	// filesystem is above the high watermark.
+	periodicCheck = "periodic-check"
+)
+
+var (
+	log        = logf.Log.WithName("controller").WithValues("process", "diskpressure-controller")
+	ownerLabel labels.Selector
+)
+
+func init() {
+	var err error
+
+	ownerLabelString := fmt.Sprintf("%s=%s", util.ImageJobOwnerLabelKey, ownerLabelValue)
+	ownerLabel, err = labels.Parse(ownerLabelString)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Reconciler starts eraser runs on nodes that are under disk pressure.
+type Reconciler struct {
+	client.Client
+	eraserConfig *config.Manager
+	stats        *util.NodeStats
+
+	mtx sync.Mutex
+	// time of the last run started on each node
+	lastRun map[string]time.Time
+}
+
+func Add(mgr manager.Manager, cfg *config.Manager) error {
+	c, err := cfg.Read()
+	if err != nil {
+		return err
+	}
+
+	dpCfg := c.Manager.DiskPressure
+	if !dpCfg.Enabled {
+		// don't add controller, but don't throw an error either
+		return nil
+	}
+
+	if dpCfg.HighWatermark <= 0 || dpCfg.HighWatermark > 100 || dpCfg.LowWatermark < 0 || dpCfg.LowWatermark >= dpCfg.HighWatermark {
+		return fmt.Errorf("invalid disk pressure watermarks: high %d, low %d: must satisfy 0 <= low < high <= 100", dpCfg.HighWatermark, dpCfg.LowWatermark)
+	}
+
+	stats, err := util.NewNodeStats(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	r := &Reconciler{
+		Client:       mgr.GetClient(),
+		eraserConfig: cfg,
+		stats:        stats,
+		lastRun:      make(map[string]time.Time),
+	}
+
+	return add(mgr, r, time.Duration(dpCfg.CheckInterval))
+}
+
+func add(mgr manager.Manager, r *Reconciler, checkInterval time.Duration) error {
+	log.Info("add diskpressure controller")
+	c, err := controller.New("diskpressure-controller", mgr, controller.Options{
+		Reconciler: r,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(
+		&source.Kind{Type: &corev1.Node{}},
+		&handler.EnqueueRequestForObject{}, predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				node, ok := e.Object.(*corev1.Node)
+				return ok && hasDiskPressure(node)
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				node, ok := e.ObjectNew.(*corev1.Node)
+				return ok && hasDiskPressure(node)
+			},
+			DeleteFunc:  util.NeverOnDelete,
+			GenericFunc: util.NeverOnGeneric,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(
+		&source.Kind{Type: &eraserv1.ImageJob{}},
+		&handler.EnqueueRequestForObject{}, predicate.Funcs{
+			CreateFunc:  util.NeverOnCreate,
+			DeleteFunc:  util.NeverOnDelete,
+			GenericFunc: util.NeverOnGeneric,
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				if job, ok := e.ObjectNew.(*eraserv1.ImageJob); ok && util.IsCompletedOrFailed(job.Status.Phase) {
+					return ownerLabel.Matches(labels.Set(job.ObjectMeta.Labels))
+				}
+
+				return false
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if checkInterval <= 0 {
+		return nil
+	}
+
+	ch := make(chan event.GenericEvent)
+	err = c.Watch(&source.Channel{
+		Source: ch,
+	}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			log.V(1).Info("Queueing periodic disk pressure check...")
+			ch <- event.GenericEvent{
+				Object: &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: periodicCheck,
+					},
+				},
+			}
+		}
+	}()
+
+	return nil
+}
+
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=nodes/proxy,verbs=get
+//+kubebuilder:rbac:groups=eraser.sh,resources=imagejobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=podtemplates,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile cleans up finished disk pressure ImageJobs and starts a new one on
+// the nodes that need it. Only one disk pressure ImageJob runs at a time.
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	eraserConfig, err := r.eraserConfig.Read()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	jobList := &eraserv1.ImageJobList{}
+	if err := r.List(ctx, jobList, client.MatchingLabelsSelector{Selector: ownerLabel}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var requeueAfter time.Duration
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		if !util.IsCompletedOrFailed(job.Status.Phase) {
+			// nodes that are still under pressure are picked up once the
+			// running job completes.
+			return ctrl.Result{}, nil
+		}
+
+		until, err := r.handleCompletedJob(ctx, job, eraserConfig.Manager.ImageJob.Cleanup)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		requeueAfter = minDuration(requeueAfter, until)
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	cooldown := time.Duration(eraserConfig.Manager.DiskPressure.Cooldown)
+	now := time.Now()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var targets []string
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !hasDiskPressure(node) && (req.Name != periodicCheck || !r.aboveHighWatermark(ctx, node.Name, eraserConfig.Manager.DiskPressure.HighWatermark)) {
+			continue
+		}
+
+		if remaining := r.lastRun[node.Name].Add(cooldown).Sub(now); remaining > 0 {
+			log.V(1).Info("node is cooling down", "node", node.Name, "remaining", remaining)
+			requeueAfter = minDuration(requeueAfter, remaining)
+			continue
+		}
+
+		targets = append(targets, node.Name)
+	}
+
+	if len(targets) == 0 {
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	blackout, err := util.BlackoutRemaining(eraserConfig.Manager.Scheduling, now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if blackout > 0 {
+		log.Info("Delaying disk pressure ImageJob during blackout window", "remaining", blackout, "nodes", targets)
+		return ctrl.Result{RequeueAfter: blackout}, nil
+	}
+
+	if err := r.createImageJob(ctx, &eraserConfig, targets); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	for _, name := range targets {
+		r.lastRun[name] = now
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// handleCompletedJob deletes job once its cleanup delay has passed, and
+// otherwise returns how long is left.
+func (r *Reconciler) handleCompletedJob(ctx context.Context, job *eraserv1.ImageJob, cleanupCfg eraserv1alpha1.ImageJobCleanupConfig) (time.Duration, error) {
+	if job.Status.DeleteAfter == nil {
+		delay := time.Duration(cleanupCfg.DelayOnSuccess)
+		if job.Status.Phase == eraserv1.PhaseFailed {
+			delay = time.Duration(cleanupCfg.DelayOnFailure)
+		}
+
+		job.Status.DeleteAfter = util.After(time.Now(), int64(delay.Seconds()))
+		if err := r.Status().Update(ctx, job); err != nil {
+			return 0, err
+		}
+	}
+
+	if until := time.Until(job.Status.DeleteAfter.Time); until > 0 {
+		return until, nil
+	}
+
+	log.Info("Deleting imagejob", "job", job.Name)
+	return 0, client.IgnoreNotFound(r.Delete(ctx, job))
+}
+
+func (r *Reconciler) createImageJob(ctx context.Context, eraserConfig *eraserv1alpha1.EraserConfig, nodes []string) error {
+	mgrCfg := eraserConfig.Manager
+	eraser, err := util.EraserContainer(eraserConfig, false,
+		util.HighWatermarkFlag+"="+strconv.Itoa(mgrCfg.DiskPressure.HighWatermark),
+		"--low-watermark="+strconv.Itoa(mgrCfg.DiskPressure.LowWatermark),
+	)
+	if err != nil {
+		return err
+	}
+
+	jobTemplate := corev1.PodTemplateSpec{Spec: util.ImageJobPodSpec(eraserConfig, eraser)}
+	if err := util.MountExclusions(ctx, r.Client, &jobTemplate.Spec); err != nil {
+		return err
+	}
+
+	job := &eraserv1.ImageJob{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "imagejob-",
+			Labels: map[string]string{
+				util.ImageJobOwnerLabelKey: ownerLabelValue,
+			},
+			Annotations: map[string]string{
+				util.ImageJobNodesAnnotationKey: strings.Join(nodes, ","),
+			},
+		},
+	}
+
+	if err := r.Create(ctx, job); err != nil {
+		return err
+	}
+
+	template := corev1.PodTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      job.GetName(),
+			Namespace: utils.GetNamespace(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(job, eraserv1alpha1.GroupVersion.WithKind("ImageJob")),
+			},
+		},
+		Template: jobTemplate,
+	}
+
+	if err := r.Create(ctx, &template); err != nil {
+		return err
+	}
+
+	log.Info("Successfully created disk pressure ImageJob", "job", job.Name, "nodes", nodes)
+	return nil
+}
+
+// aboveHighWatermark reports whether the image filesystem of node is used
+// beyond highWatermark percent. Nodes whose stats cannot be read are left
+// alone until the next check.
+func (r *Reconciler) aboveHighWatermark(ctx context.Context, node string, highWatermark int) bool {
+	fs, err := r.stats.ImageFs(ctx, node)
+	if err != nil {
+		log.Error(err, "unable to read image filesystem stats", "node", node)
+		return false
+	}
+
+	used := fs.UsedPercent()
+	log.V(1).Info("image filesystem usage", "node", node, "used", used, "highWatermark", highWatermark)
+	return used >= highWatermark
+}
+
+func hasDiskPressure(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeDiskPressure {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// minDuration returns the smaller of a and b, ignoring zero values.
+func minDuration(a, b time.Duration) time.Duration {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,457 @@
+package imagecollector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	eraserv1 "github.com/Azure/eraser/api/v1"
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/controllers/util"
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	// clusterScanLabelKey marks the collector ImageJobs of a cluster scan,
+	// which only collect the images of each node.
+	clusterScanLabelKey   = "eraser.sh/cluster-scan"
+	clusterScanLabelValue = "collect"
+
+	clusterScanImagesKey = "images.json"
+	clusterScanPath      = "/run/eraser.sh/cluster-scan"
+
+	// defaultScanTimeout is the total timeout of the trivy scanner when its
+	// config does not set one.
+	defaultScanTimeout = 23 * time.Hour
+	// scanSetupGrace is the time the scan Job gets on top of the total
+	// timeout of the scanner, which only starts once the vulnerability DB is
+	// ready, to fetch the DB and report the result.
+	scanSetupGrace = 15 * time.Minute
+)
+
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+
+// isClusterScan reports whether job only collects images for a cluster scan.
+func isClusterScan(job *eraserv1.ImageJob) bool {
+	return job.Labels[clusterScanLabelKey] == clusterScanLabelValue
+}
+
+func scanJobName(job *eraserv1.ImageJob) string {
+	return job.Name + "-scan"
+}
+
+// handleCollectedImageJob drives a cluster scan once its collector ImageJob
+// is done: the images reported by every node are scanned once per digest by
+// a single Job, then an ImageJob removes the non-compliant digests.
+func (r *Reconciler) handleCollectedImageJob(ctx context.Context, job *eraserv1.ImageJob) (ctrl.Result, error) {
+	if !util.IsCompletedOrFailed(job.Status.Phase) {
+		return ctrl.Result{}, nil
+	}
+
+	scanJob := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: utils.GetNamespace(), Name: scanJobName(job)}, scanJob)
+	if apierrors.IsNotFound(err) {
+		return r.startClusterScan(ctx, job)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !jobFinished(scanJob) {
+		return ctrl.Result{}, nil
+	}
+
+	// the removal waits for blackout windows like any other ImageJob
+	eraserConfig, err := r.eraserConfig.Read()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	blackout, err := util.BlackoutRemaining(eraserConfig.Manager.Scheduling, time.Now())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if blackout > 0 {
+		log.Info("Delaying removal of cluster scan results during blackout window", "job", job.Name, "remaining", blackout)
+		return ctrl.Result{RequeueAfter: blackout}, nil
+	}
+
+	var digests []string
+	scanned := false
+	result := &corev1.ConfigMap{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: utils.GetNamespace(), Name: utils.ScanResultName(job.Name)}, result)
+	switch {
+	case apierrors.IsNotFound(err):
+		log.Error(fmt.Errorf("no result for cluster scan %s", job.Name), "cluster scan failed, no images will be removed")
+	case err != nil:
+		return ctrl.Result{}, err
+	default:
+		if err := json.Unmarshal([]byte(result.Data[utils.ScanResultDataKey]), &digests); err != nil {
+			log.Error(err, "invalid cluster scan result, no images will be removed", "job", job.Name)
+			digests = nil
+		} else {
+			scanned = true
+		}
+	}
+
+	if quarantineCfg := eraserConfig.Manager.Scan.Quarantine; quarantineCfg.Enabled && scanned {
+		if digests, err = r.quarantineDigests(ctx, quarantineCfg, digests); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.finishClusterScan(ctx, job); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("cluster scan complete", "job", job.Name, "images", len(digests))
+	if len(digests) == 0 {
+		return r.scheduleNext(ctx)
+	}
+
+	return r.createEraseImageJob(ctx, digests)
+}
+
+// startClusterScan dedupes the images reported by the nodes and starts the Job
+// scanning them.
+func (r *Reconciler) startClusterScan(ctx context.Context, job *eraserv1.ImageJob) (ctrl.Result, error) {
+	reportList := &corev1.ConfigMapList{}
+	if err := r.List(ctx, reportList, client.InNamespace(utils.GetNamespace()), client.MatchingLabels{utils.ScanReportLabelKey: job.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var reports []utils.ScanReport
+	for i := range reportList.Items {
+		data, ok := reportList.Items[i].Data[utils.ScanReportDataKey]
+		if !ok {
+			continue
+		}
+
+		var report utils.ScanReport
+		if err := json.Unmarshal([]byte(data), &report); err != nil {
+			log.Error(err, "ignoring invalid scan report", "configmap", reportList.Items[i].Name)
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	images := utils.DedupeScanReports(reports)
+	log.Info("starting cluster scan", "job", job.Name, "nodes", len(reports), "images", len(images))
+
+	if len(images) == 0 {
+		if err := r.finishClusterScan(ctx, job); err != nil {
+			return ctrl.Result{}, err
+		}
+		return r.scheduleNext(ctx)
+	}
+
+	data, err := json.Marshal(images)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	owner := []metav1.OwnerReference{*metav1.NewControllerRef(job, eraserv1alpha1.GroupVersion.WithKind("ImageJob"))}
+
+	imagesConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            job.Name + "-scan-images",
+			Namespace:       utils.GetNamespace(),
+			OwnerReferences: owner,
+		},
+		Immutable: utils.BoolPtr(true),
+		Data:      map[string]string{clusterScanImagesKey: string(data)},
+	}
+	if err := r.Create(ctx, imagesConfigMap); err != nil && !apierrors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+
+	scanJob, err := r.clusterScanJob(job, imagesConfigMap.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	scanJob.OwnerReferences = owner
+
+	if err := r.Create(ctx, scanJob); err != nil {
+		log.Info("Could not create cluster scan Job")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Successfully created cluster scan Job", "job", scanJob.Name)
+	return ctrl.Result{}, nil
+}
+
+// scanTimeout returns the total timeout of the scanner configured by cfg.
+func scanTimeout(cfg *string) (time.Duration, error) {
+	var scannerCfg struct {
+		Timeout struct {
+			Total eraserv1alpha1.Duration `json:"total,omitempty"`
+		} `json:"timeout,omitempty"`
+	}
+	if cfg != nil {
+		if err := yaml.Unmarshal([]byte(*cfg), &scannerCfg); err != nil {
+			return 0, fmt.Errorf("invalid scanner config: %w", err)
+		}
+	}
+
+	if total := time.Duration(scannerCfg.Timeout.Total); total > 0 {
+		return total, nil
+	}
+	return defaultScanTimeout, nil
+}
+
+// clusterScanJob returns the Job running the scanner over the images in the
+// configmap imagesName, fetching them from their registries.
+func (r *Reconciler) clusterScanJob(job *eraserv1.ImageJob, imagesName string) (*batchv1.Job, error) {
+	eraserConfig, err := r.eraserConfig.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	mgrCfg := eraserConfig.Manager
+	scanCfg := eraserConfig.Components.Scanner
+
+	iCfg := scanCfg.Image
+	scannerImg := fmt.Sprintf("%s:%s", iCfg.Repo, iCfg.Tag)
+
+	profileConfig := mgrCfg.Profile
+	cfgDirname := "/config"
+	args := []string{
+		fmt.Sprintf("--config=%s", filepath.Join(cfgDirname, "controller_manager_config.yaml")),
+		"--enable-pprof=" + strconv.FormatBool(profileConfig.Enabled),
+		fmt.Sprintf("--pprof-port=%d", profileConfig.Port),
+		"--cluster-scan=" + filepath.Join(clusterScanPath, clusterScanImagesKey),
+		"--scan-report=" + job.Name,
+		"--pull-secrets=" + utils.PullSecretsDir,
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name: configVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: util.EraserConfigmapName}},
+			},
+		},
+		{
+			Name: imagesName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: imagesName}},
+			},
+		},
+	}
+	mounts := []corev1.VolumeMount{
+		{MountPath: cfgDirname, Name: configVolumeName},
+		{MountPath: clusterScanPath, Name: imagesName},
+	}
+
+	pullSecrets := []corev1.LocalObjectReference{}
+	for _, secret := range mgrCfg.PullSecrets {
+		pullSecrets = append(pullSecrets, corev1.LocalObjectReference{Name: secret})
+	}
+	secretVolumes, secretMounts := pullSecretVolumes(mgrCfg.PullSecrets)
+	volumes = append(volumes, secretVolumes...)
+	mounts = append(mounts, secretMounts...)
+
+	env := []corev1.EnvVar{
+		namespaceEnv,
+		{
+			Name:  "OTEL_EXPORTER_OTLP_ENDPOINT",
+			Value: mgrCfg.OTLPEndpoint,
+		},
+		{
+			Name:  "OTEL_SERVICE_NAME",
+			Value: "trivy-scanner",
+		},
+	}
+	if usesSharedDB(&eraserConfig) {
+		volume, mount := sharedDBVolume(mgrCfg.Scan.SharedDB, true)
+		volumes = append(volumes, volume)
+		mounts = append(mounts, mount)
+		args = append(args, "--shared-db-dir="+sharedDBPath)
+	}
+
+	env = append(env, scanCfg.Env...)
+	mounts = append(mounts, scanCfg.VolumeMounts...)
+	volumes = append(volumes, scanCfg.Volumes...)
+
+	timeout, err := scanTimeout(scanCfg.Config)
+	if err != nil {
+		return nil, err
+	}
+	// the Job is stopped if the scanner hangs past its own timeout, so that
+	// the next run is not blocked
+	deadline := int64((timeout + scanSetupGrace) / time.Second)
+
+	backoffLimit := int32(0)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      scanJobName(job),
+			Namespace: utils.GetNamespace(),
+			Labels: map[string]string{
+				util.ImageJobOwnerLabelKey: ownerLabelValue,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes:           volumes,
+					ImagePullSecrets:  pullSecrets,
+					RestartPolicy:     corev1.RestartPolicyNever,
+					PriorityClassName: mgrCfg.PriorityClassName,
+					Containers: []corev1.Container{
+						{
+							Name:            "trivy-scanner",
+							Image:           scannerImg,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Args:            args,
+							VolumeMounts:    mounts,
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									"memory": scanCfg.Request.Mem,
+									"cpu":    scanCfg.Request.CPU,
+								},
+								Limits: corev1.ResourceList{
+									"memory": scanCfg.Limit.Mem,
+								},
+							},
+							Env: env,
+						},
+					},
+					ServiceAccountName: "eraser-imagejob-pods",
+				},
+			},
+		},
+	}, nil
+}
+
+// createEraseImageJob starts an ImageJob removing digests from every node.
+func (r *Reconciler) createEraseImageJob(ctx context.Context, digests []string) (ctrl.Result, error) {
+	eraserConfig, err := r.eraserConfig.Read()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	data, err := json.Marshal(digests)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	configMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "imagelist-",
+			Namespace:    utils.GetNamespace(),
+		},
+		Immutable: utils.BoolPtr(true),
+		Data:      map[string]string{"images": string(data)},
+	}
+	if err := r.Create(ctx, &configMap); err != nil {
+		return ctrl.Result{}, fmt.Errorf("create configmap: %w", err)
+	}
+
+	eraser, err := util.EraserContainer(&eraserConfig, false)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	jobTemplate := corev1.PodTemplateSpec{Spec: util.ImageJobPodSpec(&eraserConfig, eraser)}
+	util.MountImageList(&jobTemplate.Spec, &jobTemplate.Spec.Containers[0], configMap.Name)
+	if err := util.MountExclusions(ctx, r.Client, &jobTemplate.Spec); err != nil {
+		log.Info("Could not get exclusion mounts and volumes")
+		return ctrl.Result{}, err
+	}
+
+	job := &eraserv1alpha1.ImageJob{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "imagejob-",
+			Labels: map[string]string{
+				util.ImageJobOwnerLabelKey: ownerLabelValue,
+			},
+		},
+	}
+	if err := r.Create(ctx, job); err != nil {
+		log.Info("Could not create cluster scan ImageJob")
+		return ctrl.Result{}, err
+	}
+	startTime = time.Now()
+
+	owner := []metav1.OwnerReference{*metav1.NewControllerRef(job, eraserv1alpha1.GroupVersion.WithKind("ImageJob"))}
+	template := corev1.PodTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            job.GetName(),
+			Namespace:       utils.GetNamespace(),
+			OwnerReferences: owner,
+		},
+		Template: jobTemplate,
+	}
+	if err := r.Create(ctx, &template); err != nil {
+		log.Error(err, "Could not create cluster scan PodTemplate")
+		return ctrl.Result{}, err
+	}
+
+	configMap.OwnerReferences = owner
+	if err := r.Update(ctx, &configMap); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Successfully created cluster scan ImageJob", "job", job.Name, "images", len(digests))
+	return ctrl.Result{}, nil
+}
+
+// finishClusterScan deletes the collector ImageJob of a cluster scan, which
+// owns the scan Job, and the configmaps written by the collectors and the
+// scanner.
+func (r *Reconciler) finishClusterScan(ctx context.Context, job *eraserv1.ImageJob) error {
+	if err := r.deleteScanReports(ctx, client.MatchingLabels{utils.ScanReportLabelKey: job.Name}); err != nil {
+		return err
+	}
+
+	log.Info("Deleting imagejob", "job", job.Name)
+	return client.IgnoreNotFound(r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+}
+
+// deleteScanReports deletes the cluster scan configmaps matching opt.
+func (r *Reconciler) deleteScanReports(ctx context.Context, opt client.ListOption) error {
+	configMapList := &corev1.ConfigMapList{}
+	if err := r.List(ctx, configMapList, client.InNamespace(utils.GetNamespace()), opt); err != nil {
+		return err
+	}
+
+	for i := range configMapList.Items {
+		if err := r.Delete(ctx, &configMapList.Items[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// jobFinished reports whether a Job has completed or failed.
+func jobFinished(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceEnv lets the pods of a cluster scan write configmaps to the
+// namespace they run in.
+var namespaceEnv = corev1.EnvVar{
+	Name: "POD_NAMESPACE",
+	ValueFrom: &corev1.EnvVarSource{
+		FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+	},
+}
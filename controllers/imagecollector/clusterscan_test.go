@@ -0,0 +1,55 @@
+package imagecollector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScanTimeout(t *testing.T) {
+	config := func(s string) *string { return &s }
+
+	testCases := []struct {
+		name    string
+		config  *string
+		timeout time.Duration
+		err     bool
+	}{
+		{
+			name:    "no config",
+			timeout: defaultScanTimeout,
+		},
+		{
+			name:    "no timeout",
+			config:  config("cacheDir: /var/lib/trivy\n"),
+			timeout: defaultScanTimeout,
+		},
+		{
+			name:    "total",
+			config:  config("timeout:\n  total: 2h\n  perImage: 5m\n"),
+			timeout: 2 * time.Hour,
+		},
+		{
+			name:   "invalid",
+			config: config("timeout:\n  total: soon\n"),
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			timeout, err := scanTimeout(tc.config)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got timeout %s", timeout)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if timeout != tc.timeout {
+				t.Errorf("unexpected timeout: expected: %s, got: %s", tc.timeout, timeout)
+			}
+		})
+	}
+}
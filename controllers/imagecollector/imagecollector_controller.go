@@ -24,6 +24,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -47,17 +48,20 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	"github.com/Azure/eraser/pkg/logger"
 	"github.com/Azure/eraser/pkg/metrics"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const (
-	ownerLabelValue  = "imagecollector"
-	configVolumeName = "eraser-config"
+	ownerLabelValue     = "imagecollector"
+	configVolumeName    = "eraser-config"
+	scanCacheVolumeName = "scan-cache"
+	scanCachePath       = "/run/eraser.sh/scan-cache"
 )
 
 var (
@@ -161,6 +165,28 @@ func add(mgr manager.Manager, r *Reconciler) error {
 		return err
 	}
 
+	// the Jobs scanning the images of a cluster scan and fetching the shared DB
+	err = c.Watch(
+		&source.Kind{Type: &batchv1.Job{}},
+		&handler.EnqueueRequestForObject{}, predicate.Funcs{
+			CreateFunc:  util.NeverOnCreate,
+			DeleteFunc:  util.NeverOnDelete,
+			GenericFunc: util.NeverOnGeneric,
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldJob, okOld := e.ObjectOld.(*batchv1.Job)
+				job, ok := e.ObjectNew.(*batchv1.Job)
+				if okOld && ok && !jobFinished(oldJob) && jobFinished(job) {
+					return ownerLabel.Matches(labels.Set(job.ObjectMeta.Labels))
+				}
+
+				return false
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
 	ch := make(chan event.GenericEvent)
 	err = c.Watch(&source.Channel{
 		Source: ch,
@@ -174,16 +200,36 @@ func add(mgr manager.Manager, r *Reconciler) error {
 		return err
 	}
 
-	scheduleCfg := eraserConfig.Manager.Scheduling
-	delay := time.Duration(scheduleCfg.RepeatInterval)
-	if scheduleCfg.BeginImmediately {
-		delay = 0 * time.Second
+	// validate the schedule up front rather than on the first reconcile
+	if _, err := newScheduler(eraserConfig.Manager.Scheduling); err != nil {
+		return err
+	}
+
+	switch mode := eraserConfig.Manager.Scan.Mode; mode {
+	case "", eraserv1alpha1.ScanModeNode, eraserv1alpha1.ScanModeCluster:
+	default:
+		return fmt.Errorf("invalid scan mode %q, must be %q or %q", mode, eraserv1alpha1.ScanModeNode, eraserv1alpha1.ScanModeCluster)
+	}
+
+	if cacheCfg := eraserConfig.Manager.Scan.Cache; cacheCfg.Enabled && !filepath.IsAbs(cacheCfg.HostPath) {
+		return fmt.Errorf("invalid scan cache host path %q, must be absolute", cacheCfg.HostPath)
 	}
 
-	log.V(1).Info("delay", "delay", delay)
+	if quarantineCfg := eraserConfig.Manager.Scan.Quarantine; quarantineCfg.Enabled && quarantineCfg.GracePeriod < 0 {
+		return fmt.Errorf("invalid quarantine grace period %s, must not be negative", time.Duration(quarantineCfg.GracePeriod))
+	}
 
-	// runs the provided function after the specified delay
-	_ = time.AfterFunc(delay, func() {
+	if dbCfg := eraserConfig.Manager.Scan.SharedDB; dbCfg.Enabled {
+		if errs := validation.IsDNS1123Subdomain(dbCfg.ClaimName); len(errs) > 0 {
+			return fmt.Errorf("invalid shared DB claim name %q: %s", dbCfg.ClaimName, strings.Join(errs, ", "))
+		}
+	}
+
+	if err := validateScanners(&eraserConfig.Components, &eraserConfig.Manager.Scan); err != nil {
+		return err
+	}
+
+	go func() {
 		log.Info("Queueing first ImageCollector reconcile...")
 		ch <- event.GenericEvent{
 			Object: &eraserv1.ImageJob{
@@ -192,7 +238,7 @@ func add(mgr manager.Manager, r *Reconciler) error {
 				},
 			},
 		}
-	})
+	}()
 
 	return nil
 }
@@ -213,26 +259,38 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	defer log.Info("done reconcile")
 
 	imageJobList := &eraserv1.ImageJobList{}
-	if err := r.List(ctx, imageJobList); err != nil {
+	if err := r.List(ctx, imageJobList, client.MatchingLabelsSelector{Selector: ownerLabel}); err != nil {
 		log.Info("could not list imagejobs")
 		return ctrl.Result{}, err
 	}
 
 	if req.Name == "first-reconcile" {
+		interrupted := false
 		for idx := range imageJobList.Items {
+			if !util.IsCompletedOrFailed(imageJobList.Items[idx].Status.Phase) {
+				interrupted = true
+			}
 			if err := r.Delete(ctx, &imageJobList.Items[idx]); err != nil {
 				log.Info("error cleaning up previous imagejobs")
 				return ctrl.Result{}, err
 			}
 		}
-		return r.createImageJob(ctx)
+		if err := r.deleteScanReports(ctx, client.HasLabels{utils.ScanReportLabelKey}); err != nil {
+			log.Info("error cleaning up previous cluster scan reports")
+			return ctrl.Result{}, err
+		}
+		if interrupted {
+			log.Info("Restarting interrupted collector ImageJob")
+			return r.createImageJob(ctx)
+		}
+		return r.scheduleNext(ctx)
 	}
 
 	switch len(imageJobList.Items) {
 	case 0:
 		// If we reach this point, reconcile has been called on a timer, and we want to begin a
-		// collector ImageJob
-		return r.createImageJob(ctx)
+		// collector ImageJob if one is due
+		return r.scheduleNext(ctx)
 	case 1:
 		// an imagejob has just completed; proceed to imagelist creation.
 		return r.handleCompletedImageJob(ctx, &imageJobList.Items[0])
@@ -263,115 +321,96 @@ func (r *Reconciler) createImageJob(ctx context.Context) (ctrl.Result, error) {
 		return ctrl.Result{}, err
 	}
 
+	blackout, err := util.BlackoutRemaining(eraserConfig.Manager.Scheduling, time.Now())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if blackout > 0 {
+		log.Info("Delaying collector ImageJob during blackout window", "remaining", blackout)
+		return ctrl.Result{RequeueAfter: blackout}, nil
+	}
+
 	mgrCfg := eraserConfig.Manager
 	compCfg := eraserConfig.Components
 
 	scanCfg := compCfg.Scanner
 	collectorCfg := compCfg.Collector
-	eraserCfg := compCfg.Eraser
 
 	scanDisabled := !scanCfg.Enabled
-	startTime = time.Now()
-
-	eraserImg := *util.EraserImage
-	if eraserImg == "" {
-		iCfg := eraserCfg.Image
-		eraserImg = fmt.Sprintf("%s:%s", iCfg.Repo, iCfg.Tag)
+	scanners := enabledScanners(&compCfg)
+	clusterScan := !scanDisabled && mgrCfg.Scan.Mode == eraserv1alpha1.ScanModeCluster
+	// cluster scans quarantine the digests they find before removing any
+	quarantine := !scanDisabled && !clusterScan && mgrCfg.Scan.Quarantine.Enabled
+
+	// the scanners wait for the DB to be fetched for the whole cluster
+	if usesSharedDB(&eraserConfig) {
+		fetched, err := r.fetchDB(ctx, &eraserConfig)
+		if err != nil || !fetched {
+			return ctrl.Result{}, err
+		}
 	}
-
-	log.V(1).Info("eraserImg", "eraserImg", eraserImg)
+	startTime = time.Now()
 
 	iCfg := collectorCfg.Image
 	collectorImg := fmt.Sprintf("%s:%s", iCfg.Repo, iCfg.Tag)
 
-	profileConfig := eraserConfig.Manager.Profile
-	profileArgs := []string{
-		"--enable-pprof=" + strconv.FormatBool(profileConfig.Enabled),
-		fmt.Sprintf("--pprof-port=%d", profileConfig.Port),
+	profileArgs := util.ProfileArgs(&eraserConfig)
+	policyArgs, err := util.ImagePolicyArgs(mgrCfg.ImagePolicy)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
 	collArgs := []string{"--scan-disabled=" + strconv.FormatBool(scanDisabled)}
+	if !scanDisabled && !clusterScan {
+		collArgs = append(collArgs, verdictArgs(scanners, mgrCfg.Scan.Verdicts)...)
+	}
 	collArgs = append(collArgs, profileArgs...)
+	collArgs = append(collArgs, policyArgs...)
 
-	eraserArgs := []string{"--log-level=" + logger.GetLevel()}
-	eraserArgs = append(eraserArgs, profileArgs...)
-
-	pullSecrets := []corev1.LocalObjectReference{}
-	for _, secret := range eraserConfig.Manager.PullSecrets {
-		pullSecrets = append(pullSecrets, corev1.LocalObjectReference{Name: secret})
+	var eraserArgs []string
+	if quarantine {
+		eraserArgs = append(eraserArgs, "--quarantine")
+	}
+	eraser, err := util.EraserContainer(&eraserConfig, false, eraserArgs...)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
+	eraser.VolumeMounts = append(eraser.VolumeMounts, corev1.VolumeMount{MountPath: "/run/eraser.sh/shared-data", Name: "shared-data"})
 
-	jobTemplate := corev1.PodTemplateSpec{
-		Spec: corev1.PodSpec{
-			Volumes: []corev1.Volume{
-				{
-					// EmptyDir default
-					Name: "shared-data",
-				},
-				{
-					Name: configVolumeName,
-					VolumeSource: corev1.VolumeSource{
-						ConfigMap: &corev1.ConfigMapVolumeSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: util.EraserConfigmapName,
-							},
-						},
-					},
-				},
+	collector := corev1.Container{
+		Name:            "collector",
+		Image:           collectorImg,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Args:            collArgs,
+		VolumeMounts: []corev1.VolumeMount{
+			{MountPath: "/run/eraser.sh/shared-data", Name: "shared-data"},
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				"cpu":    collectorCfg.Request.CPU,
+				"memory": collectorCfg.Request.Mem,
 			},
-			ImagePullSecrets:  pullSecrets,
-			RestartPolicy:     corev1.RestartPolicyNever,
-			PriorityClassName: eraserConfig.Manager.PriorityClassName,
-			Containers: []corev1.Container{
-				{
-					Name:            "collector",
-					Image:           collectorImg,
-					ImagePullPolicy: corev1.PullIfNotPresent,
-					Args:            collArgs,
-					VolumeMounts: []corev1.VolumeMount{
-						{MountPath: "/run/eraser.sh/shared-data", Name: "shared-data"},
-					},
-					Resources: corev1.ResourceRequirements{
-						Requests: corev1.ResourceList{
-							"cpu":    collectorCfg.Request.CPU,
-							"memory": collectorCfg.Request.Mem,
-						},
-						Limits: corev1.ResourceList{
-							"memory": collectorCfg.Limit.Mem,
-						},
-					},
-				},
-				{
-					Name:            "eraser",
-					Image:           eraserImg,
-					ImagePullPolicy: corev1.PullIfNotPresent,
-					Args:            eraserArgs,
-					VolumeMounts: []corev1.VolumeMount{
-						{MountPath: "/run/eraser.sh/shared-data", Name: "shared-data"},
-					},
-					Resources: corev1.ResourceRequirements{
-						Requests: corev1.ResourceList{
-							"cpu":    eraserCfg.Request.CPU,
-							"memory": eraserCfg.Request.Mem,
-						},
-						Limits: corev1.ResourceList{
-							"memory": eraserCfg.Limit.Mem,
-						},
-					},
-					SecurityContext: utils.SharedSecurityContext,
-					Env: []corev1.EnvVar{
-						{
-							Name:  "OTEL_EXPORTER_OTLP_ENDPOINT",
-							Value: mgrCfg.OTLPEndpoint,
-						},
-						{
-							Name:  "OTEL_SERVICE_NAME",
-							Value: "eraser",
-						},
+			Limits: corev1.ResourceList{
+				"memory": collectorCfg.Limit.Mem,
+			},
+		},
+	}
+
+	jobTemplate := corev1.PodTemplateSpec{Spec: util.ImageJobPodSpec(&eraserConfig, collector, eraser)}
+	jobTemplate.Spec.Volumes = []corev1.Volume{
+		{
+			// EmptyDir default
+			Name: "shared-data",
+		},
+		{
+			Name: configVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: util.EraserConfigmapName,
 					},
 				},
 			},
-			ServiceAccountName: "eraser-imagejob-pods",
 		},
 	}
 
@@ -384,71 +423,47 @@ func (r *Reconciler) createImageJob(ctx context.Context) (ctrl.Result, error) {
 		},
 	}
 
-	if !scanDisabled {
-		iCfg := scanCfg.Image
-		scannerImg := fmt.Sprintf("%s:%s", iCfg.Repo, iCfg.Tag)
-
-		cfgDirname := "/config"
-		cfgFilename := filepath.Join(cfgDirname, "controller_manager_config.yaml")
-		scannerArgs := []string{fmt.Sprintf("--config=%s", cfgFilename)}
-		scannerArgs = append(scannerArgs, profileArgs...)
-
-		scannerContainer := corev1.Container{
-			Name:  "trivy-scanner",
-			Image: scannerImg,
-			Args:  scannerArgs,
-			VolumeMounts: []corev1.VolumeMount{
-				{MountPath: "/run/eraser.sh/shared-data", Name: "shared-data"},
-				{MountPath: cfgDirname, Name: configVolumeName},
-			},
-			Resources: corev1.ResourceRequirements{
-				Requests: corev1.ResourceList{
-					"memory": scanCfg.Request.Mem,
-					"cpu":    scanCfg.Request.CPU,
-				},
-				Limits: corev1.ResourceList{
-					"memory": scanCfg.Limit.Mem,
-				},
-			},
-			// env vars for exporting metrics
-			Env: []corev1.EnvVar{
-				{
-					Name:  "OTEL_EXPORTER_OTLP_ENDPOINT",
-					Value: mgrCfg.OTLPEndpoint,
-				},
-				{
-					Name:  "OTEL_SERVICE_NAME",
-					Value: "trivy-scanner",
-				},
-			},
+	if clusterScan {
+		// the nodes only report their images, which are scanned once for the
+		// whole cluster before anything is removed
+		collector := jobTemplate.Spec.Containers[0]
+		collector.Env = append(collector.Env, namespaceEnv)
+		jobTemplate.Spec.Containers = []corev1.Container{collector}
+		job.Labels[clusterScanLabelKey] = clusterScanLabelValue
+	} else if !scanDisabled {
+		for i, scanner := range scanners {
+			// only the main scanner keeps its results across runs
+			container := scannerContainer(&jobTemplate.Spec, scanner, &mgrCfg, profileArgs, collectorImg, i == 0)
+			jobTemplate.Spec.Containers = append(jobTemplate.Spec.Containers, container)
 		}
-		jobTemplate.Spec.Containers = append(jobTemplate.Spec.Containers, scannerContainer)
 	}
 
-	configmapList := &corev1.ConfigMapList{}
-	if err := r.List(ctx, configmapList, client.InNamespace(utils.GetNamespace())); err != nil {
-		log.Info("Could not get list of configmaps")
-		return reconcile.Result{}, err
+	if quarantine {
+		job.Labels[quarantineLabelKey] = quarantineLabelValue
 	}
 
-	exclusionMount, exclusionVolume, err := util.GetExclusionVolume(configmapList)
-	if err != nil {
+	if err := util.MountExclusions(ctx, r.Client, &jobTemplate.Spec); err != nil {
 		log.Info("Could not get exclusion mounts and volumes")
 		return reconcile.Result{}, err
 	}
 
-	for i := range jobTemplate.Spec.Containers {
-		jobTemplate.Spec.Containers[i].VolumeMounts = append(jobTemplate.Spec.Containers[i].VolumeMounts, exclusionMount...)
-	}
-
-	jobTemplate.Spec.Volumes = append(jobTemplate.Spec.Volumes, exclusionVolume...)
-
 	err = r.Create(ctx, job)
 	if err != nil {
 		log.Info("Could not create collector ImageJob")
 		return reconcile.Result{}, err
 	}
 
+	if clusterScan {
+		collector := &jobTemplate.Spec.Containers[0]
+		collector.Args = append(collector.Args, "--scan-report="+job.Name)
+	}
+
+	if quarantine {
+		if err := r.mountReleased(ctx, mgrCfg.Scan.Quarantine, job, &jobTemplate.Spec); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	namespace := utils.GetNamespace()
 	template := corev1.PodTemplate{
 		ObjectMeta: metav1.ObjectMeta{
@@ -467,24 +482,38 @@ func (r *Reconciler) createImageJob(ctx context.Context) (ctrl.Result, error) {
 	}
 
 	log.Info("Successfully created collector ImageJob", "job", job.Name)
+
+	if err := r.setLastRun(ctx, startTime); err != nil {
+		log.Error(err, "Could not persist collector last run time")
+	}
+
 	return reconcile.Result{}, nil
 }
 
 func (r *Reconciler) handleCompletedImageJob(ctx context.Context, childJob *eraserv1.ImageJob) (ctrl.Result, error) {
+	if isClusterScan(childJob) {
+		return r.handleCollectedImageJob(ctx, childJob)
+	}
+
 	var err error
-	var timeRemaining time.Duration
 	eraserConfig, err := r.eraserConfig.Read()
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
 	otlpEndpoint := eraserConfig.Manager.OTLPEndpoint
-	repeatInterval := time.Duration(eraserConfig.Manager.Scheduling.RepeatInterval)
 
 	cleanupCfg := eraserConfig.Manager.ImageJob.Cleanup
 	successDelay := time.Duration(cleanupCfg.DelayOnSuccess)
 	errDelay := time.Duration(cleanupCfg.DelayOnFailure)
 
+	// record the quarantined images once, when the job is first seen done
+	if childJob.Status.DeleteAfter == nil && isQuarantined(childJob) {
+		if err := r.recordQuarantined(ctx, eraserConfig.Manager.Scan.Quarantine, childJob); err != nil {
+			log.Error(err, "unable to record quarantined images", "job", childJob.Name)
+		}
+	}
+
 	switch phase := childJob.Status.Phase; phase {
 	case eraserv1.PhaseCompleted:
 		log.Info("completed phase")
@@ -504,7 +533,6 @@ func (r *Reconciler) handleCompletedImageJob(ctx context.Context, childJob *eras
 			metrics.ExportMetrics(log, exporter, reader)
 		}
 
-		timeRemaining = repeatInterval - successDelay
 		if res, err := r.handleJobDeletion(ctx, childJob); err != nil || res.RequeueAfter > 0 {
 			return res, err
 		}
@@ -526,7 +554,6 @@ func (r *Reconciler) handleCompletedImageJob(ctx context.Context, childJob *eras
 			metrics.ExportMetrics(log, exporter, reader)
 		}
 
-		timeRemaining = repeatInterval - errDelay
 		if res, err := r.handleJobDeletion(ctx, childJob); err != nil || res.RequeueAfter > 0 {
 			return res, err
 		}
@@ -535,9 +562,10 @@ func (r *Reconciler) handleCompletedImageJob(ctx context.Context, childJob *eras
 		log.Error(err, "imagejob not in completed or failed phase", "imagejob", childJob)
 	}
 
-	if timeRemaining <= 0 {
-		return ctrl.Result{Requeue: true}, err
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{RequeueAfter: timeRemaining}, err
+	// the job is gone; wait for the next scheduled run
+	return r.scheduleNext(ctx)
 }
@@ -0,0 +1,267 @@
+package imagecollector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	eraserv1 "github.com/Azure/eraser/api/v1"
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/controllers/util"
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	// quarantineLabelKey marks the collector ImageJobs whose eraser holds back
+	// non-compliant images, so that their results update the quarantine.
+	quarantineLabelKey   = "eraser.sh/quarantine"
+	quarantineLabelValue = "true"
+
+	releasedVolumeName = "released-images"
+)
+
+//+kubebuilder:rbac:groups=eraser.sh,resources=noncompliantimages,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=eraser.sh,resources=noncompliantimages/status,verbs=get;update;patch
+
+// isQuarantined reports whether the eraser of job quarantined images.
+func isQuarantined(job *eraserv1.ImageJob) bool {
+	return job.Labels[quarantineLabelKey] == quarantineLabelValue
+}
+
+// quarantineName returns the name of the NonCompliantImage recording image,
+// or false if no valid name can be derived from it. Images are recorded by
+// ID, digest, or, when the eraser prunes them, by reference.
+func quarantineName(image string) (string, bool) {
+	name := strings.NewReplacer(":", "-", "/", ".", "@", ".").Replace(strings.ToLower(image))
+	return name, len(validation.IsDNS1123Subdomain(name)) == 0
+}
+
+// released reports whether the quarantine of img is over at now.
+func released(img *eraserv1.NonCompliantImage, gracePeriod time.Duration, now time.Time) bool {
+	return img.Spec.Approved || !now.Before(img.Status.FirstFlagged.Add(gracePeriod))
+}
+
+// releasedImages returns the quarantined images that may be removed, because
+// they were approved or their grace period is over.
+func (r *Reconciler) releasedImages(ctx context.Context, cfg eraserv1alpha1.QuarantineConfig) ([]string, error) {
+	list := &eraserv1.NonCompliantImageList{}
+	if err := r.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var images []string
+	for i := range list.Items {
+		if list.Items[i].Status.Image != "" && released(&list.Items[i], time.Duration(cfg.GracePeriod), now) {
+			images = append(images, list.Items[i].Status.Image)
+		}
+	}
+	sort.Strings(images)
+
+	return images, nil
+}
+
+// mountReleased stores the released images in a configmap owned by job, and
+// mounts it in the eraser container of spec. A configmap holds far more
+// images than the environment of a container.
+func (r *Reconciler) mountReleased(ctx context.Context, cfg eraserv1alpha1.QuarantineConfig, job *eraserv1alpha1.ImageJob, spec *corev1.PodSpec) error {
+	var eraser *corev1.Container
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == util.EraserContainerName {
+			eraser = &spec.Containers[i]
+		}
+	}
+	if eraser == nil {
+		return fmt.Errorf("no %s container to release quarantined images to", util.EraserContainerName)
+	}
+
+	images, err := r.releasedImages(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if images == nil {
+		images = []string{}
+	}
+
+	data, err := json.Marshal(images)
+	if err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      job.Name + "-released",
+			Namespace: utils.GetNamespace(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(job, eraserv1alpha1.GroupVersion.WithKind("ImageJob")),
+			},
+		},
+		Immutable: utils.BoolPtr(true),
+		Data:      map[string]string{utils.ReleasedImagesFile: string(data)},
+	}
+	if err := r.Create(ctx, cm); err != nil {
+		return err
+	}
+
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: releasedVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name}},
+		},
+	})
+	eraser.VolumeMounts = append(eraser.VolumeMounts, corev1.VolumeMount{Name: releasedVolumeName, MountPath: utils.ReleasedImagesDir, ReadOnly: true})
+
+	log.Info("releasing quarantined images", "images", len(images))
+	return nil
+}
+
+// updateQuarantine records the images flagged in the last run, with the nodes
+// that held them back. When the run covered every image, the records of
+// images that are neither flagged nor pending are deleted: they were removed,
+// excluded, or are no longer found non-compliant.
+func (r *Reconciler) updateQuarantine(ctx context.Context, cfg eraserv1alpha1.QuarantineConfig, flagged map[string][]string, pending map[string]struct{}, complete bool) error {
+	list := &eraserv1.NonCompliantImageList{}
+	if err := r.List(ctx, list); err != nil {
+		return err
+	}
+
+	records := make(map[string]*eraserv1.NonCompliantImage, len(list.Items))
+	for i := range list.Items {
+		records[list.Items[i].Name] = &list.Items[i]
+	}
+
+	now := metav1.Now()
+	gracePeriod := time.Duration(cfg.GracePeriod)
+	seen := make(map[string]struct{}, len(flagged))
+
+	for image, nodes := range flagged {
+		name, ok := quarantineName(image)
+		if !ok {
+			log.Info("unable to record quarantined image, invalid name", "image", image)
+			continue
+		}
+		seen[name] = struct{}{}
+
+		record, exists := records[name]
+		if !exists {
+			record = &eraserv1.NonCompliantImage{ObjectMeta: metav1.ObjectMeta{Name: name}}
+			err := r.Create(ctx, record)
+			if apierrors.IsAlreadyExists(err) {
+				err = r.Get(ctx, types.NamespacedName{Name: name}, record)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if record.Status.FirstFlagged.IsZero() {
+			record.Status.FirstFlagged = now
+			log.Info("image quarantined", "image", image, "removeAfter", now.Add(gracePeriod))
+		}
+
+		sort.Strings(nodes)
+		record.Status.Image = image
+		record.Status.Nodes = nodes
+		record.Status.LastFlagged = now
+		record.Status.RemoveAfter = metav1.NewTime(record.Status.FirstFlagged.Add(gracePeriod))
+		if err := r.Status().Update(ctx, record); err != nil {
+			return err
+		}
+	}
+
+	if !complete {
+		return nil
+	}
+
+	for name, record := range records {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		if _, ok := pending[record.Status.Image]; ok {
+			continue
+		}
+
+		log.Info("image left quarantine", "image", record.Status.Image, "name", name)
+		if err := r.Delete(ctx, record); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordQuarantined updates the quarantine from the results of the erasers
+// of a completed collector ImageJob. Images a node could not remove yet stay
+// quarantined, so that their grace period is not restarted.
+func (r *Reconciler) recordQuarantined(ctx context.Context, cfg eraserv1alpha1.QuarantineConfig, job *eraserv1.ImageJob) error {
+	flagged := make(map[string][]string)
+	pending := make(map[string]struct{})
+	complete := job.Status.Phase == eraserv1.PhaseCompleted
+
+	for i := range job.Status.Nodes {
+		result := &job.Status.Nodes[i]
+		if result.Truncated || result.Error != "" {
+			complete = false
+		}
+
+		for _, image := range result.Quarantined {
+			flagged[image] = append(flagged[image], result.Node)
+		}
+
+		for _, image := range result.Running {
+			pending[image] = struct{}{}
+		}
+		for _, e := range result.Errors {
+			pending[e.Image] = struct{}{}
+		}
+		if result.DryRun {
+			for _, image := range result.Removed {
+				pending[image] = struct{}{}
+			}
+		}
+	}
+
+	return r.updateQuarantine(ctx, cfg, flagged, pending, complete)
+}
+
+// quarantineDigests records the non-compliant digests of a cluster scan and
+// returns those whose quarantine is over.
+func (r *Reconciler) quarantineDigests(ctx context.Context, cfg eraserv1alpha1.QuarantineConfig, digests []string) ([]string, error) {
+	flagged := make(map[string][]string, len(digests))
+	for _, digest := range digests {
+		flagged[digest] = nil
+	}
+
+	if err := r.updateQuarantine(ctx, cfg, flagged, nil, true); err != nil {
+		return nil, err
+	}
+
+	releasedDigests, err := r.releasedImages(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	isReleased := make(map[string]struct{}, len(releasedDigests))
+	for _, image := range releasedDigests {
+		isReleased[image] = struct{}{}
+	}
+
+	var remove []string
+	for _, digest := range digests {
+		if _, ok := isReleased[digest]; ok {
+			remove = append(remove, digest)
+		}
+	}
+
+	log.Info("quarantined non-compliant images", "images", len(digests)-len(remove), "released", len(remove))
+	return remove, nil
+}
@@ -0,0 +1,231 @@
+package imagecollector
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	eraserv1 "github.com/Azure/eraser/api/v1"
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+)
+
+func TestQuarantineName(t *testing.T) {
+	testCases := []struct {
+		name  string
+		image string
+		want  string
+		ok    bool
+	}{
+		{
+			name:  "digest",
+			image: "sha256:ABC123",
+			want:  "sha256-abc123",
+			ok:    true,
+		},
+		{
+			name:  "image ID",
+			image: "abc123",
+			want:  "abc123",
+			ok:    true,
+		},
+		{
+			name:  "reference",
+			image: "docker.io/library/alpine:3.17",
+			want:  "docker.io.library.alpine-3.17",
+			ok:    true,
+		},
+		{
+			name:  "digest reference",
+			image: "docker.io/library/alpine@sha256:ABC123",
+			want:  "docker.io.library.alpine.sha256-abc123",
+			ok:    true,
+		},
+		{
+			name:  "invalid",
+			image: "alpine_3",
+		},
+		{
+			name:  "empty",
+			image: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, ok := quarantineName(tc.image)
+			if ok != tc.ok {
+				t.Fatalf("unexpected validity of %q: expected: %t, got: %t", name, tc.ok, ok)
+			}
+			if ok && name != tc.want {
+				t.Errorf("unexpected name: expected: %s, got: %s", tc.want, name)
+			}
+		})
+	}
+}
+
+func TestReleased(t *testing.T) {
+	flagged := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	image := func(approved bool) *eraserv1.NonCompliantImage {
+		img := &eraserv1.NonCompliantImage{}
+		img.Spec.Approved = approved
+		img.Status.FirstFlagged = metav1.NewTime(flagged)
+		return img
+	}
+
+	testCases := []struct {
+		name        string
+		img         *eraserv1.NonCompliantImage
+		gracePeriod time.Duration
+		now         time.Time
+		released    bool
+	}{
+		{
+			name:        "within grace period",
+			img:         image(false),
+			gracePeriod: 24 * time.Hour,
+			now:         flagged.Add(time.Hour),
+		},
+		{
+			name:        "grace period over",
+			img:         image(false),
+			gracePeriod: 24 * time.Hour,
+			now:         flagged.Add(24 * time.Hour),
+			released:    true,
+		},
+		{
+			name:        "approved",
+			img:         image(true),
+			gracePeriod: 24 * time.Hour,
+			now:         flagged,
+			released:    true,
+		},
+		{
+			name:     "no grace period",
+			img:      image(false),
+			now:      flagged,
+			released: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if r := released(tc.img, tc.gracePeriod, tc.now); r != tc.released {
+				t.Errorf("unexpected result: expected: %t, got: %t", tc.released, r)
+			}
+		})
+	}
+}
+
+func TestUpdateQuarantine(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := eraserv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(name, image string) *eraserv1.NonCompliantImage {
+		img := &eraserv1.NonCompliantImage{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		img.Status.Image = image
+		img.Status.FirstFlagged = metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		return img
+	}
+
+	testCases := []struct {
+		name     string
+		existing []*eraserv1.NonCompliantImage
+		flagged  map[string][]string
+		pending  map[string]struct{}
+		complete bool
+		records  []string
+	}{
+		{
+			name:     "new image",
+			flagged:  map[string][]string{"sha256:a": {"node-b", "node-a"}},
+			complete: true,
+			records:  []string{"sha256-a"},
+		},
+		{
+			name:     "image no longer flagged",
+			existing: []*eraserv1.NonCompliantImage{record("sha256-a", "sha256:a")},
+			flagged:  map[string][]string{"sha256:b": nil},
+			complete: true,
+			records:  []string{"sha256-b"},
+		},
+		{
+			name:     "pending image",
+			existing: []*eraserv1.NonCompliantImage{record("sha256-a", "sha256:a")},
+			flagged:  map[string][]string{"sha256:b": nil},
+			pending:  map[string]struct{}{"sha256:a": {}},
+			complete: true,
+			records:  []string{"sha256-a", "sha256-b"},
+		},
+		{
+			name:     "incomplete run",
+			existing: []*eraserv1.NonCompliantImage{record("sha256-a", "sha256:a")},
+			flagged:  map[string][]string{"sha256:b": nil},
+			records:  []string{"sha256-a", "sha256-b"},
+		},
+		{
+			name:     "reference",
+			flagged:  map[string][]string{"docker.io/library/alpine:3.17": nil},
+			complete: true,
+			records:  []string{"docker.io.library.alpine-3.17"},
+		},
+		{
+			name:     "invalid name",
+			flagged:  map[string][]string{"alpine_3": nil},
+			complete: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			for _, img := range tc.existing {
+				builder = builder.WithObjects(img)
+			}
+			r := &Reconciler{Client: builder.Build(), Scheme: scheme}
+
+			cfg := eraserv1alpha1.QuarantineConfig{GracePeriod: eraserv1alpha1.Duration(24 * time.Hour)}
+			if err := r.updateQuarantine(context.Background(), cfg, tc.flagged, tc.pending, tc.complete); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			list := &eraserv1.NonCompliantImageList{}
+			if err := r.List(context.Background(), list); err != nil {
+				t.Fatal(err)
+			}
+
+			var records []string
+			for i := range list.Items {
+				img := &list.Items[i]
+				records = append(records, img.Name)
+
+				nodes, ok := tc.flagged[img.Status.Image]
+				if !ok {
+					continue
+				}
+				if img.Status.FirstFlagged.IsZero() || img.Status.LastFlagged.IsZero() {
+					t.Errorf("%s: flagged image without flag times: %+v", img.Name, img.Status)
+				}
+				if want := img.Status.FirstFlagged.Add(24 * time.Hour); !img.Status.RemoveAfter.Time.Equal(want) {
+					t.Errorf("%s: unexpected removal time: expected: %s, got: %s", img.Name, want, img.Status.RemoveAfter)
+				}
+				sort.Strings(nodes)
+				if len(nodes) > 0 && !reflect.DeepEqual(img.Status.Nodes, nodes) {
+					t.Errorf("%s: unexpected nodes: expected: %v, got: %v", img.Name, nodes, img.Status.Nodes)
+				}
+			}
+			sort.Strings(records)
+
+			if !reflect.DeepEqual(records, tc.records) {
+				t.Errorf("unexpected records: expected: %v, got: %v", tc.records, records)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package imagecollector
+
+import (
+	"fmt"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+)
+
+const (
+	// scannerPluginPath is where the scanner shim is installed for the
+	// scanner container.
+	scannerPluginPath       = "/run/eraser.sh/scanner-plugin"
+	scannerPluginVolumeName = "scanner-plugin"
+
+	// scannerShim is the path of the shim in the collector image.
+	scannerShim = "/scanner-shim"
+)
+
+// validateScannerConfig checks the scanner config against the scan mode.
+func validateScannerConfig(cfg *eraserv1alpha1.ScannerConfig, scanMode string) error {
+	switch protocol := cfg.Plugin.Protocol; protocol {
+	case "":
+		return nil
+	case eraserv1alpha1.ScannerPluginStdioV1:
+	default:
+		return fmt.Errorf("invalid scanner plugin protocol %q, must be empty or %q", protocol, eraserv1alpha1.ScannerPluginStdioV1)
+	}
+
+	if len(cfg.Plugin.Command) == 0 {
+		return fmt.Errorf("scanner plugin protocol %q requires a command", cfg.Plugin.Protocol)
+	}
+	if scanMode == eraserv1alpha1.ScanModeCluster {
+		return fmt.Errorf("scanner plugins are not supported in %q scan mode", scanMode)
+	}
+
+	return nil
+}
+
+// runScannerPlugin makes the scanner container run the plugin through the
+// scanner shim, which an init container copies from the collector image once
+// for every plugin of the pod.
+func runScannerPlugin(spec *corev1.PodSpec, scanner *corev1.Container, cfg *eraserv1alpha1.ScannerConfig, collectorImg, cfgFilename string) {
+	mount := corev1.VolumeMount{MountPath: scannerPluginPath, Name: scannerPluginVolumeName}
+
+	if !hasVolume(spec, scannerPluginVolumeName) {
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			// EmptyDir default
+			Name: scannerPluginVolumeName,
+		})
+		spec.InitContainers = append(spec.InitContainers, corev1.Container{
+			Name:            "install-scanner-shim",
+			Image:           collectorImg,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         []string{scannerShim, "install", scannerPluginPath},
+			VolumeMounts:    []corev1.VolumeMount{mount},
+		})
+	}
+
+	scanner.Command = append([]string{
+		filepath.Join(scannerPluginPath, filepath.Base(scannerShim)),
+		"run",
+		"--config=" + cfgFilename,
+		"--",
+	}, cfg.Plugin.Command...)
+	scanner.Args = nil
+	scanner.VolumeMounts = append(scanner.VolumeMounts, mount)
+}
+
+// addScannerSettings adds the environment and volumes of the scanner config
+// to the scanner container.
+func addScannerSettings(spec *corev1.PodSpec, scanner *corev1.Container, cfg *eraserv1alpha1.ScannerConfig) {
+	scanner.Env = append(scanner.Env, cfg.Env...)
+	scanner.VolumeMounts = append(scanner.VolumeMounts, cfg.VolumeMounts...)
+	spec.Volumes = append(spec.Volumes, cfg.Volumes...)
+}
+
+func hasVolume(spec *corev1.PodSpec, name string) bool {
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package imagecollector
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/pkg/pipeline"
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	// defaultScannerName names the main scanner container, unless it is a
+	// plugin or has a name.
+	defaultScannerName       = "trivy-scanner"
+	defaultPluginScannerName = "scanner"
+)
+
+// enabledScanners returns the scanners of an ImageJob pod: the main scanner
+// and the additional scanners that are enabled. There are none if the main
+// scanner is disabled.
+func enabledScanners(components *eraserv1alpha1.Components) []*eraserv1alpha1.ScannerConfig {
+	if !components.Scanner.Enabled {
+		return nil
+	}
+
+	scanners := []*eraserv1alpha1.ScannerConfig{&components.Scanner}
+	for i := range components.AdditionalScanners {
+		if components.AdditionalScanners[i].Enabled {
+			scanners = append(scanners, &components.AdditionalScanners[i])
+		}
+	}
+
+	return scanners
+}
+
+// scannerName returns the name of the container of the scanner.
+func scannerName(cfg *eraserv1alpha1.ScannerConfig) string {
+	switch {
+	case cfg.Name != "":
+		return cfg.Name
+	case cfg.Plugin.Protocol != "":
+		return defaultPluginScannerName
+	default:
+		return defaultScannerName
+	}
+}
+
+func scannerWeight(cfg *eraserv1alpha1.ScannerConfig) int {
+	if cfg.Weight == nil {
+		return 1
+	}
+	return *cfg.Weight
+}
+
+// verdictArgs returns the collector args combining the verdicts of the
+// scanners. Every rule comes down to a threshold on the weights of the
+// scanners finding an image non-compliant.
+func verdictArgs(scanners []*eraserv1alpha1.ScannerConfig, cfg eraserv1alpha1.VerdictConfig) []string {
+	weighted := cfg.Combine == eraserv1alpha1.VerdictCombineWeighted
+
+	weights := make([]string, 0, len(scanners))
+	for _, scanner := range scanners {
+		weight := 1
+		if weighted {
+			weight = scannerWeight(scanner)
+		}
+		weights = append(weights, scannerName(scanner)+":"+strconv.Itoa(weight))
+	}
+
+	threshold := 1
+	switch cfg.Combine {
+	case eraserv1alpha1.VerdictCombineAll:
+		threshold = len(scanners)
+	case eraserv1alpha1.VerdictCombineWeighted:
+		threshold = cfg.Threshold
+	}
+
+	return []string{
+		"--scanners=" + strings.Join(weights, ","),
+		"--verdict-threshold=" + strconv.Itoa(threshold),
+	}
+}
+
+// validateScanners checks the scanners and how their verdicts are combined.
+func validateScanners(components *eraserv1alpha1.Components, scanCfg *eraserv1alpha1.ScanConfig) error {
+	if err := validateScannerConfig(&components.Scanner, scanCfg.Mode); err != nil {
+		return err
+	}
+
+	names := map[string]bool{"collector": true, "eraser": true, scannerName(&components.Scanner): true}
+	volumes := map[string]bool{configVolumeName: true, scanCacheVolumeName: true, scannerPluginVolumeName: true, sharedDBVolumeName: true}
+	for i := range components.Scanner.Volumes {
+		volumes[components.Scanner.Volumes[i].Name] = true
+	}
+
+	for i := range components.AdditionalScanners {
+		scanner := &components.AdditionalScanners[i]
+		if scanner.Name == "" {
+			return fmt.Errorf("additional scanner %d has no name", i)
+		}
+		if errs := validation.IsDNS1123Label(scanner.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid name of additional scanner %q: %s", scanner.Name, strings.Join(errs, ", "))
+		}
+		if names[scanner.Name] {
+			return fmt.Errorf("duplicate container name %q of additional scanner", scanner.Name)
+		}
+		names[scanner.Name] = true
+
+		for j := range scanner.Volumes {
+			if volumes[scanner.Volumes[j].Name] {
+				return fmt.Errorf("duplicate volume %q of additional scanner %q", scanner.Volumes[j].Name, scanner.Name)
+			}
+			volumes[scanner.Volumes[j].Name] = true
+		}
+
+		if err := validateScannerConfig(scanner, scanCfg.Mode); err != nil {
+			return fmt.Errorf("additional scanner %q: %w", scanner.Name, err)
+		}
+		if scanner.Enabled && scanCfg.Mode == eraserv1alpha1.ScanModeCluster {
+			return fmt.Errorf("additional scanners are not supported in %q scan mode", scanCfg.Mode)
+		}
+	}
+
+	scanners := enabledScanners(components)
+	total := 0
+	for _, scanner := range scanners {
+		if w := scannerWeight(scanner); w < 0 {
+			return fmt.Errorf("negative weight %d of scanner %q", w, scannerName(scanner))
+		}
+		total += scannerWeight(scanner)
+	}
+
+	switch combine := scanCfg.Verdicts.Combine; combine {
+	case "", eraserv1alpha1.VerdictCombineAny, eraserv1alpha1.VerdictCombineAll:
+	case eraserv1alpha1.VerdictCombineWeighted:
+		if t := scanCfg.Verdicts.Threshold; len(scanners) > 0 && (t < 1 || t > total) {
+			return fmt.Errorf("invalid verdict threshold %d, must be between 1 and the total weight of the enabled scanners, %d", t, total)
+		}
+	default:
+		return fmt.Errorf("invalid verdict combination %q, must be %q, %q or %q", combine,
+			eraserv1alpha1.VerdictCombineAny, eraserv1alpha1.VerdictCombineAll, eraserv1alpha1.VerdictCombineWeighted)
+	}
+
+	return nil
+}
+
+// scannerContainer returns the container running scanner in an ImageJob pod,
+// adding the volumes it needs to spec. The result cache and the shared DB only
+// apply to the main scanner.
+func scannerContainer(spec *corev1.PodSpec, scanner *eraserv1alpha1.ScannerConfig, mgrCfg *eraserv1alpha1.ManagerConfig, profileArgs []string, collectorImg string, main bool) corev1.Container {
+	iCfg := scanner.Image
+	scannerImg := fmt.Sprintf("%s:%s", iCfg.Repo, iCfg.Tag)
+	name := scannerName(scanner)
+
+	cfgDirname := "/config"
+	cfgFilename := filepath.Join(cfgDirname, "controller_manager_config.yaml")
+	scannerArgs := []string{fmt.Sprintf("--config=%s", cfgFilename)}
+	scannerArgs = append(scannerArgs, profileArgs...)
+
+	container := corev1.Container{
+		Name:  name,
+		Image: scannerImg,
+		Args:  scannerArgs,
+		VolumeMounts: []corev1.VolumeMount{
+			{MountPath: "/run/eraser.sh/shared-data", Name: "shared-data"},
+			{MountPath: cfgDirname, Name: configVolumeName},
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				"memory": scanner.Request.Mem,
+				"cpu":    scanner.Request.CPU,
+			},
+			Limits: corev1.ResourceList{
+				"memory": scanner.Limit.Mem,
+			},
+		},
+		// env vars for exporting metrics
+		Env: []corev1.EnvVar{
+			{
+				Name:  "OTEL_EXPORTER_OTLP_ENDPOINT",
+				Value: mgrCfg.OTLPEndpoint,
+			},
+			{
+				Name:  "OTEL_SERVICE_NAME",
+				Value: name,
+			},
+			{
+				Name:  pipeline.ScannerNameEnv,
+				Value: name,
+			},
+		},
+	}
+
+	if cacheCfg := mgrCfg.Scan.Cache; main && cacheCfg.Enabled && scanner.Plugin.Protocol == "" {
+		// scan results outlive the pod so the next run on the node can
+		// reuse them
+		hostPathType := corev1.HostPathDirectoryOrCreate
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: scanCacheVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: cacheCfg.HostPath, Type: &hostPathType},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{MountPath: scanCachePath, Name: scanCacheVolumeName})
+		container.Args = append(container.Args, "--result-cache-dir="+scanCachePath)
+	}
+
+	if dbCfg := mgrCfg.Scan.SharedDB; main && dbCfg.Enabled && scanner.Plugin.Protocol == "" {
+		volume, mount := sharedDBVolume(dbCfg, true)
+		spec.Volumes = append(spec.Volumes, volume)
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+		container.Args = append(container.Args, "--shared-db-dir="+sharedDBPath)
+	}
+
+	// scanners reading from registries, such as the signature scanner, use
+	// the pull secrets of the manager to authenticate
+	volumes, mounts := pullSecretVolumes(mgrCfg.PullSecrets)
+	for i := range volumes {
+		if !hasVolume(spec, volumes[i].Name) {
+			spec.Volumes = append(spec.Volumes, volumes[i])
+		}
+	}
+	container.VolumeMounts = append(container.VolumeMounts, mounts...)
+
+	if scanner.Plugin.Protocol != "" {
+		runScannerPlugin(spec, &container, scanner, collectorImg, cfgFilename)
+	}
+	addScannerSettings(spec, &container, scanner)
+
+	return container
+}
+
+// pullSecretVolumes returns the volumes of the image pull secrets, and their
+// mounts under utils.PullSecretsDir, one directory per secret.
+func pullSecretVolumes(secrets []string) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := make([]corev1.Volume, 0, len(secrets))
+	mounts := make([]corev1.VolumeMount, 0, len(secrets))
+	for i, secret := range secrets {
+		name := fmt.Sprintf("pull-secret-%d", i)
+		volumes = append(volumes, corev1.Volume{
+			Name:         name,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secret}},
+		})
+		mounts = append(mounts, corev1.VolumeMount{MountPath: filepath.Join(utils.PullSecretsDir, secret), Name: name, ReadOnly: true})
+	}
+	return volumes, mounts
+}
@@ -0,0 +1,180 @@
+package imagecollector
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+)
+
+func scanner(name string, weight int) eraserv1alpha1.ScannerConfig {
+	s := eraserv1alpha1.ScannerConfig{Name: name, Weight: &weight}
+	s.Enabled = true
+	return s
+}
+
+func TestVerdictArgs(t *testing.T) {
+	trivy := scanner("", 3)
+	grype := scanner("grype", 2)
+	plugin := scanner("", 1)
+	plugin.Plugin.Protocol = eraserv1alpha1.ScannerPluginStdioV1
+	scanners := []*eraserv1alpha1.ScannerConfig{&trivy, &grype}
+
+	testCases := []struct {
+		name     string
+		scanners []*eraserv1alpha1.ScannerConfig
+		cfg      eraserv1alpha1.VerdictConfig
+		args     []string
+	}{
+		{
+			name:     "default",
+			scanners: scanners,
+			args:     []string{"--scanners=trivy-scanner:1,grype:1", "--verdict-threshold=1"},
+		},
+		{
+			name:     "any",
+			scanners: scanners,
+			cfg:      eraserv1alpha1.VerdictConfig{Combine: eraserv1alpha1.VerdictCombineAny},
+			args:     []string{"--scanners=trivy-scanner:1,grype:1", "--verdict-threshold=1"},
+		},
+		{
+			name:     "all",
+			scanners: scanners,
+			cfg:      eraserv1alpha1.VerdictConfig{Combine: eraserv1alpha1.VerdictCombineAll},
+			args:     []string{"--scanners=trivy-scanner:1,grype:1", "--verdict-threshold=2"},
+		},
+		{
+			name:     "weighted",
+			scanners: scanners,
+			cfg:      eraserv1alpha1.VerdictConfig{Combine: eraserv1alpha1.VerdictCombineWeighted, Threshold: 4},
+			args:     []string{"--scanners=trivy-scanner:3,grype:2", "--verdict-threshold=4"},
+		},
+		{
+			name:     "plugin",
+			scanners: []*eraserv1alpha1.ScannerConfig{&plugin},
+			args:     []string{"--scanners=scanner:1", "--verdict-threshold=1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if args := verdictArgs(tc.scanners, tc.cfg); !reflect.DeepEqual(args, tc.args) {
+				t.Errorf("unexpected args: expected: %v, got: %v", tc.args, args)
+			}
+		})
+	}
+}
+
+func TestValidateScanners(t *testing.T) {
+	components := func(additional ...eraserv1alpha1.ScannerConfig) *eraserv1alpha1.Components {
+		return &eraserv1alpha1.Components{Scanner: scanner("", 1), AdditionalScanners: additional}
+	}
+	withVolume := func(s eraserv1alpha1.ScannerConfig, volume string) eraserv1alpha1.ScannerConfig {
+		s.Volumes = append(s.Volumes, corev1.Volume{Name: volume})
+		return s
+	}
+	weighted := func(threshold int) eraserv1alpha1.ScanConfig {
+		return eraserv1alpha1.ScanConfig{Verdicts: eraserv1alpha1.VerdictConfig{Combine: eraserv1alpha1.VerdictCombineWeighted, Threshold: threshold}}
+	}
+	disabled := scanner("disabled", 5)
+	disabled.Enabled = false
+
+	testCases := []struct {
+		name       string
+		components *eraserv1alpha1.Components
+		scan       eraserv1alpha1.ScanConfig
+		err        bool
+	}{
+		{
+			name:       "main scanner",
+			components: components(),
+		},
+		{
+			name:       "additional scanners",
+			components: components(scanner("grype", 1), scanner("clamav", 1)),
+		},
+		{
+			name:       "unnamed additional scanner",
+			components: components(scanner("", 1)),
+			err:        true,
+		},
+		{
+			name:       "invalid name",
+			components: components(scanner("Grype_1", 1)),
+			err:        true,
+		},
+		{
+			name:       "name of the main scanner",
+			components: components(scanner("trivy-scanner", 1)),
+			err:        true,
+		},
+		{
+			name:       "name of the eraser",
+			components: components(scanner("eraser", 1)),
+			err:        true,
+		},
+		{
+			name:       "duplicate name",
+			components: components(scanner("grype", 1), scanner("grype", 1)),
+			err:        true,
+		},
+		{
+			name:       "volume of the pod",
+			components: components(withVolume(scanner("grype", 1), configVolumeName)),
+			err:        true,
+		},
+		{
+			name:       "duplicate volume",
+			components: components(withVolume(scanner("grype", 1), "db"), withVolume(scanner("clamav", 1), "db")),
+			err:        true,
+		},
+		{
+			name:       "cluster scan mode",
+			components: components(scanner("grype", 1)),
+			scan:       eraserv1alpha1.ScanConfig{Mode: eraserv1alpha1.ScanModeCluster},
+			err:        true,
+		},
+		{
+			name:       "negative weight",
+			components: components(scanner("grype", -1)),
+			err:        true,
+		},
+		{
+			name:       "weighted threshold",
+			components: components(scanner("grype", 2)),
+			scan:       weighted(3),
+		},
+		{
+			name:       "threshold above total weight",
+			components: components(scanner("grype", 2), disabled),
+			scan:       weighted(4),
+			err:        true,
+		},
+		{
+			name:       "zero threshold",
+			components: components(),
+			scan:       weighted(0),
+			err:        true,
+		},
+		{
+			name:       "invalid combination",
+			components: components(),
+			scan:       eraserv1alpha1.ScanConfig{Verdicts: eraserv1alpha1.VerdictConfig{Combine: "majority"}},
+			err:        true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateScanners(tc.components, &tc.scan)
+			if tc.err && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tc.err && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
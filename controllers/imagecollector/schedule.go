@@ -0,0 +1,173 @@
+package imagecollector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/controllers/util"
+	"github.com/Azure/eraser/pkg/schedule"
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	scheduleConfigmapName = "eraser-collector-schedule"
+	lastRunKey            = "lastRun"
+)
+
+// scheduler decides when the collector runs from the scheduling config.
+type scheduler struct {
+	cron      *schedule.Cron
+	interval  time.Duration
+	immediate bool
+	blackouts []schedule.Window
+}
+
+func newScheduler(cfg v1alpha1.ScheduleConfig) (*scheduler, error) {
+	loc, err := util.ScheduleLocation(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &scheduler{
+		interval:  time.Duration(cfg.RepeatInterval),
+		immediate: cfg.BeginImmediately,
+	}
+
+	if cfg.Cron != "" {
+		c, err := schedule.Parse(cfg.Cron, loc)
+		if err != nil {
+			return nil, err
+		}
+		s.cron = c
+	} else if s.interval <= 0 {
+		return nil, fmt.Errorf("scheduling requires a cron expression or a positive repeatInterval")
+	}
+
+	if s.blackouts, err = util.Blackouts(cfg); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// next returns when the collector should next start, given when it last
+// started. A zero lastRun means the collector has never run. A missed run
+// is due immediately, unless it falls in a blackout window. It returns the
+// zero time when the cron expression never matches again.
+func (s *scheduler) next(lastRun, now time.Time) time.Time {
+	var next time.Time
+	switch {
+	case lastRun.IsZero() && s.immediate:
+		next = now
+	case lastRun.IsZero():
+		lastRun = now
+		fallthrough
+	default:
+		if s.cron != nil {
+			next = s.cron.Next(lastRun)
+			if next.IsZero() {
+				return next
+			}
+		} else {
+			next = lastRun.Add(s.interval)
+		}
+	}
+
+	if next.Before(now) {
+		next = now
+	}
+
+	return schedule.Defer(next, s.blackouts)
+}
+
+// getLastRun reads the start time of the previous collector run, which is
+// persisted so that restarting the manager does not trigger an extra run.
+func (r *Reconciler) getLastRun(ctx context.Context) (time.Time, error) {
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: utils.GetNamespace(), Name: scheduleConfigmapName}, cm)
+	if apierrors.IsNotFound(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	value, ok := cm.Data[lastRunKey]
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	lastRun, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Error(err, "ignoring invalid last run time", "configmap", scheduleConfigmapName, "value", value)
+		return time.Time{}, nil
+	}
+
+	return lastRun, nil
+}
+
+func (r *Reconciler) setLastRun(ctx context.Context, t time.Time) error {
+	value := t.UTC().Format(time.RFC3339)
+
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: utils.GetNamespace(), Name: scheduleConfigmapName}, cm)
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      scheduleConfigmapName,
+				Namespace: utils.GetNamespace(),
+			},
+			Data: map[string]string{lastRunKey: value},
+		}
+		return r.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[lastRunKey] = value
+	return r.Update(ctx, cm)
+}
+
+// scheduleNext starts a collector ImageJob if one is due, and otherwise
+// requeues until the next run.
+func (r *Reconciler) scheduleNext(ctx context.Context) (ctrl.Result, error) {
+	eraserConfig, err := r.eraserConfig.Read()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	s, err := newScheduler(eraserConfig.Manager.Scheduling)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	lastRun, err := r.getLastRun(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	next := s.next(lastRun, now)
+	if next.IsZero() {
+		log.Info("No collector run scheduled: the cron expression never matches again", "cron", eraserConfig.Manager.Scheduling.Cron)
+		return ctrl.Result{}, nil
+	}
+	if wait := next.Sub(now); wait > 0 {
+		log.Info("Next collector run scheduled", "at", next)
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	return r.createImageJob(ctx)
+}
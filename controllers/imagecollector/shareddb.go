@@ -0,0 +1,160 @@
+package imagecollector
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/controllers/util"
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	fetchDBJobName     = "eraser-trivy-db-fetch"
+	sharedDBVolumeName = "trivy-db"
+	sharedDBPath       = "/run/eraser.sh/trivy-db"
+)
+
+// usesSharedDB reports whether the scanners read the DB fetched by the
+// manager. Only the main scanner does, when it is built on the scanner
+// template.
+func usesSharedDB(eraserConfig *eraserv1alpha1.EraserConfig) bool {
+	scanner := &eraserConfig.Components.Scanner
+	return eraserConfig.Manager.Scan.SharedDB.Enabled && scanner.Enabled && scanner.Plugin.Protocol == ""
+}
+
+// sharedDBVolume returns the volume of the shared DB claim and where it is
+// mounted.
+func sharedDBVolume(cfg eraserv1alpha1.SharedDBConfig, readOnly bool) (corev1.Volume, corev1.VolumeMount) {
+	volume := corev1.Volume{
+		Name: sharedDBVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: cfg.ClaimName, ReadOnly: readOnly},
+		},
+	}
+	mount := corev1.VolumeMount{MountPath: sharedDBPath, Name: sharedDBVolumeName, ReadOnly: readOnly}
+	return volume, mount
+}
+
+// fetchDB runs the Job fetching the DB into the shared claim and reports
+// whether it is done. When the fetch fails, the scanners use the DB left in
+// the claim by a previous run, or fetch their own.
+func (r *Reconciler) fetchDB(ctx context.Context, eraserConfig *eraserv1alpha1.EraserConfig) (bool, error) {
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: utils.GetNamespace(), Name: fetchDBJobName}, job)
+	if apierrors.IsNotFound(err) {
+		job = fetchDBJob(eraserConfig)
+		if err := r.Create(ctx, job); err != nil {
+			log.Info("Could not create trivy DB fetch Job")
+			return false, err
+		}
+		log.Info("Successfully created trivy DB fetch Job", "job", job.Name)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !jobFinished(job) {
+		return false, nil
+	}
+	if job.Status.Succeeded == 0 {
+		log.Error(fmt.Errorf("trivy DB fetch Job %s failed", job.Name), "scanners will use the DB of a previous run or fetch their own")
+	}
+
+	// the next run fetches the DB again
+	if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// fetchDBJob returns the Job running the scanner to fetch its DB into the
+// shared claim, as configured for the scanner: from its registry, a bundle
+// or not at all when offline.
+func fetchDBJob(eraserConfig *eraserv1alpha1.EraserConfig) *batchv1.Job {
+	mgrCfg := eraserConfig.Manager
+	scanCfg := eraserConfig.Components.Scanner
+
+	iCfg := scanCfg.Image
+	scannerImg := fmt.Sprintf("%s:%s", iCfg.Repo, iCfg.Tag)
+
+	cfgDirname := "/config"
+	args := []string{
+		fmt.Sprintf("--config=%s", filepath.Join(cfgDirname, "controller_manager_config.yaml")),
+		"--fetch-db=" + sharedDBPath,
+	}
+
+	dbVolume, dbMount := sharedDBVolume(mgrCfg.Scan.SharedDB, false)
+	volumes := []corev1.Volume{
+		{
+			Name: configVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: util.EraserConfigmapName}},
+			},
+		},
+		dbVolume,
+	}
+	mounts := []corev1.VolumeMount{
+		{MountPath: cfgDirname, Name: configVolumeName},
+		dbMount,
+	}
+	// the scanner volumes may hold a DB bundle
+	mounts = append(mounts, scanCfg.VolumeMounts...)
+	volumes = append(volumes, scanCfg.Volumes...)
+
+	pullSecrets := []corev1.LocalObjectReference{}
+	for _, secret := range mgrCfg.PullSecrets {
+		pullSecrets = append(pullSecrets, corev1.LocalObjectReference{Name: secret})
+	}
+
+	backoffLimit := int32(0)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fetchDBJobName,
+			Namespace: utils.GetNamespace(),
+			Labels: map[string]string{
+				util.ImageJobOwnerLabelKey: ownerLabelValue,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes:           volumes,
+					ImagePullSecrets:  pullSecrets,
+					RestartPolicy:     corev1.RestartPolicyNever,
+					PriorityClassName: mgrCfg.PriorityClassName,
+					Containers: []corev1.Container{
+						{
+							Name:            "trivy-db-fetch",
+							Image:           scannerImg,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Args:            args,
+							VolumeMounts:    mounts,
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									"memory": scanCfg.Request.Mem,
+									"cpu":    scanCfg.Request.CPU,
+								},
+								Limits: corev1.ResourceList{
+									"memory": scanCfg.Limit.Mem,
+								},
+							},
+							Env: scanCfg.Env,
+						},
+					},
+					ServiceAccountName: "eraser-imagejob-pods",
+				},
+			},
+		},
+	}
+}
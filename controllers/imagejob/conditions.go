@@ -0,0 +1,130 @@
+package imagejob
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	eraserv1 "github.com/Azure/eraser/api/v1"
+)
+
+// Reasons of the conditions and events of ImageJobs and nodes.
+const (
+	reasonNodesSelected      = "NodesSelected"
+	reasonPodsStarted        = "PodsStarted"
+	reasonPodsFinished       = "PodsFinished"
+	reasonPodDoesNotFit      = "PodDoesNotFit"
+	reasonRolloutAborted     = "RolloutAborted"
+	reasonSuccessRatioMet    = "SuccessRatioMet"
+	reasonSuccessRatioNotMet = "SuccessRatioNotMet"
+	reasonPodsFailed         = "PodsFailed"
+	reasonNodesNotRun        = "NodesNotRun"
+	reasonAllNodesSucceeded  = "AllNodesSucceeded"
+	reasonImagesRemoved      = "ImagesRemoved"
+	reasonImageRemovalFailed = "ImageRemovalFailed"
+	reasonEraserFailed       = "EraserFailed"
+)
+
+// setCondition sets a condition of imageJob for its current generation.
+func setCondition(imageJob *eraserv1.ImageJob, conditionType string, status bool, reason, message string) {
+	conditionStatus := metav1.ConditionFalse
+	if status {
+		conditionStatus = metav1.ConditionTrue
+	}
+
+	meta.SetStatusCondition(&imageJob.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		ObservedGeneration: imageJob.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// setScheduledConditions records that the pods of imageJob are being started
+// on the nodes selected.
+func setScheduledConditions(imageJob *eraserv1.ImageJob, selected, skipped int) string {
+	message := fmt.Sprintf("starting pods on %d nodes, %d nodes skipped by the node filter", selected, skipped)
+	setCondition(imageJob, eraserv1.ConditionScheduled, true, reasonNodesSelected, message)
+	setCondition(imageJob, eraserv1.ConditionRunning, true, reasonPodsStarted, message)
+	return message
+}
+
+// setCompletedConditions records the outcome of imageJob once its pods have
+// finished. It returns the message of the Succeeded condition.
+func setCompletedConditions(imageJob *eraserv1.ImageJob, ratio, successRatio float64) string {
+	status := &imageJob.Status
+
+	setCondition(imageJob, eraserv1.ConditionRunning, false, reasonPodsFinished,
+		fmt.Sprintf("%d pods succeeded, %d pods failed", status.Succeeded, status.Failed))
+
+	succeeded := status.Phase == eraserv1.PhaseCompleted
+	reason := reasonSuccessRatioMet
+	if !succeeded {
+		reason = reasonSuccessRatioNotMet
+	}
+	message := fmt.Sprintf("%d of %d nodes succeeded or were skipped, a ratio of %.2f for a success ratio of %.2f",
+		status.Succeeded+status.Skipped, status.Desired, ratio, successRatio)
+	setCondition(imageJob, eraserv1.ConditionSucceeded, succeeded, reason, message)
+	setCondition(imageJob, eraserv1.ConditionFailed, !succeeded, reason, message)
+
+	notRun := status.Desired - status.Succeeded - status.Failed - status.Skipped
+	switch {
+	case status.Failed > 0:
+		setCondition(imageJob, eraserv1.ConditionDegraded, true, reasonPodsFailed, fmt.Sprintf("%d pods failed", status.Failed))
+	case notRun > 0:
+		setCondition(imageJob, eraserv1.ConditionDegraded, true, reasonNodesNotRun,
+			fmt.Sprintf("%d nodes had no pod, because it did not fit or the rollout was aborted", notRun))
+	default:
+		setCondition(imageJob, eraserv1.ConditionDegraded, false, reasonAllNodesSucceeded, "all nodes succeeded or were skipped")
+	}
+
+	return message
+}
+
+// nodeReference refers to a node for events, the way the kubelet does.
+func nodeReference(name string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind: "Node",
+		Name: name,
+		UID:  types.UID(name),
+	}
+}
+
+// recordNodeEvents reports on the node of each pod what the eraser did
+// there. The results are read from the pods, as those in the job status may
+// have been shortened.
+func recordNodeEvents(recorder record.EventRecorder, pods []corev1.Pod) {
+	for i := range pods {
+		result, ok := eraserResult(&pods[i])
+		if !ok {
+			continue
+		}
+		node := nodeReference(result.Node)
+
+		if result.Error != "" {
+			recorder.Eventf(node, corev1.EventTypeWarning, reasonEraserFailed, "eraser failed: %s", result.Error)
+			continue
+		}
+
+		if len(result.Errors) > 0 {
+			recorder.Eventf(node, corev1.EventTypeWarning, reasonImageRemovalFailed, "eraser could not remove %d images", len(result.Errors))
+		}
+
+		// Removed may have been truncated, RemovedCount is not
+		if result.RemovedCount == 0 {
+			continue
+		}
+		size := float64(result.RemovedBytes) / (1 << 20)
+
+		if result.DryRun {
+			recorder.Eventf(node, corev1.EventTypeNormal, reasonImagesRemoved, "eraser would remove %d images (%.1f MiB), dry run", result.RemovedCount, size)
+			continue
+		}
+		recorder.Eventf(node, corev1.EventTypeNormal, reasonImagesRemoved, "eraser removed %d images (%.1f MiB)", result.RemovedCount, size)
+	}
+}
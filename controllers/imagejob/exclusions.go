@@ -0,0 +1,220 @@
+package imagejob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	eraserv1 "github.com/Azure/eraser/api/v1"
+	eraserUtils "github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	// collectorContainerName is the container that reports the images it left
+	// out of collection because of an ImageExclusion.
+	collectorContainerName = "collector"
+	// exclusionRulesVolumeName is the volume of the ImageExclusion rules that
+	// apply to the node of a pod.
+	exclusionRulesVolumeName = "image-exclusions"
+)
+
+// imageExclusions returns the ImageExclusions that have not expired.
+func (r *Reconciler) imageExclusions(ctx context.Context) ([]eraserv1.ImageExclusion, error) {
+	exclusionList := &eraserv1.ImageExclusionList{}
+	if err := r.List(ctx, exclusionList); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	exclusions := make([]eraserv1.ImageExclusion, 0, len(exclusionList.Items))
+	for i := range exclusionList.Items {
+		expiresAt := exclusionList.Items[i].Spec.ExpiresAt
+		if expiresAt != nil && !expiresAt.Time.After(now) {
+			log.V(1).Info("ignoring expired ImageExclusion", "name", exclusionList.Items[i].Name, "expiresAt", expiresAt)
+			continue
+		}
+		exclusions = append(exclusions, exclusionList.Items[i])
+	}
+
+	return exclusions, nil
+}
+
+// exclusionRules returns the rules of the exclusions whose node selector
+// matches node as JSON, and the key they are stored under in the exclusions
+// configmap of a job. Nodes with the same rules share a key. It returns
+// empty strings if no exclusion matches. An invalid node selector matches
+// every node, so that a mistake protects too many images rather than none.
+func exclusionRules(exclusions []eraserv1.ImageExclusion, node *corev1.Node) (string, string, error) {
+	var rules []eraserUtils.ExclusionRule
+	for i := range exclusions {
+		if selector := exclusions[i].Spec.NodeSelector; selector != nil {
+			s, err := metav1.LabelSelectorAsSelector(selector)
+			if err != nil {
+				log.Error(err, "invalid node selector in ImageExclusion, applying it to every node", "name", exclusions[i].Name)
+			} else if !s.Matches(labels.Set(node.Labels)) {
+				continue
+			}
+		}
+
+		rule := eraserUtils.ExclusionRule{Name: exclusions[i].Name}
+		for _, img := range exclusions[i].Spec.Images {
+			rule.Images = append(rule.Images, string(img))
+		}
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 {
+		return "", "", nil
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), string(data), nil
+}
+
+func exclusionsConfigMapName(imageJob *eraserv1.ImageJob) string {
+	return imageJob.Name + "-exclusions"
+}
+
+// storeExclusionRules adds rules, by key, to the exclusions configmap of
+// imageJob, which is deleted along with the job. Rules stored by earlier
+// passes of the rollout are kept, as their pods may still be starting.
+func (r *Reconciler) storeExclusionRules(ctx context.Context, imageJob *eraserv1.ImageJob, rules map[string]string) error {
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: eraserUtils.GetNamespace(), Name: exclusionsConfigMapName(imageJob)}, cm)
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      exclusionsConfigMapName(imageJob),
+				Namespace: eraserUtils.GetNamespace(),
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(imageJob, imageJob.GroupVersionKind()),
+				},
+			},
+			Data: rules,
+		}
+		return r.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, len(rules))
+	}
+	for key, value := range rules {
+		if _, ok := cm.Data[key]; !ok {
+			cm.Data[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return r.Update(ctx, cm)
+}
+
+// mountExclusionRules mounts the rules stored under key in the exclusions
+// configmap of imageJob in every container of spec.
+func mountExclusionRules(spec *corev1.PodSpec, imageJob *eraserv1.ImageJob, key string) {
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: exclusionRulesVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: exclusionsConfigMapName(imageJob)},
+				Items:                []corev1.KeyToPath{{Key: key, Path: eraserUtils.ImageExclusionsFile}},
+			},
+		},
+	})
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, corev1.VolumeMount{
+			Name:      exclusionRulesVolumeName,
+			MountPath: eraserUtils.ImageExclusionsDir,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// updateExclusionStatus records in each ImageExclusion how many images it
+// protected on the nodes of a completed job.
+func (r *Reconciler) updateExclusionStatus(ctx context.Context, results []eraserv1.NodeResult) error {
+	exclusionList := &eraserv1.ImageExclusionList{}
+	if err := r.List(ctx, exclusionList); err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	for i := range exclusionList.Items {
+		exclusion := &exclusionList.Items[i]
+
+		status := eraserv1.ImageExclusionStatus{LastRunTime: &now}
+		for j := range results {
+			if protected, ok := results[j].Exclusions[exclusion.Name]; ok {
+				status.Protected += protected
+				status.Nodes++
+			}
+		}
+
+		if status.Nodes == 0 {
+			continue
+		}
+
+		exclusion.Status = status
+		if err := r.Status().Update(ctx, exclusion); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectorExclusions returns the ImageExclusion counts reported by the
+// collector container of pod, if any.
+func collectorExclusions(pod *corev1.Pod) map[string]int64 {
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if status.Name != collectorContainerName || terminated == nil || terminated.Message == "" {
+			continue
+		}
+
+		var result eraserv1.NodeResult
+		if err := json.Unmarshal([]byte(terminated.Message), &result); err != nil {
+			log.Info("unable to parse collector result", "pod", pod.Name, "node", pod.Spec.NodeName)
+			return nil
+		}
+		return result.Exclusions
+	}
+
+	return nil
+}
+
+// mergeExclusions merges the counts reported by the collector into those
+// reported by the eraser. The collector leaves excluded images out of what
+// the eraser sees, so usually only one of them finds images for a rule; the
+// larger count is kept.
+func mergeExclusions(into, from map[string]int64) map[string]int64 {
+	if len(from) == 0 {
+		return into
+	}
+	if into == nil {
+		into = make(map[string]int64, len(from))
+	}
+	for name, n := range from {
+		if n > into[name] {
+			into[name] = n
+		}
+	}
+	return into
+}
@@ -0,0 +1,92 @@
+package imagejob
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	eraserv1 "github.com/Azure/eraser/api/v1"
+)
+
+func TestExclusionRules(t *testing.T) {
+	exclusion := func(name string, selector map[string]string, images ...eraserv1.ImagePattern) eraserv1.ImageExclusion {
+		e := eraserv1.ImageExclusion{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		e.Spec.Images = images
+		if selector != nil {
+			e.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: selector}
+		}
+		return e
+	}
+	node := func(labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Labels: labels}}
+	}
+
+	exclusions := []eraserv1.ImageExclusion{
+		exclusion("all", nil, "docker.io/library/*"),
+		exclusion("gpu", map[string]string{"pool": "gpu"}, "nvcr.io/*"),
+	}
+
+	testCases := []struct {
+		name       string
+		exclusions []eraserv1.ImageExclusion
+		node       *corev1.Node
+		rules      string
+	}{
+		{
+			name:       "every node",
+			exclusions: exclusions,
+			node:       node(nil),
+			rules:      `[{"name":"all","images":["docker.io/library/*"]}]`,
+		},
+		{
+			name:       "matching selector",
+			exclusions: exclusions,
+			node:       node(map[string]string{"pool": "gpu"}),
+			rules:      `[{"name":"all","images":["docker.io/library/*"]},{"name":"gpu","images":["nvcr.io/*"]}]`,
+		},
+		{
+			name:       "no matching exclusion",
+			exclusions: exclusions[1:],
+			node:       node(map[string]string{"pool": "cpu"}),
+		},
+		{
+			name: "invalid selector",
+			exclusions: []eraserv1.ImageExclusion{{
+				ObjectMeta: metav1.ObjectMeta{Name: "invalid"},
+				Spec: eraserv1.ImageExclusionSpec{
+					Images: []eraserv1.ImagePattern{"nvcr.io/*"},
+					NodeSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "pool", Operator: "Matches", Values: []string{"gpu"}},
+					}},
+				},
+			}},
+			node:  node(map[string]string{"pool": "cpu"}),
+			rules: `[{"name":"invalid","images":["nvcr.io/*"]}]`,
+		},
+		{
+			name: "no exclusions",
+			node: node(nil),
+		},
+	}
+
+	keys := make(map[string]string)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, rules, err := exclusionRules(tc.exclusions, tc.node)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rules != tc.rules {
+				t.Errorf("unexpected rules: expected: %s, got: %s", tc.rules, rules)
+			}
+			if (key == "") != (rules == "") {
+				t.Errorf("unexpected key %q for rules %q", key, rules)
+			}
+			if other, ok := keys[key]; ok && other != rules {
+				t.Errorf("key %q is used for both %s and %s", key, other, rules)
+			}
+			keys[key] = rules
+		})
+	}
+}
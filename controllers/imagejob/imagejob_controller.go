@@ -15,8 +15,10 @@ package imagejob
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -24,12 +26,13 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 	"k8s.io/kubernetes/pkg/scheduler/framework/plugins/noderesources"
 
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -52,6 +55,18 @@ import (
 const (
 	defaultFilterLabel = "eraser.sh/cleanup.filter"
 	windowsFilterLabel = "kubernetes.io/os=windows"
+
+	// eraserContainerName is the container whose termination message holds
+	// the NodeResult for its node.
+	eraserContainerName = controllerUtils.EraserContainerName
+	// stateVolumeName is the volume of the eraser state directory on the node.
+	stateVolumeName = "eraser-state"
+	// maxNodeResultsSize bounds the node results kept in an ImageJob status so
+	// that large clusters stay well below the etcd object size limit.
+	maxNodeResultsSize = 512 * 1024
+	// runningJobRequeue is how often running jobs are checked on, on top of
+	// the changes to their pods.
+	runningJobRequeue = time.Minute
 )
 
 var log = logf.Log.WithName("controller").WithValues("process", "imagejob-controller")
@@ -63,25 +78,42 @@ var defaultTolerations = []corev1.Toleration{
 }
 
 func Add(mgr manager.Manager, cfg *config.Manager) error {
-	return add(mgr, newReconciler(mgr, cfg))
+	r, err := newReconciler(mgr, cfg)
+	if err != nil {
+		return err
+	}
+	return add(mgr, r)
 }
 
 // newReconciler returns a new reconcile.Reconciler.
-func newReconciler(mgr manager.Manager, cfg *config.Manager) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager, cfg *config.Manager) (reconcile.Reconciler, error) {
+	stats, err := controllerUtils.NewNodeStats(mgr.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+
 	rec := &Reconciler{
 		Client:       mgr.GetClient(),
+		apiReader:    mgr.GetAPIReader(),
 		scheme:       mgr.GetScheme(),
 		eraserConfig: cfg,
+		recorder:     mgr.GetEventRecorderFor("imagejob-controller"),
+		stats:        stats,
 	}
 
-	return rec
+	return rec, nil
 }
 
 // ImageJobReconciler reconciles a ImageJob object.
 type Reconciler struct {
 	client.Client
+	// apiReader lists workloads without caching them
+	apiReader    client.Reader
 	scheme       *runtime.Scheme
 	eraserConfig *config.Manager
+	recorder     record.EventRecorder
+	// stats reads the image filesystem usage the watermarks apply to
+	stats *controllerUtils.NodeStats
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler.
@@ -159,6 +191,10 @@ func checkNodeFitness(pod *corev1.Pod, node *corev1.Node) bool {
 //+kubebuilder:rbac:groups="",resources=podtemplates,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=eraser.sh,resources=imagejobs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=eraser.sh,resources=imageexclusions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=eraser.sh,resources=imageexclusions/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=nodes/proxy,verbs=get
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -194,6 +230,11 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, fmt.Errorf("reconcile: unexpected imagejob phase: %s", imageJob.Status.Phase)
 	}
 
+	// changes to the pods requeue the job, this only guards against a missed event
+	if imageJob.Status.Phase == eraserv1.PhaseRunning {
+		return ctrl.Result{RequeueAfter: runningJobRequeue}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
@@ -205,9 +246,6 @@ func podListOptions(jobTemplate *corev1.PodTemplate) client.ListOptions {
 }
 
 func (r *Reconciler) handleRunningJob(ctx context.Context, imageJob *eraserv1.ImageJob) error {
-	// get eraser pods
-	podList := &corev1.PodList{}
-
 	template := corev1.PodTemplate{}
 	namespace := eraserUtils.GetNamespace()
 
@@ -219,69 +257,184 @@ func (r *Reconciler) handleRunningJob(ctx context.Context, imageJob *eraserv1.Im
 		return err
 	}
 
-	listOpts := podListOptions(&template)
-	err = r.List(ctx, podList, &listOpts)
+	pods, err := r.jobPods(ctx, imageJob, &template)
 	if err != nil {
 		return err
 	}
 
+	eraserConfig, err := r.eraserConfig.Read()
+	if err != nil {
+		return err
+	}
+
+	managerConfig := eraserConfig.Manager
+	successRatio := managerConfig.ImageJob.SuccessRatio
+
 	failed := 0
 	success := 0
+	running := 0
 	skipped := imageJob.Status.Skipped
 
-	if !podsComplete(podList.Items) {
-		return nil
+	// nodes the job has a pod on, or was found not to fit on
+	seen := make(map[string]bool, len(pods)+len(imageJob.Status.UnfitNodes))
+	for _, name := range imageJob.Status.UnfitNodes {
+		seen[name] = true
+	}
+	for i := range pods {
+		seen[pods[i].Spec.NodeName] = true
+		switch pods[i].Status.Phase {
+		case corev1.PodRunning, corev1.PodPending:
+			running++
+		case corev1.PodSucceeded:
+			success++
+		default:
+			failed++
+		}
 	}
 
-	// if all pods are complete, job is complete
-	// get status of pods
-	for i := range podList.Items {
-		if podList.Items[i].Status.Phase == corev1.PodSucceeded {
-			success++
+	// start the next pods of the rollout as earlier ones finish
+	pending := 0
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes); err != nil {
+		return err
+	}
+
+	remaining := remainingNodes(nodes.Items, imageJob.Status.TargetNodes, seen)
+
+	if len(remaining) > 0 {
+		rollout := managerConfig.ImageJob.Rollout
+		limit, err := rolloutLimit(rollout, imageJob.Status.Desired)
+		if err != nil {
+			return err
+		}
+
+		if rolloutAborted(rollout, successRatio, success, failed, limit) {
+			log.Info("Aborting rollout, too many pods failed",
+				"job", imageJob.Name,
+				"success ratio", successRatio,
+				"succeeded", success,
+				"failed", failed,
+				"not started", len(remaining),
+			)
+			r.recorder.Eventf(imageJob, corev1.EventTypeWarning, reasonRolloutAborted,
+				"not starting pods on %d nodes, %d of %d finished pods failed", len(remaining), failed, success+failed)
 		} else {
-			failed++
+			var unfit []string
+			pending, unfit, err = r.startPods(ctx, imageJob, &template, remaining, limit-running)
+			if err != nil {
+				return err
+			}
+			if err := r.recordUnfitNodes(ctx, imageJob, unfit); err != nil {
+				return err
+			}
 		}
 	}
 
+	if running > 0 || pending > 0 {
+		return nil
+	}
+
+	// if all pods are complete, job is complete
+	// get status of pods
+	conditions := imageJob.Status.Conditions
 	imageJob.Status = eraserv1.ImageJobStatus{
-		Desired:   imageJob.Status.Desired,
-		Succeeded: success,
-		Skipped:   skipped,
-		Failed:    failed,
-		Phase:     eraserv1.PhaseCompleted,
+		Desired:     imageJob.Status.Desired,
+		Succeeded:   success,
+		Skipped:     skipped,
+		Failed:      failed,
+		Phase:       eraserv1.PhaseCompleted,
+		Nodes:       nodeResults(pods),
+		TargetNodes: imageJob.Status.TargetNodes,
+		UnfitNodes:  imageJob.Status.UnfitNodes,
 	}
 
 	successAndSkipped := success + skipped
-
-	eraserConfig, err := r.eraserConfig.Read()
-	if err != nil {
-		return err
+	ratio := 1.0
+	if imageJob.Status.Desired > 0 {
+		ratio = float64(successAndSkipped) / float64(imageJob.Status.Desired)
 	}
 
-	managerConfig := eraserConfig.Manager
-	successRatio := managerConfig.ImageJob.SuccessRatio
-
-	if float64(successAndSkipped/imageJob.Status.Desired) < successRatio {
+	if ratio < successRatio {
 		log.Info(
 			"Marking job as failed",
 			"success ratio", successRatio,
-			"actual ratio", success/imageJob.Status.Desired,
+			"actual ratio", ratio,
 		)
 		imageJob.Status.Phase = eraserv1.PhaseFailed
 	}
 
-	return r.updateJobStatus(ctx, imageJob)
+	imageJob.Status.Conditions = conditions
+	message := setCompletedConditions(imageJob, ratio, successRatio)
+	if imageJob.Status.Phase == eraserv1.PhaseFailed {
+		r.recorder.Event(imageJob, corev1.EventTypeWarning, reasonSuccessRatioNotMet, message)
+	} else {
+		r.recorder.Event(imageJob, corev1.EventTypeNormal, reasonSuccessRatioMet, message)
+	}
+	recordNodeEvents(r.recorder, pods)
+
+	if err := r.updateExclusionStatus(ctx, imageJob.Status.Nodes); err != nil {
+		log.Error(err, "unable to update ImageExclusion status", "job", imageJob.Name)
+	}
+
+	if err := r.updateJobStatus(ctx, imageJob); err != nil {
+		return err
+	}
+
+	// only once the status is saved, so a retry does not count the job twice
+	recordMetrics(imageJob, pods)
+	return nil
 }
 
-func (r *Reconciler) handleNewJob(ctx context.Context, imageJob *eraserv1.ImageJob) error {
+// jobPods returns the pods created for imageJob.
+func (r *Reconciler) jobPods(ctx context.Context, imageJob *eraserv1.ImageJob, template *corev1.PodTemplate) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	listOpts := podListOptions(template)
+	// read from the API server, as pods started by the previous pass may not
+	// be cached yet and would otherwise be started again
+	if err := r.apiReader.List(ctx, podList, &listOpts); err != nil {
+		return nil, err
+	}
+
+	pods := make([]corev1.Pod, 0, len(podList.Items))
+	for i := range podList.Items {
+		if metav1.IsControlledBy(&podList.Items[i], imageJob) {
+			pods = append(pods, podList.Items[i])
+		}
+	}
+
+	return pods, nil
+}
+
+// selectNodes returns the nodes imageJob should run on, and how many nodes
+// were skipped by the node filter.
+func (r *Reconciler) selectNodes(ctx context.Context, imageJob *eraserv1.ImageJob, filterOpts eraserv1alpha1.NodeFilterConfig) ([]corev1.Node, int, error) {
 	nodes := &corev1.NodeList{}
 	err := r.List(ctx, nodes)
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+
+	if names, ok := imageJob.GetAnnotations()[controllerUtils.ImageJobNodesAnnotationKey]; ok {
+		nodes.Items = selectNamedNodes(nodes.Items, strings.Split(names, ","))
+	}
+
+	if !slices.Contains(filterOpts.Selectors, defaultFilterLabel) {
+		filterOpts.Selectors = append(filterOpts.Selectors, defaultFilterLabel)
+	}
+
+	switch filterOpts.Type {
+	case "exclude":
+		return filterOutSkippedNodes(nodes, filterOpts.Selectors)
+	case "include":
+		return selectIncludedNodes(nodes, filterOpts.Selectors)
+	default:
+		return nil, 0, errors.Errorf("invalid node filter option")
 	}
+}
 
+func (r *Reconciler) handleNewJob(ctx context.Context, imageJob *eraserv1.ImageJob) error {
 	template := corev1.PodTemplate{}
-	err = r.Get(ctx,
+	err := r.Get(ctx,
 		types.NamespacedName{
 			Namespace: eraserUtils.GetNamespace(),
 			Name:      imageJob.GetName(),
@@ -292,61 +445,116 @@ func (r *Reconciler) handleNewJob(ctx context.Context, imageJob *eraserv1.ImageJ
 		return err
 	}
 
+	eraserConfig, err := r.eraserConfig.Read()
+	if err != nil {
+		return err
+	}
+	log.V(1).Info("configuration used", "manager", eraserConfig.Manager, "components", eraserConfig.Components)
+
+	if eraserConfig.Manager.ProtectWorkloadImages {
+		if err := r.protectWorkloadImages(ctx, imageJob, &template); err != nil {
+			return err
+		}
+	}
+
+	filterOpts := eraserConfig.Manager.NodeFilter
+	nodeList, skipped, err := r.selectNodes(ctx, imageJob, filterOpts)
+	if err != nil {
+		return err
+	}
+
+	targetNodes := make([]string, 0, len(nodeList))
+	for i := range nodeList {
+		targetNodes = append(targetNodes, nodeList[i].Name)
+	}
+
+	// the rollout is limited to the nodes selected now, so that its total
+	// does not change as nodes join the cluster
 	imageJob.Status = eraserv1.ImageJobStatus{
-		Desired:   len(nodes.Items),
-		Succeeded: 0,
-		Skipped:   0, // placeholder, updated below
-		Failed:    0,
-		Phase:     eraserv1.PhaseRunning,
+		Desired:     len(nodeList) + skipped,
+		Succeeded:   0,
+		Skipped:     skipped,
+		Failed:      0,
+		Phase:       eraserv1.PhaseRunning,
+		TargetNodes: targetNodes,
 	}
+	message := setScheduledConditions(imageJob, len(nodeList), skipped)
+	r.recorder.Event(imageJob, corev1.EventTypeNormal, reasonNodesSelected, message)
 
-	skipped := 0
-	var nodeList []corev1.Node
+	if err := r.updateJobStatus(ctx, imageJob); err != nil {
+		return err
+	}
+
+	limit, err := rolloutLimit(eraserConfig.Manager.ImageJob.Rollout, imageJob.Status.Desired)
+	if err != nil {
+		return err
+	}
 
+	_, unfit, err := r.startPods(ctx, imageJob, &template, nodeList, limit)
+	if err != nil {
+		return err
+	}
+	return r.recordUnfitNodes(ctx, imageJob, unfit)
+}
+
+// recordUnfitNodes adds unfit to the nodes the pods of imageJob do not fit
+// on, so that they are reported once and not tried again.
+func (r *Reconciler) recordUnfitNodes(ctx context.Context, imageJob *eraserv1.ImageJob, unfit []string) error {
+	if len(unfit) == 0 {
+		return nil
+	}
+	imageJob.Status.UnfitNodes = append(imageJob.Status.UnfitNodes, unfit...)
+	return r.updateJobStatus(ctx, imageJob)
+}
+
+// startPods starts a pod from template on up to limit of the given nodes,
+// without waiting for them. It returns how many nodes were left for later
+// because the limit was reached, and the nodes the pod does not fit on.
+func (r *Reconciler) startPods(ctx context.Context, imageJob *eraserv1.ImageJob, template *corev1.PodTemplate, nodeList []corev1.Node, limit int) (int, []string, error) {
 	log := log.WithValues("job", imageJob.Name)
 
 	env := []corev1.EnvVar{
 		{Name: "NODE_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
 	}
 
-	eraserConfig, err := r.eraserConfig.Read()
+	exclusions, err := r.imageExclusions(ctx)
 	if err != nil {
-		return err
-	}
-	log.V(1).Info("configuration used", "manager", eraserConfig.Manager, "components", eraserConfig.Components)
-
-	filterOpts := eraserConfig.Manager.NodeFilter
-	if !slices.Contains(filterOpts.Selectors, defaultFilterLabel) {
-		filterOpts.Selectors = append(filterOpts.Selectors, defaultFilterLabel)
+		return 0, nil, err
 	}
 
-	switch filterOpts.Type {
-	case "exclude":
-		nodeList, skipped, err = filterOutSkippedNodes(nodes, filterOpts.Selectors)
+	// the rules are stored before any pod mounts them
+	ruleKeys := make([]string, len(nodeList))
+	rules := make(map[string]string)
+	for i := range nodeList {
+		key, value, err := exclusionRules(exclusions, &nodeList[i])
 		if err != nil {
-			return err
+			return 0, nil, err
 		}
-	case "include":
-		nodeList, skipped, err = selectIncludedNodes(nodes, filterOpts.Selectors)
-		if err != nil {
-			return err
+		if key != "" {
+			ruleKeys[i] = key
+			rules[key] = value
 		}
-	default:
-		return errors.Errorf("invalid node filter option")
 	}
-
-	imageJob.Status.Skipped = skipped
-	if err := r.updateJobStatus(ctx, imageJob); err != nil {
-		return err
+	if len(rules) > 0 {
+		if err := r.storeExclusionRules(ctx, imageJob, rules); err != nil {
+			return 0, nil, err
+		}
 	}
 
-	var namespacedNames []types.NamespacedName
+	started := 0
+	pending := 0
+	var unfit []string
 	podSpecTemplate := template.Template.Spec
+	watermarks := controllerUtils.UsesWatermarks(&podSpecTemplate)
 	for i := range nodeList {
 		log := log.WithValues("node", nodeList[i].Name)
+
 		podSpec, err := copyAndFillTemplateSpec(&podSpecTemplate, env, &nodeList[i])
 		if err != nil {
-			return err
+			return 0, nil, err
+		}
+		if ruleKeys[i] != "" {
+			mountExclusionRules(podSpec, imageJob, ruleKeys[i])
 		}
 
 		containerName := podSpec.Containers[0].Name
@@ -368,36 +576,65 @@ func (r *Reconciler) handleNewJob(ctx context.Context, imageJob *eraserv1.ImageJ
 		fitness := checkNodeFitness(pod, &nodeList[i])
 		if !fitness {
 			log.Info(containerName + " pod does not fit on node, skipping")
+			message := fmt.Sprintf("%s pod of ImageJob %s does not fit on node %s, skipping it", containerName, imageJob.Name, nodeName)
+			r.recorder.Event(imageJob, corev1.EventTypeWarning, reasonPodDoesNotFit, message)
+			r.recorder.Event(nodeReference(nodeName), corev1.EventTypeWarning, reasonPodDoesNotFit, message)
+			unfit = append(unfit, nodeName)
+			continue
+		}
+
+		if started >= limit {
+			pending++
 			continue
 		}
 
+		if watermarks {
+			// without the stats, the eraser fails and reports why
+			if fs, err := r.stats.ImageFs(ctx, nodeName); err != nil {
+				log.Error(err, "unable to read image filesystem usage")
+			} else {
+				for i := range pod.Spec.Containers {
+					if pod.Spec.Containers[i].Name == eraserContainerName {
+						pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, controllerUtils.ImageFsEnv(fs)...)
+					}
+				}
+			}
+		}
+
 		err = r.Create(ctx, pod)
 		if err != nil {
-			return err
+			return 0, nil, err
 		}
 		log.Info("Started "+containerName+" pod on node", "nodeName", nodeName)
-		namespacedNames = append(namespacedNames, types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace})
-	}
-
-	for _, namespacedName := range namespacedNames {
-		if err := wait.PollImmediate(time.Nanosecond, time.Minute*5, r.isPodReady(ctx, namespacedName)); err != nil {
-			log.Error(err, "timed out waiting for pod to leave pending state", "pod NamespacedName", namespacedName)
-		}
+		started++
 	}
 
-	return nil
+	return pending, unfit, nil
 }
 
-func (r *Reconciler) isPodReady(ctx context.Context, namespacedName types.NamespacedName) wait.ConditionFunc {
-	return func() (bool, error) {
-		currentPod := &corev1.Pod{}
+// rolloutLimit returns how many pods of a job on total nodes may run at
+// once. A limit of zero means no limit.
+func rolloutLimit(cfg eraserv1alpha1.RolloutConfig, total int) (int, error) {
+	limit, err := intstr.GetScaledValueFromIntOrPercent(&cfg.MaxConcurrent, total, true)
+	if err != nil {
+		return 0, fmt.Errorf("invalid imageJob.rollout.maxConcurrent: %w", err)
+	}
 
-		if err := r.Get(ctx, namespacedName, currentPod); err != nil {
-			return false, client.IgnoreNotFound(err)
-		}
+	if limit <= 0 || limit > total {
+		return total, nil
+	}
+	return limit, nil
+}
 
-		return currentPod.Status.Phase != corev1.PodPhase(corev1.PodPending), nil
+// rolloutAborted reports whether no more pods should be started because the
+// ratio of successful pods fell below successRatio. It is only checked once
+// at least one batch of limit pods has finished.
+func rolloutAborted(cfg eraserv1alpha1.RolloutConfig, successRatio float64, succeeded, failed, limit int) bool {
+	finished := succeeded + failed
+	if !cfg.AbortOnFailure || failed == 0 || finished < limit {
+		return false
 	}
+	return float64(succeeded)/float64(finished) < successRatio
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -408,13 +645,116 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
-func podsComplete(podList []corev1.Pod) bool {
-	for i := range podList {
-		if podList[i].Status.Phase == corev1.PodRunning || podList[i].Status.Phase == corev1.PodPending {
-			return false
+// nodeResults collects the results reported by the eraser container of each
+// pod. Once the results grow past maxNodeResultsSize, only the node name is
+// kept for the remaining nodes.
+func nodeResults(pods []corev1.Pod) []eraserv1.NodeResult {
+	results := make([]eraserv1.NodeResult, 0, len(pods))
+	for i := range pods {
+		if result, ok := nodeResult(&pods[i]); ok {
+			results = append(results, result)
 		}
 	}
-	return true
+
+	if len(results) == 0 {
+		return nil
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Node < results[j].Node })
+
+	size := 0
+	for i := range results {
+		data, err := json.Marshal(results[i])
+		if err == nil && size+len(data) <= maxNodeResultsSize {
+			size += len(data)
+			continue
+		}
+
+		results[i] = eraserv1.NodeResult{
+			Node:         results[i].Node,
+			DryRun:       results[i].DryRun,
+			RemovedCount: results[i].RemovedCount,
+			RemovedBytes: results[i].RemovedBytes,
+			Error:        results[i].Error,
+			Exclusions:   results[i].Exclusions,
+			Quarantined:  results[i].Quarantined,
+			Scans:        results[i].Scans,
+			Truncated:    true,
+		}
+	}
+
+	return results
+}
+
+// nodeResult returns the result reported by the eraser container of pod,
+// with the ImageExclusion counts reported by the collector and the outcome
+// reported by each scanner.
+func nodeResult(pod *corev1.Pod) (eraserv1.NodeResult, bool) {
+	result, ok := eraserResult(pod)
+	if ok {
+		result.Exclusions = mergeExclusions(result.Exclusions, collectorExclusions(pod))
+	}
+	if scans := scanResults(pod); len(scans) > 0 {
+		result.Scans = scans
+		ok = true
+	}
+	return result, ok
+}
+
+// scanResults returns the outcomes reported by the scanner containers of pod,
+// which are the containers other than the eraser and the collector.
+func scanResults(pod *corev1.Pod) []eraserv1.ScanResult {
+	var scans []eraserv1.ScanResult
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if status.Name == eraserContainerName || status.Name == collectorContainerName || terminated == nil || terminated.Message == "" {
+			continue
+		}
+
+		var result eraserv1.NodeResult
+		if err := json.Unmarshal([]byte(terminated.Message), &result); err != nil {
+			log.Info("unable to parse scanner result", "pod", pod.Name, "node", pod.Spec.NodeName, "container", status.Name)
+			continue
+		}
+		scans = append(scans, result.Scans...)
+	}
+
+	sort.Slice(scans, func(i, j int) bool { return scans[i].Scanner < scans[j].Scanner })
+	return scans
+}
+
+// eraserResult returns the result reported by the eraser container of pod.
+// If the eraser failed without reporting one, the result only carries the
+// error.
+func eraserResult(pod *corev1.Pod) (eraserv1.NodeResult, bool) {
+	result := eraserv1.NodeResult{Node: pod.Spec.NodeName}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if status.Name != eraserContainerName || terminated == nil {
+			continue
+		}
+
+		if terminated.Message != "" {
+			if err := json.Unmarshal([]byte(terminated.Message), &result); err == nil {
+				result.Node = pod.Spec.NodeName
+				return result, true
+			}
+			log.Info("unable to parse eraser result", "pod", pod.Name, "node", pod.Spec.NodeName)
+		}
+
+		if terminated.ExitCode != 0 {
+			result.Error = fmt.Sprintf("eraser exited with code %d: %s", terminated.ExitCode, terminated.Reason)
+			return result, true
+		}
+	}
+
+	if pod.Status.Phase == corev1.PodFailed && pod.Status.Reason != "" {
+		result.Error = fmt.Sprintf("%s: %s", pod.Status.Reason, pod.Status.Message)
+		return result, true
+	}
+
+	return result, false
 }
 
 func (r *Reconciler) updateJobStatus(ctx context.Context, imageJob *eraserv1.ImageJob) error {
@@ -426,6 +766,30 @@ func (r *Reconciler) updateJobStatus(ctx context.Context, imageJob *eraserv1.Ima
 	return nil
 }
 
+// selectNamedNodes returns the nodes whose name is in names.
+// remainingNodes returns the target nodes of a job that are not in seen.
+// Nodes that joined after the job started are not targets, and targets
+// removed since are not returned, so they count as not succeeded.
+func remainingNodes(nodes []corev1.Node, target []string, seen map[string]bool) []corev1.Node {
+	remaining := make([]corev1.Node, 0, len(target))
+	for i := range nodes {
+		if !seen[nodes[i].Name] && slices.Contains(target, nodes[i].Name) {
+			remaining = append(remaining, nodes[i])
+		}
+	}
+	return remaining
+}
+
+func selectNamedNodes(nodes []corev1.Node, names []string) []corev1.Node {
+	selected := make([]corev1.Node, 0, len(names))
+	for i := range nodes {
+		if slices.Contains(names, nodes[i].Name) {
+			selected = append(selected, nodes[i])
+		}
+	}
+	return selected
+}
+
 func selectIncludedNodes(nodes *corev1.NodeList, includeNodesSelectors []string) ([]corev1.Node, int, error) {
 	skipped := 0
 	nodeList := make([]corev1.Node, 0, len(nodes.Items))
@@ -517,32 +881,54 @@ func copyAndFillTemplateSpec(templateSpecTemplate *corev1.PodSpec, env []corev1.
 	templateSpec := templateSpecTemplate.DeepCopy()
 	templateSpec.Tolerations = defaultTolerations
 
-	eraserImg := &templateSpec.Containers[0]
-	eraserImg.Args = append(eraserImg.Args, args...)
-	eraserImg.VolumeMounts = append(eraserImg.VolumeMounts, volumeMounts...)
-	eraserImg.Env = append(eraserImg.Env, env...)
-
-	if len(templateSpec.Containers) > 1 {
-		collectorImg := &templateSpec.Containers[1]
-		collectorImg.Args = append(collectorImg.Args, args...)
-		collectorImg.VolumeMounts = append(collectorImg.VolumeMounts, volumeMounts...)
-		collectorImg.Env = append(collectorImg.Env, env...)
-	}
-
-	if len(templateSpec.Containers) > 2 {
-		scannerImg := &templateSpec.Containers[2]
-		scannerImg.VolumeMounts = append(scannerImg.VolumeMounts, volumeMounts...)
-		scannerImg.Env = append(scannerImg.Env,
-			corev1.EnvVar{
-				Name:  eraserUtils.EnvEraserContainerRuntime,
-				Value: runtimeName,
-			},
-			corev1.EnvVar{
-				Name:  controllerUtils.EnvVarContainerdNamespaceKey,
-				Value: controllerUtils.EnvVarContainerdNamespaceValue,
-			},
-		)
-		scannerImg.Env = append(scannerImg.Env, env...)
+	for i := range templateSpec.Containers {
+		container := &templateSpec.Containers[i]
+		switch container.Name {
+		case eraserContainerName, collectorContainerName:
+			container.Args = append(container.Args, args...)
+			container.VolumeMounts = append(container.VolumeMounts, volumeMounts...)
+		default:
+			// every other container is a scanner
+			container.VolumeMounts = append(container.VolumeMounts, volumeMounts...)
+			container.Env = append(container.Env,
+				corev1.EnvVar{
+					Name:  eraserUtils.EnvEraserContainerRuntime,
+					Value: runtimeName,
+				},
+				corev1.EnvVar{
+					Name:  controllerUtils.EnvVarContainerdNamespaceKey,
+					Value: controllerUtils.EnvVarContainerdNamespaceValue,
+				},
+			)
+		}
+		container.Env = append(container.Env, env...)
+	}
+
+	// the eraser records when it first saw each image on the node
+	stateType := corev1.HostPathDirectoryOrCreate
+	for i := range templateSpec.Containers {
+		if templateSpec.Containers[i].Name != eraserContainerName {
+			continue
+		}
+		templateSpec.Containers[i].Args = append(templateSpec.Containers[i].Args, "--state-dir="+eraserUtils.EraserStateDir)
+		templateSpec.Containers[i].VolumeMounts = append(templateSpec.Containers[i].VolumeMounts, corev1.VolumeMount{MountPath: eraserUtils.EraserStateDir, Name: stateVolumeName})
+		volumes = append(volumes, corev1.Volume{
+			Name:         stateVolumeName,
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: eraserUtils.EraserStateDir, Type: &stateType}},
+		})
+	}
+
+	if len(node.Labels) > 0 {
+		nodeLabels, err := json.Marshal(node.Labels)
+		if err != nil {
+			return nil, err
+		}
+		for i := range templateSpec.Containers {
+			templateSpec.Containers[i].Env = append(templateSpec.Containers[i].Env, corev1.EnvVar{
+				Name:  eraserUtils.EnvNodeLabels,
+				Value: string(nodeLabels),
+			})
+		}
 	}
 
 	secrets := os.Getenv("ERASER_PULL_SECRET_NAMES")
@@ -0,0 +1,297 @@
+package imagejob
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	controllerUtils "github.com/Azure/eraser/controllers/util"
+	eraserUtils "github.com/Azure/eraser/pkg/utils"
+)
+
+func TestRolloutLimit(t *testing.T) {
+	testCases := []struct {
+		name          string
+		maxConcurrent intstr.IntOrString
+		total         int
+		limit         int
+		err           bool
+	}{
+		{
+			name:  "unset",
+			total: 10,
+			limit: 10,
+		},
+		{
+			name:          "count",
+			maxConcurrent: intstr.FromInt(3),
+			total:         10,
+			limit:         3,
+		},
+		{
+			name:          "count above total",
+			maxConcurrent: intstr.FromInt(30),
+			total:         10,
+			limit:         10,
+		},
+		{
+			name:          "percentage",
+			maxConcurrent: intstr.FromString("25%"),
+			total:         10,
+			limit:         3,
+		},
+		{
+			name:          "percentage of few nodes",
+			maxConcurrent: intstr.FromString("10%"),
+			total:         3,
+			limit:         1,
+		},
+		{
+			name:          "zero percent",
+			maxConcurrent: intstr.FromString("0%"),
+			total:         10,
+			limit:         10,
+		},
+		{
+			name:          "no nodes",
+			maxConcurrent: intstr.FromInt(3),
+			total:         0,
+			limit:         0,
+		},
+		{
+			name:          "invalid",
+			maxConcurrent: intstr.FromString("half"),
+			total:         10,
+			err:           true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			limit, err := rolloutLimit(eraserv1alpha1.RolloutConfig{MaxConcurrent: tc.maxConcurrent}, tc.total)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got limit %d", limit)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if limit != tc.limit {
+				t.Errorf("unexpected limit: expected: %d, got: %d", tc.limit, limit)
+			}
+		})
+	}
+}
+
+func TestRolloutAborted(t *testing.T) {
+	testCases := []struct {
+		name           string
+		abortOnFailure bool
+		successRatio   float64
+		succeeded      int
+		failed         int
+		limit          int
+		aborted        bool
+	}{
+		{
+			name:           "below ratio",
+			abortOnFailure: true,
+			successRatio:   0.8,
+			succeeded:      2,
+			failed:         2,
+			limit:          4,
+			aborted:        true,
+		},
+		{
+			name:         "abort disabled",
+			successRatio: 0.8,
+			succeeded:    2,
+			failed:       2,
+			limit:        4,
+		},
+		{
+			name:           "first batch not finished",
+			abortOnFailure: true,
+			successRatio:   0.8,
+			succeeded:      1,
+			failed:         2,
+			limit:          4,
+		},
+		{
+			name:           "at ratio",
+			abortOnFailure: true,
+			successRatio:   0.8,
+			succeeded:      8,
+			failed:         2,
+			limit:          4,
+		},
+		{
+			name:           "no failures",
+			abortOnFailure: true,
+			successRatio:   1,
+			succeeded:      4,
+			limit:          4,
+		},
+		{
+			name:           "all failed",
+			abortOnFailure: true,
+			successRatio:   0.1,
+			failed:         5,
+			limit:          5,
+			aborted:        true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := eraserv1alpha1.RolloutConfig{AbortOnFailure: tc.abortOnFailure}
+			if aborted := rolloutAborted(cfg, tc.successRatio, tc.succeeded, tc.failed, tc.limit); aborted != tc.aborted {
+				t.Errorf("unexpected result: expected: %t, got: %t", tc.aborted, aborted)
+			}
+		})
+	}
+}
+
+func TestCopyAndFillTemplateSpec(t *testing.T) {
+	template := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: collectorContainerName},
+			{Name: eraserContainerName},
+			{Name: "trivy-scanner"},
+			{Name: "extra-scanner"},
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{ContainerRuntimeVersion: "containerd://1.6.0"},
+		},
+	}
+	env := []corev1.EnvVar{{Name: "NODE_NAME", Value: node.Name}}
+
+	spec, err := copyAndFillTemplateSpec(template, env, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hasMount := func(c *corev1.Container) bool {
+		for _, m := range c.VolumeMounts {
+			if m.MountPath == eraserUtils.ContainerdPath {
+				return true
+			}
+		}
+		return false
+	}
+	envValue := func(c *corev1.Container, name string) string {
+		for _, e := range c.Env {
+			if e.Name == name {
+				return e.Value
+			}
+		}
+		return ""
+	}
+
+	for i := range spec.Containers {
+		c := &spec.Containers[i]
+		t.Run(c.Name, func(t *testing.T) {
+			if !hasMount(c) {
+				t.Errorf("expected the runtime socket to be mounted")
+			}
+			if v := envValue(c, "NODE_NAME"); v != node.Name {
+				t.Errorf("unexpected NODE_NAME: expected: %s, got: %s", node.Name, v)
+			}
+
+			runtime := envValue(c, eraserUtils.EnvEraserContainerRuntime)
+			namespace := envValue(c, controllerUtils.EnvVarContainerdNamespaceKey)
+			if c.Name == collectorContainerName || c.Name == eraserContainerName {
+				if !reflect.DeepEqual(c.Args[:1], []string{"--runtime=containerd"}) {
+					t.Errorf("unexpected args: expected: --runtime=containerd, got: %v", c.Args)
+				}
+				if runtime != "" || namespace != "" {
+					t.Errorf("unexpected scanner env in %s", c.Name)
+				}
+				return
+			}
+			if len(c.Args) > 0 {
+				t.Errorf("unexpected args: expected: none, got: %v", c.Args)
+			}
+			if runtime != eraserUtils.RuntimeContainerd {
+				t.Errorf("unexpected %s: expected: %s, got: %s", eraserUtils.EnvEraserContainerRuntime, eraserUtils.RuntimeContainerd, runtime)
+			}
+			if namespace != controllerUtils.EnvVarContainerdNamespaceValue {
+				t.Errorf("unexpected %s: expected: %s, got: %s", controllerUtils.EnvVarContainerdNamespaceKey, controllerUtils.EnvVarContainerdNamespaceValue, namespace)
+			}
+		})
+	}
+}
+
+func TestRemainingNodes(t *testing.T) {
+	nodes := func(names ...string) []corev1.Node {
+		list := make([]corev1.Node, 0, len(names))
+		for _, name := range names {
+			list = append(list, corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}
+		return list
+	}
+	names := func(list []corev1.Node) []string {
+		out := make([]string, 0, len(list))
+		for i := range list {
+			out = append(out, list[i].Name)
+		}
+		return out
+	}
+
+	testCases := []struct {
+		name      string
+		nodes     []corev1.Node
+		target    []string
+		seen      map[string]bool
+		remaining []string
+	}{
+		{
+			name:      "not started",
+			nodes:     nodes("a", "b", "c"),
+			target:    []string{"a", "b", "c"},
+			remaining: []string{"a", "b", "c"},
+		},
+		{
+			name:      "started and unfit nodes",
+			nodes:     nodes("a", "b", "c"),
+			target:    []string{"a", "b", "c"},
+			seen:      map[string]bool{"a": true, "c": true},
+			remaining: []string{"b"},
+		},
+		{
+			name:      "node joined",
+			nodes:     nodes("a", "b", "new"),
+			target:    []string{"a", "b"},
+			seen:      map[string]bool{"a": true},
+			remaining: []string{"b"},
+		},
+		{
+			name:      "node removed",
+			nodes:     nodes("a"),
+			target:    []string{"a", "b"},
+			remaining: []string{"a"},
+		},
+		{
+			name:      "no target",
+			nodes:     nodes("a", "b"),
+			remaining: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if remaining := names(remainingNodes(tc.nodes, tc.target, tc.seen)); !reflect.DeepEqual(remaining, tc.remaining) {
+				t.Errorf("unexpected remaining nodes: expected: %v, got: %v", tc.remaining, remaining)
+			}
+		})
+	}
+}
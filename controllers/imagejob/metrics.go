@@ -0,0 +1,53 @@
+package imagejob
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	eraserv1 "github.com/Azure/eraser/api/v1"
+	controllerUtils "github.com/Azure/eraser/controllers/util"
+	"github.com/Azure/eraser/pkg/metrics"
+)
+
+// jobOwners are the owners of ImageJobs reported in the owner label of the
+// metrics. Any other owner is reported as otherJobOwner, so that the label
+// stays bounded.
+var jobOwners = map[string]bool{
+	"imagelist-controller": true,
+	"imagecollector":       true,
+	"diskpressure":         true,
+}
+
+const otherJobOwner = "other"
+
+// recordMetrics records imageJob, which has finished, and the results reported
+// by its pods in the metrics served by the manager.
+func recordMetrics(imageJob *eraserv1.ImageJob, pods []corev1.Pod) {
+	owner := imageJob.Labels[controllerUtils.ImageJobOwnerLabelKey]
+	if !jobOwners[owner] {
+		owner = otherJobOwner
+	}
+
+	result := metrics.ResultSucceeded
+	if imageJob.Status.Phase == eraserv1.PhaseFailed {
+		result = metrics.ResultFailed
+	}
+
+	status := &imageJob.Status
+	metrics.RecordImageJob(owner, result, time.Since(imageJob.CreationTimestamp.Time), status.Succeeded, status.Failed, status.Skipped)
+
+	for i := range pods {
+		nodeResult, ok := nodeResult(&pods[i])
+		if !ok {
+			continue
+		}
+
+		if !nodeResult.DryRun {
+			metrics.RecordRemoval(nodeResult.Node, nodeResult.RemovedCount, nodeResult.RemovedBytes, int64(len(nodeResult.Errors)))
+		}
+		for _, scan := range nodeResult.Scans {
+			metrics.RecordScan(scan.Scanner, scan.Scanned, scan.NonCompliant, scan.Failed, scan.Duration.Duration, scan.Error != "")
+		}
+	}
+}
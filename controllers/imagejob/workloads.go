@@ -0,0 +1,216 @@
+package imagejob
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/docker/distribution/reference"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	eraserv1 "github.com/Azure/eraser/api/v1"
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	eraserUtils "github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	// workloadImagesSuffix names the configmaps holding the images referenced
+	// by workloads for an ImageJob.
+	workloadImagesSuffix = "-workload-images"
+	// maxWorkloadImagesBytes bounds the exclusion list stored in each of
+	// these configmaps, well below the 1 MiB limit of a configmap.
+	maxWorkloadImagesBytes = 900 * 1024
+)
+
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=list
+//+kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=list
+
+// protectWorkloadImages stores the images referenced by workloads in
+// configmaps owned by imageJob, and mounts them in the pods of template as
+// exclusion lists. The images are split across as many configmaps as needed
+// to stay within the size limit of a configmap.
+func (r *Reconciler) protectWorkloadImages(ctx context.Context, imageJob *eraserv1.ImageJob, template *corev1.PodTemplate) error {
+	images, err := r.workloadImages(ctx)
+	if err != nil {
+		return err
+	}
+
+	chunks := chunkImages(images, maxWorkloadImagesBytes)
+	spec := &template.Template.Spec
+	mounted := make(map[string]bool, len(spec.Volumes))
+	for i := range spec.Volumes {
+		mounted[spec.Volumes[i].Name] = true
+	}
+
+	changed := false
+	for i, chunk := range chunks {
+		data, err := json.Marshal(eraserUtils.ExclusionList{Excluded: chunk})
+		if err != nil {
+			return err
+		}
+
+		name := imageJob.Name + workloadImagesSuffix
+		if i > 0 {
+			name += "-" + strconv.Itoa(i)
+		}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: eraserUtils.GetNamespace(),
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(imageJob, eraserv1alpha1.GroupVersion.WithKind("ImageJob")),
+				},
+			},
+			Data: map[string]string{"excluded.json": string(data)},
+		}
+
+		err = r.Create(ctx, configMap)
+		if apierrors.IsAlreadyExists(err) {
+			err = r.Update(ctx, configMap)
+		}
+		if err != nil {
+			return err
+		}
+
+		if mounted[name] {
+			continue
+		}
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+			},
+		})
+		for j := range spec.Containers {
+			spec.Containers[j].VolumeMounts = append(spec.Containers[j].VolumeMounts, corev1.VolumeMount{MountPath: "exclude-" + name, Name: name})
+		}
+		changed = true
+	}
+	log.Info("protecting images referenced by workloads", "job", imageJob.Name, "images", len(images), "configmaps", len(chunks))
+
+	if !changed {
+		return nil
+	}
+	return r.Update(ctx, template)
+}
+
+// chunkImages splits images into lists whose exclusion list JSON is at most
+// maxBytes long. There is always at least one list, and an image longer than
+// maxBytes gets a list of its own.
+func chunkImages(images []string, maxBytes int) [][]string {
+	// {"excluded":[]}, and the quotes and comma around each image
+	const overhead, perImage = 15, 3
+
+	chunks := [][]string{{}}
+	size := overhead
+	for _, img := range images {
+		last := len(chunks) - 1
+		if len(chunks[last]) > 0 && size+len(img)+perImage > maxBytes {
+			chunks = append(chunks, []string{})
+			last++
+			size = overhead
+		}
+		chunks[last] = append(chunks[last], img)
+		size += len(img) + perImage
+	}
+
+	return chunks
+}
+
+// workloadImages returns the images referenced by the pod templates of the
+// Deployments, StatefulSets, DaemonSets, Jobs and CronJobs in the cluster.
+func (r *Reconciler) workloadImages(ctx context.Context) ([]string, error) {
+	var specs []*corev1.PodSpec
+
+	deployments := &appsv1.DeploymentList{}
+	if err := r.apiReader.List(ctx, deployments); err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		specs = append(specs, &deployments.Items[i].Spec.Template.Spec)
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := r.apiReader.List(ctx, statefulSets); err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		specs = append(specs, &statefulSets.Items[i].Spec.Template.Spec)
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := r.apiReader.List(ctx, daemonSets); err != nil {
+		return nil, err
+	}
+	for i := range daemonSets.Items {
+		specs = append(specs, &daemonSets.Items[i].Spec.Template.Spec)
+	}
+
+	jobs := &batchv1.JobList{}
+	if err := r.apiReader.List(ctx, jobs); err != nil {
+		return nil, err
+	}
+	for i := range jobs.Items {
+		specs = append(specs, &jobs.Items[i].Spec.Template.Spec)
+	}
+
+	cronJobs := &batchv1.CronJobList{}
+	if err := r.apiReader.List(ctx, cronJobs); err != nil {
+		return nil, err
+	}
+	for i := range cronJobs.Items {
+		specs = append(specs, &cronJobs.Items[i].Spec.JobTemplate.Spec.Template.Spec)
+	}
+
+	return podSpecImages(specs), nil
+}
+
+// podSpecImages returns the images of the containers in specs, in the forms
+// the eraser compares against the names and digests of images on a node.
+func podSpecImages(specs []*corev1.PodSpec) []string {
+	set := make(map[string]struct{})
+	for _, spec := range specs {
+		for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+			for i := range containers {
+				for _, img := range imageReferences(containers[i].Image) {
+					set[img] = struct{}{}
+				}
+			}
+		}
+	}
+
+	images := make([]string, 0, len(set))
+	for img := range set {
+		images = append(images, img)
+	}
+	sort.Strings(images)
+
+	return images
+}
+
+// imageReferences returns the fully qualified name and tag of img, such as
+// docker.io/library/nginx:latest for nginx, and its digest if it has one.
+func imageReferences(img string) []string {
+	named, err := reference.ParseNormalizedNamed(img)
+	if err != nil {
+		log.V(1).Info("unable to parse workload image, keeping it as is", "image", img, "error", err.Error())
+		return []string{img}
+	}
+
+	var refs []string
+	digested, hasDigest := named.(reference.Digested)
+	if tagged, ok := named.(reference.Tagged); ok {
+		refs = append(refs, reference.TrimNamed(named).String()+":"+tagged.Tag())
+	} else if !hasDigest {
+		refs = append(refs, reference.TagNameOnly(named).String())
+	}
+	if hasDigest {
+		refs = append(refs, digested.Digest().String())
+	}
+	return refs
+}
@@ -0,0 +1,165 @@
+package imagejob
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	eraserUtils "github.com/Azure/eraser/pkg/utils"
+)
+
+const digest = "sha256:8b1a4c6ed8e2e7fa54f5d1ad35e1b0d8c8e5cd0f9f1f7c6c3b3a2f1e0d9c8b7a"
+
+func TestImageReferences(t *testing.T) {
+	testCases := []struct {
+		name string
+		img  string
+		refs []string
+	}{
+		{
+			name: "short name",
+			img:  "nginx",
+			refs: []string{"docker.io/library/nginx:latest"},
+		},
+		{
+			name: "tag",
+			img:  "nginx:1.23",
+			refs: []string{"docker.io/library/nginx:1.23"},
+		},
+		{
+			name: "registry",
+			img:  "mcr.microsoft.com/oss/kubernetes/pause:3.6",
+			refs: []string{"mcr.microsoft.com/oss/kubernetes/pause:3.6"},
+		},
+		{
+			name: "digest",
+			img:  "nginx@" + digest,
+			refs: []string{digest},
+		},
+		{
+			name: "tag and digest",
+			img:  "nginx:1.23@" + digest,
+			refs: []string{"docker.io/library/nginx:1.23", digest},
+		},
+		{
+			name: "invalid",
+			img:  "Nginx:latest",
+			refs: []string{"Nginx:latest"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if refs := imageReferences(tc.img); !reflect.DeepEqual(refs, tc.refs) {
+				t.Errorf("unexpected references: expected: %v, got: %v", tc.refs, refs)
+			}
+		})
+	}
+}
+
+func TestPodSpecImages(t *testing.T) {
+	spec := func(initImages []string, images ...string) *corev1.PodSpec {
+		s := &corev1.PodSpec{}
+		for _, img := range initImages {
+			s.InitContainers = append(s.InitContainers, corev1.Container{Image: img})
+		}
+		for _, img := range images {
+			s.Containers = append(s.Containers, corev1.Container{Image: img})
+		}
+		return s
+	}
+
+	testCases := []struct {
+		name   string
+		specs  []*corev1.PodSpec
+		images []string
+	}{
+		{
+			name:   "containers",
+			specs:  []*corev1.PodSpec{spec(nil, "nginx", "redis:7")},
+			images: []string{"docker.io/library/nginx:latest", "docker.io/library/redis:7"},
+		},
+		{
+			name:   "init containers",
+			specs:  []*corev1.PodSpec{spec([]string{"busybox"}, "nginx@"+digest)},
+			images: []string{"docker.io/library/busybox:latest", digest},
+		},
+		{
+			name:   "shared images",
+			specs:  []*corev1.PodSpec{spec(nil, "nginx"), spec(nil, "docker.io/library/nginx:latest")},
+			images: []string{"docker.io/library/nginx:latest"},
+		},
+		{
+			name:   "no workloads",
+			images: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if images := podSpecImages(tc.specs); !reflect.DeepEqual(images, tc.images) {
+				t.Errorf("unexpected images: expected: %v, got: %v", tc.images, images)
+			}
+		})
+	}
+}
+
+func TestChunkImages(t *testing.T) {
+	testCases := []struct {
+		name     string
+		images   []string
+		maxBytes int
+		chunks   [][]string
+	}{
+		{
+			name:     "no images",
+			images:   []string{},
+			maxBytes: 100,
+			chunks:   [][]string{{}},
+		},
+		{
+			name:     "one chunk",
+			images:   []string{"a:1", "b:1", "c:1"},
+			maxBytes: 100,
+			chunks:   [][]string{{"a:1", "b:1", "c:1"}},
+		},
+		{
+			name:     "exact fit",
+			images:   []string{"a:1", "b:1", "c:1"},
+			maxBytes: 33,
+			chunks:   [][]string{{"a:1", "b:1", "c:1"}},
+		},
+		{
+			name:     "split",
+			images:   []string{"a:1", "b:1", "c:1"},
+			maxBytes: 32,
+			chunks:   [][]string{{"a:1", "b:1"}, {"c:1"}},
+		},
+		{
+			name:     "image above limit",
+			images:   []string{"a:1", "docker.io/library/nginx:latest", "b:1"},
+			maxBytes: 25,
+			chunks:   [][]string{{"a:1"}, {"docker.io/library/nginx:latest"}, {"b:1"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks := chunkImages(tc.images, tc.maxBytes)
+			if !reflect.DeepEqual(chunks, tc.chunks) {
+				t.Fatalf("unexpected chunks: expected: %v, got: %v", tc.chunks, chunks)
+			}
+			for _, chunk := range chunks {
+				data, err := json.Marshal(eraserUtils.ExclusionList{Excluded: chunk})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(data) > tc.maxBytes && len(chunk) > 1 {
+					t.Errorf("chunk of %d bytes is above the limit of %d: %s", len(data), tc.maxBytes, data)
+				}
+			}
+		})
+	}
+}
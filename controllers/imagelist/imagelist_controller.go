@@ -19,17 +19,18 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 	"time"
 
 	"go.opentelemetry.io/otel/metric/global"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -45,15 +46,17 @@ import (
 	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
 	"github.com/Azure/eraser/api/v1alpha1/config"
 	"github.com/Azure/eraser/controllers/util"
-	"github.com/Azure/eraser/pkg/logger"
 	"github.com/Azure/eraser/pkg/metrics"
 	"github.com/Azure/eraser/pkg/utils"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 )
 
 const (
-	imgListPath     = "/run/eraser.sh/imagelist"
 	ownerLabelValue = "imagelist-controller"
+
+	reasonJobCreated   = "JobCreated"
+	reasonJobCompleted = "JobCompleted"
+	reasonJobFailed    = "JobFailed"
 )
 
 var (
@@ -104,6 +107,7 @@ func newReconciler(mgr manager.Manager, cfg *config.Manager) (reconcile.Reconcil
 		Client:       mgr.GetClient(),
 		scheme:       mgr.GetScheme(),
 		eraserConfig: cfg,
+		recorder:     mgr.GetEventRecorderFor("imagelist-controller"),
 	}
 
 	return rec, nil
@@ -119,6 +123,7 @@ type Reconciler struct {
 	client.Client
 	scheme       *runtime.Scheme
 	eraserConfig *config.Manager
+	recorder     record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=eraser.sh,resources=imagelists,verbs=get;list;watch;create;update;patch;delete
@@ -126,6 +131,7 @@ type Reconciler struct {
 //+kubebuilder:rbac:groups=eraser.sh,resources=imagelists/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;update;create;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -203,6 +209,16 @@ func (r *Reconciler) handleJobListEvent(ctx context.Context, imageList *eraserv1
 			if err := r.Status().Update(ctx, job); err != nil {
 				log.Info("Could not update Delete After for job " + job.Name)
 			}
+
+			message := fmt.Sprintf("ImageJob %s finished", job.Name)
+			if c := meta.FindStatusCondition(job.Status.Conditions, eraserv1.ConditionSucceeded); c != nil {
+				message = fmt.Sprintf("ImageJob %s finished: %s", job.Name, c.Message)
+			}
+			if job.Status.Phase == eraserv1.PhaseCompleted {
+				r.recorder.Event(imageList, corev1.EventTypeNormal, reasonJobCompleted, message)
+			} else {
+				r.recorder.Event(imageList, corev1.EventTypeWarning, reasonJobFailed, message)
+			}
 			return ctrl.Result{}, nil
 		}
 
@@ -238,6 +254,20 @@ func (r *Reconciler) handleJobDeletion(ctx context.Context, job *eraserv1.ImageJ
 }
 
 func (r *Reconciler) handleImageListEvent(ctx context.Context, imageList *eraserv1.ImageList) (ctrl.Result, error) {
+	eraserConfig, err := r.eraserConfig.Read()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	blackout, err := util.BlackoutRemaining(eraserConfig.Manager.Scheduling, time.Now())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if blackout > 0 {
+		log.Info("Delaying imagejob during blackout window", "remaining", blackout)
+		return ctrl.Result{RequeueAfter: blackout}, nil
+	}
+
 	imgListJSON, err := json.Marshal(imageList.Spec.Images)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("marshal image list: %w", err)
@@ -255,74 +285,23 @@ func (r *Reconciler) handleImageListEvent(ctx context.Context, imageList *eraser
 		return ctrl.Result{}, fmt.Errorf("create configmap: %w", err)
 	}
 
-	configName := configMap.Name
-	args := []string{
-		"--imagelist=" + filepath.Join(imgListPath, "images"),
-		"--log-level=" + logger.GetLevel(),
+	var args []string
+	if prune := imageList.Spec.Prune; prune != nil {
+		if prune.UnusedFor != nil {
+			args = append(args, "--prune-unused-for="+prune.UnusedFor.Duration.String())
+		}
+		if prune.OlderThan != nil {
+			args = append(args, "--prune-older-than="+prune.OlderThan.Duration.String())
+		}
 	}
 
-	eraserConfig, err := r.eraserConfig.Read()
+	eraser, err := util.EraserContainer(&eraserConfig, imageList.Spec.DryRun, args...)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	eraserContainerCfg := eraserConfig.Components.Eraser
-	imageCfg := eraserContainerCfg.Image
-	image := fmt.Sprintf("%s:%s", imageCfg.Repo, imageCfg.Tag)
-
-	pullSecrets := []corev1.LocalObjectReference{}
-	for _, secret := range eraserConfig.Manager.PullSecrets {
-		pullSecrets = append(pullSecrets, corev1.LocalObjectReference{Name: secret})
-	}
-
-	jobTemplate := corev1.PodTemplateSpec{
-		Spec: corev1.PodSpec{
-			Volumes: []corev1.Volume{
-				{
-					Name: configName,
-					VolumeSource: corev1.VolumeSource{
-						ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: configName}},
-					},
-				},
-			},
-			ImagePullSecrets:  pullSecrets,
-			RestartPolicy:     corev1.RestartPolicyNever,
-			PriorityClassName: eraserConfig.Manager.PriorityClassName,
-			Containers: []corev1.Container{
-				{
-					Name:            "eraser",
-					Image:           image,
-					ImagePullPolicy: corev1.PullIfNotPresent,
-					Args:            args,
-					VolumeMounts: []corev1.VolumeMount{
-						{MountPath: imgListPath, Name: configName},
-					},
-					Resources: corev1.ResourceRequirements{
-						Requests: corev1.ResourceList{
-							"cpu":    eraserContainerCfg.Request.CPU,
-							"memory": eraserContainerCfg.Request.Mem,
-						},
-						Limits: corev1.ResourceList{
-							"memory": eraserContainerCfg.Limit.Mem,
-						},
-					},
-					SecurityContext: utils.SharedSecurityContext,
-					// env vars for exporting metrics
-					Env: []corev1.EnvVar{
-						{
-							Name:  "OTEL_EXPORTER_OTLP_ENDPOINT",
-							Value: eraserConfig.Manager.OTLPEndpoint,
-						},
-						{
-							Name:  "OTEL_SERVICE_NAME",
-							Value: "eraser",
-						},
-					},
-				},
-			},
-			ServiceAccountName: "eraser-imagejob-pods",
-		},
-	}
+	jobTemplate := corev1.PodTemplateSpec{Spec: util.ImageJobPodSpec(&eraserConfig, eraser)}
+	util.MountImageList(&jobTemplate.Spec, &jobTemplate.Spec.Containers[0], configMap.Name)
 
 	job := &eraserv1.ImageJob{
 		ObjectMeta: metav1.ObjectMeta{
@@ -336,24 +315,11 @@ func (r *Reconciler) handleImageListEvent(ctx context.Context, imageList *eraser
 		},
 	}
 
-	configmapList := &corev1.ConfigMapList{}
-	if err := r.List(ctx, configmapList); err != nil {
-		log.Info("Could not get list of configmaps")
-		return reconcile.Result{}, err
-	}
-
-	exclusionMount, exclusionVolume, err := util.GetExclusionVolume(configmapList)
-	if err != nil {
+	if err := util.MountExclusions(ctx, r.Client, &jobTemplate.Spec); err != nil {
 		log.Info("Could not get exclusion mounts and volumes")
 		return reconcile.Result{}, err
 	}
 
-	for i := range jobTemplate.Spec.Containers {
-		jobTemplate.Spec.Containers[i].VolumeMounts = append(jobTemplate.Spec.Containers[i].VolumeMounts, exclusionMount...)
-	}
-
-	jobTemplate.Spec.Volumes = append(jobTemplate.Spec.Volumes, exclusionVolume...)
-
 	err = r.Create(ctx, job)
 	startTime = time.Now()
 	log.Info("creating imagejob", "job", job.Name)
@@ -387,6 +353,24 @@ func (r *Reconciler) handleImageListEvent(ctx context.Context, imageList *eraser
 		return reconcile.Result{}, err
 	}
 
+	message := fmt.Sprintf("created ImageJob %s", job.Name)
+	r.recorder.Event(imageList, corev1.EventTypeNormal, reasonJobCreated, message)
+
+	// the outcome of the previous job no longer applies
+	for _, conditionType := range []string{eraserv1.ConditionSucceeded, eraserv1.ConditionFailed, eraserv1.ConditionDegraded} {
+		meta.RemoveStatusCondition(&imageList.Status.Conditions, conditionType)
+	}
+	meta.SetStatusCondition(&imageList.Status.Conditions, metav1.Condition{
+		Type:               eraserv1.ConditionRunning,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: imageList.Generation,
+		Reason:             reasonJobCreated,
+		Message:            message,
+	})
+	if err := r.Status().Update(ctx, imageList); err != nil {
+		return reconcile.Result{}, err
+	}
+
 	return ctrl.Result{}, nil
 }
 
@@ -396,8 +380,15 @@ func (r *Reconciler) handleJobCompletion(ctx context.Context, imageList *eraserv
 	imageList.Status.Success = int64(job.Status.Succeeded)
 	imageList.Status.Failed = int64(job.Status.Failed)
 	imageList.Status.Skipped = int64(job.Status.Skipped)
+	imageList.Status.Nodes = job.Status.Nodes
 	imageList.Status.Timestamp = &now
 
+	// the list reflects the conditions of its last job
+	for _, condition := range job.Status.Conditions {
+		condition.ObservedGeneration = imageList.Generation
+		meta.SetStatusCondition(&imageList.Status.Conditions, condition)
+	}
+
 	err := r.Status().Update(ctx, imageList)
 	if err != nil {
 		return err
@@ -0,0 +1,143 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/pkg/logger"
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	// EraserContainerName is the name of the eraser container in the pods of
+	// every ImageJob.
+	EraserContainerName = "eraser"
+
+	// ImageListPath is where the images to remove are mounted in the eraser
+	// container.
+	ImageListPath = "/run/eraser.sh/imagelist"
+
+	imageJobServiceAccount = "eraser-imagejob-pods"
+)
+
+// EraserImageRef returns the image of the eraser container, which the
+// --eraser-image flag overrides.
+func EraserImageRef(cfg *v1alpha1.EraserConfig) string {
+	if *EraserImage != "" {
+		return *EraserImage
+	}
+	iCfg := cfg.Components.Eraser.Image
+	return fmt.Sprintf("%s:%s", iCfg.Repo, iCfg.Tag)
+}
+
+// ProfileArgs returns the flags enabling pprof in the containers of an
+// ImageJob.
+func ProfileArgs(cfg *v1alpha1.EraserConfig) []string {
+	profileConfig := cfg.Manager.Profile
+	return []string{
+		"--enable-pprof=" + strconv.FormatBool(profileConfig.Enabled),
+		fmt.Sprintf("--pprof-port=%d", profileConfig.Port),
+	}
+}
+
+// EraserContainer returns the eraser container of an ImageJob. The flags
+// every run needs come first, then args.
+func EraserContainer(cfg *v1alpha1.EraserConfig, dryRun bool, args ...string) (corev1.Container, error) {
+	policyArgs, err := ImagePolicyArgs(cfg.Manager.ImagePolicy)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	eraserArgs := []string{
+		"--log-level=" + logger.GetLevel(),
+		"--dry-run=" + strconv.FormatBool(dryRun || cfg.Manager.DryRun),
+	}
+	eraserArgs = append(eraserArgs, ProfileArgs(cfg)...)
+	eraserArgs = append(eraserArgs, policyArgs...)
+	eraserArgs = append(eraserArgs, args...)
+
+	eraserCfg := cfg.Components.Eraser
+	return corev1.Container{
+		Name:            EraserContainerName,
+		Image:           EraserImageRef(cfg),
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Args:            eraserArgs,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				"cpu":    eraserCfg.Request.CPU,
+				"memory": eraserCfg.Request.Mem,
+			},
+			Limits: corev1.ResourceList{
+				"memory": eraserCfg.Limit.Mem,
+			},
+		},
+		SecurityContext: utils.SharedSecurityContext,
+		// env vars for exporting metrics
+		Env: []corev1.EnvVar{
+			{
+				Name:  "OTEL_EXPORTER_OTLP_ENDPOINT",
+				Value: cfg.Manager.OTLPEndpoint,
+			},
+			{
+				Name:  "OTEL_SERVICE_NAME",
+				Value: "eraser",
+			},
+		},
+	}, nil
+}
+
+// ImageJobPodSpec returns the spec of the pods of an ImageJob running
+// containers.
+func ImageJobPodSpec(cfg *v1alpha1.EraserConfig, containers ...corev1.Container) corev1.PodSpec {
+	pullSecrets := []corev1.LocalObjectReference{}
+	for _, secret := range cfg.Manager.PullSecrets {
+		pullSecrets = append(pullSecrets, corev1.LocalObjectReference{Name: secret})
+	}
+
+	return corev1.PodSpec{
+		ImagePullSecrets:   pullSecrets,
+		RestartPolicy:      corev1.RestartPolicyNever,
+		PriorityClassName:  cfg.Manager.PriorityClassName,
+		Containers:         containers,
+		ServiceAccountName: imageJobServiceAccount,
+	}
+}
+
+// MountImageList mounts configMapName, which holds the images to remove, in
+// the eraser container of spec and points it at the list.
+func MountImageList(spec *corev1.PodSpec, container *corev1.Container, configMapName string) {
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: configMapName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: configMapName}},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{MountPath: ImageListPath, Name: configMapName})
+	container.Args = append(container.Args, "--imagelist="+filepath.Join(ImageListPath, "images"))
+}
+
+// MountExclusions mounts the exclusion configmaps of the eraser namespace in
+// every container of spec.
+func MountExclusions(ctx context.Context, c client.Client, spec *corev1.PodSpec) error {
+	configmapList := &corev1.ConfigMapList{}
+	if err := c.List(ctx, configmapList, client.InNamespace(utils.GetNamespace())); err != nil {
+		return err
+	}
+
+	exclusionMount, exclusionVolume, err := GetExclusionVolume(configmapList)
+	if err != nil {
+		return err
+	}
+
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, exclusionMount...)
+	}
+	spec.Volumes = append(spec.Volumes, exclusionVolume...)
+	return nil
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/pkg/schedule"
+)
+
+// ScheduleLocation returns the time zone in which the cron expressions of
+// cfg are evaluated.
+func ScheduleLocation(cfg v1alpha1.ScheduleConfig) (*time.Location, error) {
+	if cfg.TimeZone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scheduling time zone: %w", err)
+	}
+	return loc, nil
+}
+
+// Blackouts returns the blackout windows of cfg.
+func Blackouts(cfg v1alpha1.ScheduleConfig) ([]schedule.Window, error) {
+	loc, err := ScheduleLocation(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	windows := make([]schedule.Window, 0, len(cfg.Blackouts))
+	for _, b := range cfg.Blackouts {
+		c, err := schedule.Parse(b.Start, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout window: %w", err)
+		}
+		windows = append(windows, schedule.Window{Start: c, Duration: time.Duration(b.Duration)})
+	}
+	return windows, nil
+}
+
+// ValidateSchedule checks the time zone and cron expressions of cfg, so that
+// a configuration that can never be scheduled is rejected when it is loaded.
+func ValidateSchedule(cfg v1alpha1.ScheduleConfig) error {
+	loc, err := ScheduleLocation(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Cron != "" {
+		if _, err := schedule.Parse(cfg.Cron, loc); err != nil {
+			return err
+		}
+	}
+
+	_, err = Blackouts(cfg)
+	return err
+}
+
+// BlackoutRemaining returns how long until every blackout window of cfg is
+// closed, or zero if none is open at now. Every controller creating
+// ImageJobs waits for it, so that no removal starts during a blackout.
+func BlackoutRemaining(cfg v1alpha1.ScheduleConfig, now time.Time) (time.Duration, error) {
+	windows, err := Blackouts(cfg)
+	if err != nil {
+		return 0, err
+	}
+	return schedule.Defer(now, windows).Sub(now), nil
+}
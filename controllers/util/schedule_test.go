@@ -0,0 +1,76 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/eraser/api/v1alpha1"
+)
+
+func TestBlackoutRemaining(t *testing.T) {
+	cfg := v1alpha1.ScheduleConfig{
+		TimeZone: "UTC",
+		Blackouts: []v1alpha1.BlackoutWindow{
+			{Start: "0 8 * * MON-FRI", Duration: v1alpha1.Duration(10 * time.Hour)},
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		cfg       v1alpha1.ScheduleConfig
+		now       time.Time
+		remaining time.Duration
+		err       bool
+	}{
+		{
+			name:      "before the window",
+			cfg:       cfg,
+			now:       time.Date(2023, 3, 1, 7, 0, 0, 0, time.UTC),
+			remaining: 0,
+		},
+		{
+			name:      "inside the window",
+			cfg:       cfg,
+			now:       time.Date(2023, 3, 1, 12, 30, 0, 0, time.UTC),
+			remaining: 5*time.Hour + 30*time.Minute,
+		},
+		{
+			name:      "weekend",
+			cfg:       cfg,
+			now:       time.Date(2023, 3, 4, 12, 0, 0, 0, time.UTC),
+			remaining: 0,
+		},
+		{
+			name:      "no windows",
+			cfg:       v1alpha1.ScheduleConfig{},
+			now:       time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+			remaining: 0,
+		},
+		{
+			name: "invalid window",
+			cfg: v1alpha1.ScheduleConfig{
+				Blackouts: []v1alpha1.BlackoutWindow{{Start: "0 0 31 FEB *", Duration: v1alpha1.Duration(time.Hour)}},
+			},
+			now: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+			err: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			remaining, err := BlackoutRemaining(tc.cfg, tc.now)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if remaining != tc.remaining {
+				t.Errorf("unexpected remaining time: expected: %s, got: %s", tc.remaining, remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+// HighWatermarkFlag is the eraser flag that makes it prune images until the
+// image filesystem of its node is below the low watermark.
+const HighWatermarkFlag = "--high-watermark"
+
+// FsStats is the usage of a filesystem of a node, as reported by its kubelet.
+type FsStats struct {
+	AvailableBytes *uint64 `json:"availableBytes,omitempty"`
+	CapacityBytes  *uint64 `json:"capacityBytes,omitempty"`
+}
+
+// UsedBytes returns the bytes used on the filesystem by anything, images,
+// logs and volumes alike.
+func (s FsStats) UsedBytes() uint64 {
+	if s.CapacityBytes == nil || s.AvailableBytes == nil || *s.AvailableBytes > *s.CapacityBytes {
+		return 0
+	}
+	return *s.CapacityBytes - *s.AvailableBytes
+}
+
+// UsedPercent returns the share of the filesystem in use, in percent.
+func (s FsStats) UsedPercent() int {
+	if s.CapacityBytes == nil || *s.CapacityBytes == 0 {
+		return 0
+	}
+	return int(s.UsedBytes() * 100 / *s.CapacityBytes)
+}
+
+// summary holds the parts of the kubelet stats summary that are read.
+type summary struct {
+	Node struct {
+		Fs      *FsStats `json:"fs,omitempty"`
+		Runtime *struct {
+			ImageFs *FsStats `json:"imageFs,omitempty"`
+		} `json:"runtime,omitempty"`
+	} `json:"node"`
+}
+
+// NodeStats reads the stats of nodes from their kubelet, through the API
+// server.
+type NodeStats struct {
+	client rest.Interface
+}
+
+// NewNodeStats returns a reader of node stats using cfg to reach the API
+// server.
+func NewNodeStats(cfg *rest.Config) (*NodeStats, error) {
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &NodeStats{client: clientset.CoreV1().RESTClient()}, nil
+}
+
+// ImageFs returns the usage of the filesystem holding the images of node.
+// It is the node filesystem unless the runtime keeps images on another one.
+func (s *NodeStats) ImageFs(ctx context.Context, node string) (FsStats, error) {
+	data, err := s.client.Get().Resource("nodes").Name(node).SubResource("proxy").Suffix("stats/summary").DoRaw(ctx)
+	if err != nil {
+		return FsStats{}, err
+	}
+	return parseImageFs(data)
+}
+
+func parseImageFs(data []byte) (FsStats, error) {
+	var sum summary
+	if err := json.Unmarshal(data, &sum); err != nil {
+		return FsStats{}, err
+	}
+
+	fs := sum.Node.Fs
+	if rt := sum.Node.Runtime; rt != nil && rt.ImageFs != nil {
+		fs = rt.ImageFs
+	}
+	if fs == nil || fs.CapacityBytes == nil || fs.AvailableBytes == nil {
+		return FsStats{}, fmt.Errorf("kubelet reported no image filesystem capacity")
+	}
+	return *fs, nil
+}
+
+// UsesWatermarks reports whether the eraser in spec prunes images down to
+// the disk pressure watermarks.
+func UsesWatermarks(spec *corev1.PodSpec) bool {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name != EraserContainerName {
+			continue
+		}
+		for _, arg := range spec.Containers[i].Args {
+			if strings.HasPrefix(arg, HighWatermarkFlag+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ImageFsEnv returns the env of the eraser holding the usage of the image
+// filesystem of its node.
+func ImageFsEnv(fs FsStats) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{Name: utils.EnvImageFsCapacity, Value: fmt.Sprint(*fs.CapacityBytes)},
+		{Name: utils.EnvImageFsAvailable, Value: fmt.Sprint(*fs.AvailableBytes)},
+	}
+}
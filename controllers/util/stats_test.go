@@ -0,0 +1,64 @@
+package util
+
+import "testing"
+
+func TestParseImageFs(t *testing.T) {
+	testCases := []struct {
+		name        string
+		summary     string
+		capacity    uint64
+		usedPercent int
+		err         bool
+	}{
+		{
+			name:        "images on the node filesystem",
+			summary:     `{"node":{"fs":{"capacityBytes":1000,"availableBytes":150},"runtime":{"imageFs":{"capacityBytes":1000,"availableBytes":150,"usedBytes":200}}}}`,
+			capacity:    1000,
+			usedPercent: 85,
+		},
+		{
+			name:        "separate image filesystem",
+			summary:     `{"node":{"fs":{"capacityBytes":1000,"availableBytes":100},"runtime":{"imageFs":{"capacityBytes":4000,"availableBytes":3000,"usedBytes":900}}}}`,
+			capacity:    4000,
+			usedPercent: 25,
+		},
+		{
+			name:        "no runtime stats",
+			summary:     `{"node":{"fs":{"capacityBytes":1000,"availableBytes":400}}}`,
+			capacity:    1000,
+			usedPercent: 60,
+		},
+		{
+			name:    "no capacity",
+			summary: `{"node":{"runtime":{"imageFs":{"usedBytes":900}}}}`,
+			err:     true,
+		},
+		{
+			name:    "invalid",
+			summary: `{`,
+			err:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fs, err := parseImageFs([]byte(tc.summary))
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", fs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if *fs.CapacityBytes != tc.capacity {
+				t.Errorf("unexpected capacity: expected: %d, got: %d", tc.capacity, *fs.CapacityBytes)
+			}
+			if got := fs.UsedPercent(); got != tc.usedPercent {
+				t.Errorf("unexpected usage: expected: %d%%, got: %d%%", tc.usedPercent, got)
+			}
+		})
+	}
+}
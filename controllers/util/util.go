@@ -6,6 +6,8 @@ import (
 	"time"
 
 	eraserv1 "github.com/Azure/eraser/api/v1"
+	"github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/pkg/policy"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -26,6 +28,8 @@ func init() {
 
 const (
 	ImageJobOwnerLabelKey = "eraser.sh/job-owner"
+	// ImageJobNodesAnnotationKey limits an ImageJob to a comma-separated list of nodes.
+	ImageJobNodesAnnotationKey = "eraser.sh/nodes"
 
 	exclusionLabel = "eraser.sh/exclude.list=true"
 
@@ -136,3 +140,20 @@ func GetExclusionVolume(configmapList *corev1.ConfigMapList) ([]corev1.VolumeMou
 
 	return exclusionMount, exclusionVolume, nil
 }
+
+// ImagePolicyArgs returns the collector and eraser flags for the image
+// policy, after checking that its expressions compile.
+func ImagePolicyArgs(cfg v1alpha1.ImagePolicyConfig) ([]string, error) {
+	if _, err := policy.NewSelector(cfg.Include, cfg.Exclude); err != nil {
+		return nil, err
+	}
+
+	var args []string
+	if cfg.Include != "" {
+		args = append(args, "--include-policy="+cfg.Include)
+	}
+	if cfg.Exclude != "" {
+		args = append(args, "--exclude-policy="+cfg.Exclude)
+	}
+	return args, nil
+}
@@ -35,12 +35,15 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	eraserv1 "github.com/Azure/eraser/api/v1"
 	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
 	"github.com/Azure/eraser/api/v1alpha1/config"
 	"github.com/Azure/eraser/controllers"
+	controllerUtils "github.com/Azure/eraser/controllers/util"
 	"github.com/Azure/eraser/pkg/logger"
+	"github.com/Azure/eraser/pkg/metrics"
 	"github.com/Azure/eraser/version"
 	//+kubebuilder:scaffold:imports
 )
@@ -96,6 +99,11 @@ func main() {
 		options = o
 	}
 
+	if err := controllerUtils.ValidateSchedule(cfg.Manager.Scheduling); err != nil {
+		setupLog.Error(err, "invalid scheduling configuration")
+		os.Exit(1)
+	}
+
 	setupLog.V(1).Info("eraser config",
 		"manager", cfg.Manager,
 		"component", cfg.Components,
@@ -135,6 +143,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	// served on the metrics endpoint of the manager, along with its own
+	if err := metrics.Register(ctrlmetrics.Registry); err != nil {
+		setupLog.Error(err, "unable to register metrics")
+		os.Exit(1)
+	}
+
 	setupLog.Info("setup controllers")
 	if err = controllers.SetupWithManager(mgr, eraserOpts); err != nil {
 		setupLog.Error(err, "unable to setup controllers")
@@ -204,6 +218,11 @@ func startConfigWatch(watcher *inotify.Watcher, eraserOpts *config.Manager, file
 				continue
 			}
 
+			if err := controllerUtils.ValidateSchedule(cfg.Manager.Scheduling); err != nil {
+				setupLog.Error(err, "ignoring configuration with invalid scheduling", "filename", filename)
+				continue
+			}
+
 			if err = eraserOpts.Update(cfg); err != nil {
 				setupLog.Error(err, "configuration update failed")
 				continue
@@ -1,18 +1,22 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Azure/eraser/api/unversioned"
 	"github.com/Azure/eraser/pkg/cri"
 	"github.com/Azure/eraser/pkg/logger"
-	"golang.org/x/sys/unix"
+	"github.com/Azure/eraser/pkg/pipeline"
+	"github.com/Azure/eraser/pkg/policy"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	util "github.com/Azure/eraser/pkg/utils"
@@ -23,11 +27,20 @@ var (
 	enableProfile = flag.Bool("enable-pprof", false, "enable pprof profiling")
 	profilePort   = flag.Int("pprof-port", 6060, "port for pprof profiling. defaulted to 6060 if unspecified")
 	scanDisabled  = flag.Bool("scan-disabled", false, "boolean for if scanner container is disabled")
+	scanners      = flag.String("scanners", "", "comma-separated name:weight of each scanner container. defaults to a single scanner")
+	threshold     = flag.Int("verdict-threshold", 1, "total weight of the scanners finding an image non-compliant for it to be removed")
+	includePolicy = flag.String("include-policy", "", "only collect images matching this policy expression")
+	excludePolicy = flag.String("exclude-policy", "", "do not collect images matching this policy expression")
+	scanReport    = flag.String("scan-report", "", "report the images to the manager for a cluster scan, as part of this ImageJob")
 
 	// Timeout  of connecting to server (default: 5m).
 	timeout  = 5 * time.Minute
 	log      = logf.Log.WithName("collector")
 	excluded map[string]struct{}
+	// exclusions counts the images protected by each ImageExclusion
+	exclusions *util.ExclusionTracker
+	selector   *policy.Selector
+	node       policy.Node
 )
 
 func main() {
@@ -68,68 +81,116 @@ func main() {
 		log.Error(err, "failed to parse exclusion list")
 		os.Exit(1)
 	}
+
+	rules, err := util.ParseExclusionRules(filepath.Join(util.ImageExclusionsDir, util.ImageExclusionsFile))
+	if err != nil {
+		log.Error(err, "failed to parse image exclusions")
+		os.Exit(1)
+	}
+	exclusions = util.NewExclusionTracker(rules)
+	excluded = exclusions.Merge(excluded)
+
 	if len(excluded) == 0 {
 		log.Info("no images to exclude")
 	}
 
-	// finalImages of type []Image
-	finalImages, err := getImages(client)
+	selector, err = policy.NewSelector(*includePolicy, *excludePolicy)
 	if err != nil {
-		log.Error(err, "failed to list all images")
+		log.Error(err, "failed to compile image policy")
 		os.Exit(1)
 	}
-	log.Info("images collected", "finalImages:", finalImages)
 
-	data, err := json.Marshal(finalImages)
+	node, err = policy.CurrentNode()
 	if err != nil {
-		log.Error(err, "failed to encode finalImages")
+		log.Error(err, "failed to read node labels")
 		os.Exit(1)
 	}
 
-	path := util.CollectScanPath
-
-	if *scanDisabled {
-		path = util.ScanErasePath
+	// finalImages of type []Image
+	finalImages, err := getImages(client)
+	if err != nil {
+		log.Error(err, "failed to list all images")
+		os.Exit(1)
 	}
+	log.Info("images collected", "finalImages:", finalImages)
 
-	if err := unix.Mkfifo(path, util.PipeMode); err != nil {
-		log.Error(err, "failed to create pipe", "pipeFile", path)
-		os.Exit(1)
+	// report the images protected by each ImageExclusion, the eraser only
+	// sees the images that are left
+	result := unversioned.NodeResult{Node: node.Name, Exclusions: exclusions.Counts()}
+	if err := util.WriteNodeResult(util.TerminationLogPath, &result); err != nil {
+		log.Error(err, "unable to report node result", "path", util.TerminationLogPath)
 	}
 
-	file, err := os.OpenFile(path, os.O_WRONLY, 0)
-	if err != nil {
-		log.Error(err, "failed to open pipe", "pipeFile", path)
-		os.Exit(1)
+	// in a cluster scan, the manager scans the images and starts the eraser
+	// once every node has reported
+	if *scanReport != "" {
+		if err := writeScanReport(context.Background(), *scanReport, finalImages); err != nil {
+			log.Error(err, "failed to report images for cluster scan")
+			os.Exit(1)
+		}
+		log.Info("reported images for cluster scan", "job", *scanReport)
+		return
 	}
 
-	if _, err := file.Write(data); err != nil {
-		log.Error(err, "failed to write to pipe", "pipeFile", path)
+	lis, err := pipeline.Listen(pipeline.SocketPath)
+	if err != nil {
+		log.Error(err, "failed to listen for pipeline", "socket", pipeline.SocketPath)
 		os.Exit(1)
 	}
 
-	file.Close()
-	if err := unix.Mkfifo(util.EraseCompleteCollectPath, util.PipeMode); err != nil {
-		log.Error(err, "failed to create pipe", "pipeFile", util.EraseCompleteCollectPath)
-		os.Exit(1)
+	var weights map[string]int
+	if !*scanDisabled {
+		weights, err = parseScanners(*scanners)
+		if err != nil {
+			log.Error(err, "invalid scanners", "scanners", *scanners)
+			os.Exit(1)
+		}
 	}
 
-	file, err = os.OpenFile(util.EraseCompleteCollectPath, os.O_RDONLY, 0)
+	server, err := pipeline.NewServer(finalImages, weights, *threshold)
 	if err != nil {
-		log.Error(err, "failed to open pipe", "pipeFile", util.EraseCompleteCollectPath)
+		log.Error(err, "failed to create pipeline server")
 		os.Exit(1)
 	}
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Error(err, "pipeline server failed")
+			os.Exit(1)
+		}
+	}()
 
-	data, err = io.ReadAll(file)
+	// serve until the eraser is done with the images
+	results, err := server.Wait(context.Background())
+	server.Stop()
 	if err != nil {
-		log.Error(err, "failed to read pipe", "pipeFile", util.EraseCompleteCollectPath)
+		log.Error(err, "pipeline did not complete")
 		os.Exit(1)
 	}
+	log.Info("pipeline complete", "results", len(results))
+}
 
-	file.Close()
-
-	if string(data) != util.EraseCompleteMessage {
-		log.Info("garbage in pipe", "pipeFile", util.EraseCompleteCollectPath, "in_pipe", string(data))
-		os.Exit(1)
-	}
+// parseScanners parses the name:weight of each scanner. An empty list is a
+// single scanner, which does not need to send its name.
+func parseScanners(list string) (map[string]int, error) {
+	if list == "" {
+		return map[string]int{"": 1}, nil
+	}
+
+	weights := map[string]int{}
+	for _, item := range strings.Split(list, ",") {
+		name, weight, found := strings.Cut(item, ":")
+		if !found {
+			return nil, fmt.Errorf("scanner %q has no weight", item)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight of scanner %q: %w", name, err)
+		}
+		if _, ok := weights[name]; ok {
+			return nil, fmt.Errorf("duplicate scanner %q", name)
+		}
+		weights[name] = w
+	}
+
+	return weights, nil
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/Azure/eraser/api/unversioned"
 	"github.com/Azure/eraser/pkg/cri"
+	"github.com/Azure/eraser/pkg/policy"
 	util "github.com/Azure/eraser/pkg/utils"
 )
 
@@ -20,6 +21,8 @@ func getImages(c cri.Collector) ([]unversioned.Image, error) {
 	allImages := make([]unversioned.Image, 0, len(images))
 	// map with key: imageID, value: repoTag list (contains full name of image)
 	idToImageMap := make(map[string]unversioned.Image)
+	// map of imageID -> metadata seen by the image policy
+	policyImages := make(map[string]policy.Image, len(images))
 
 	for _, img := range images {
 		repoTags := []string{}
@@ -28,6 +31,7 @@ func getImages(c cri.Collector) ([]unversioned.Image, error) {
 		newImg := unversioned.Image{
 			ImageID: img.Id,
 			Names:   repoTags,
+			Size:    int64(img.Size_),
 		}
 
 		digests, errs := util.ProcessRepoDigests(img.RepoDigests)
@@ -39,6 +43,7 @@ func getImages(c cri.Collector) ([]unversioned.Image, error) {
 
 		allImages = append(allImages, newImg)
 		idToImageMap[img.Id] = newImg
+		policyImages[img.Id] = policy.Image{Image: newImg, SizeBytes: int64(img.Size_), Pinned: img.Pinned}
 	}
 
 	containers, err := c.ListContainers(backgroundContext)
@@ -71,12 +76,44 @@ func getImages(c cri.Collector) ([]unversioned.Image, error) {
 			ImageID: imageID,
 			Names:   img.Names,
 			Digests: img.Digests,
+			Size:    img.Size,
 		}
 
-		if !util.IsExcluded(excluded, currImage.ImageID, idToImageMap) {
-			finalImages = append(finalImages, currImage)
+		if util.IsExcluded(excluded, currImage.ImageID, idToImageMap) {
+			exclusions.Record(imageID, idToImageMap)
+			continue
+		}
+
+		if !selectedByPolicy(policyImages[imageID]) {
+			continue
 		}
+
+		finalImages = append(finalImages, currImage)
 	}
 
 	return finalImages, nil
 }
+
+// selectedByPolicy reports whether img matches the include policy and not
+// the exclude policy. Images are kept when a policy fails to evaluate.
+func selectedByPolicy(img policy.Image) bool {
+	excluded, err := selector.Excluded(img, node)
+	if err != nil {
+		log.Error(err, "error evaluating exclude policy", "imageID", img.ImageID)
+	}
+	if excluded {
+		log.Info("image is excluded by policy", "imageID", img.ImageID, "names", img.Names)
+		return false
+	}
+
+	included, err := selector.Included(img, node)
+	if err != nil {
+		log.Error(err, "error evaluating include policy", "imageID", img.ImageID)
+	}
+	if !included {
+		log.V(1).Info("image is not included by policy", "imageID", img.ImageID, "names", img.Names)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/eraser/api/unversioned"
+	util "github.com/Azure/eraser/pkg/utils"
+)
+
+// writeScanReport stores the images of the node in a configmap labeled
+// with job, for the manager to scan them once for the whole cluster.
+func writeScanReport(ctx context.Context, job string, images []unversioned.Image) error {
+	report := util.ScanReport{
+		Node:     node.Name,
+		Platform: platform(node.Labels),
+		Images:   images,
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: job + "-report-",
+			Namespace:    util.GetNamespace(),
+			Labels:       map[string]string{util.ScanReportLabelKey: job},
+		},
+		Immutable: util.BoolPtr(true),
+		Data:      map[string]string{util.ScanReportDataKey: string(data)},
+	}
+
+	return c.Create(ctx, configMap)
+}
+
+// platform returns the os/arch of a node from its well-known labels.
+func platform(labels map[string]string) string {
+	osName, arch := labels[corev1.LabelOSStable], labels[corev1.LabelArchStable]
+	if osName == "" || arch == "" {
+		return ""
+	}
+	return osName + "/" + arch
+}
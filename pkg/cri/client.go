@@ -24,6 +24,7 @@ type (
 	Eraser interface {
 		Collector
 		DeleteImage(context.Context, string) error
+		ImageStatus(context.Context, string) (*v1.ImageStatusResponse, error)
 	}
 
 	runtimeTryFunc func(context.Context, *grpc.ClientConn) (string, error)
@@ -51,3 +51,9 @@ func (c *v1Client) DeleteImage(ctx context.Context, image string) (err error) {
 
 	return nil
 }
+
+func (c *v1Client) ImageStatus(ctx context.Context, image string) (*v1.ImageStatusResponse, error) {
+	request := &v1.ImageStatusRequest{Image: &v1.ImageSpec{Image: image}, Verbose: true}
+
+	return c.images.ImageStatus(ctx, request)
+}
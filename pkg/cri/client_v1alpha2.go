@@ -53,6 +53,20 @@ func (c *v1alpha2Client) DeleteImage(ctx context.Context, image string) (err err
 	return nil
 }
 
+func (c *v1alpha2Client) ImageStatus(ctx context.Context, image string) (*v1.ImageStatusResponse, error) {
+	request := &v1alpha2.ImageStatusRequest{Image: &v1alpha2.ImageSpec{Image: image}, Verbose: true}
+
+	resp, err := c.images.ImageStatus(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.ImageStatusResponse{
+		Image: convertImage(resp.Image),
+		Info:  resp.Info,
+	}, nil
+}
+
 func convertContainers(list []*v1alpha2.Container) []*v1.Container {
 	v1s := []*v1.Container{}
 
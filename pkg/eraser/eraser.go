@@ -2,15 +2,14 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"io/fs"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -20,8 +19,9 @@ import (
 	"github.com/Azure/eraser/pkg/cri"
 	"github.com/Azure/eraser/pkg/logger"
 	"github.com/Azure/eraser/pkg/metrics"
+	"github.com/Azure/eraser/pkg/pipeline"
+	imagepolicy "github.com/Azure/eraser/pkg/policy"
 
-	"github.com/Azure/eraser/api/unversioned"
 	util "github.com/Azure/eraser/pkg/utils"
 )
 
@@ -30,11 +30,30 @@ var (
 	imageListPtr  = flag.String("imagelist", "", "name of ImageList")
 	enableProfile = flag.Bool("enable-pprof", false, "enable pprof profiling")
 	profilePort   = flag.Int("pprof-port", 6060, "port for pprof profiling. defaulted to 6060 if unspecified")
+	dryRun        = flag.Bool("dry-run", false, "report the images that would be removed without removing them")
+	unusedFor     = flag.Duration("prune-unused-for", 0, "only prune images that no container has used for this long")
+	olderThan     = flag.Duration("prune-older-than", 0, "only prune images that were created this long ago")
+	highWatermark = flag.Int("high-watermark", 0, "prune non-running images when the image filesystem usage is at least this percentage of its capacity")
+	lowWatermark  = flag.Int("low-watermark", 0, "stop pruning once the image filesystem usage is at most this percentage of its capacity")
+	includePolicy = flag.String("include-policy", "", "only prune images matching this policy expression")
+	excludePolicy = flag.String("exclude-policy", "", "never remove images matching this policy expression")
+	quarantine    = flag.Bool("quarantine", false, "keep non-compliant images until the manager releases them")
+	stateDirPtr   = flag.String("state-dir", "", "directory on the node recording when each image was first seen")
 
 	// Timeout  of connecting to server (default: 5m).
 	timeout  = 5 * time.Minute
 	log      = logf.Log.WithName("eraser")
 	excluded map[string]struct{}
+	// exclusions counts the images protected by each ImageExclusion
+	exclusions *util.ExclusionTracker
+	selector   *imagepolicy.Selector
+	node       imagepolicy.Node
+	// released holds the IDs of the quarantined images that may be removed,
+	// or nil when images are not quarantined
+	released map[string]struct{}
+	// stateDir keeps what the eraser records on the node across runs, or is
+	// empty when nothing is recorded
+	stateDir string
 )
 
 const (
@@ -72,41 +91,95 @@ func main() {
 		os.Exit(generalErr)
 	}
 
-	var imagelist []string
-
-	if *imageListPtr == "" {
-		var f *os.File
-		for {
-			var err error
+	stateDir = *stateDirPtr
+	policy := prunePolicy{unusedFor: *unusedFor, olderThan: *olderThan}
 
-			f, err = os.OpenFile(util.ScanErasePath, os.O_RDONLY, 0)
-			if err == nil {
-				break
-			}
-			if !os.IsNotExist(err) {
-				log.Error(err, "error opening scanErase pipe")
-				os.Exit(generalErr)
-			}
-			time.Sleep(1 * time.Second)
-			continue
+	// when watermarks are set, the eraser was started because of disk
+	// pressure and prunes images until enough space is free.
+	diskPressure := *highWatermark > 0
+	if diskPressure {
+		capacity, err := strconv.ParseUint(os.Getenv(util.EnvImageFsCapacity), 10, 64)
+		if err != nil || capacity == 0 {
+			log.Error(err, "unable to determine image filesystem capacity", "env", util.EnvImageFsCapacity)
+			os.Exit(generalErr)
 		}
+		available, err := strconv.ParseUint(os.Getenv(util.EnvImageFsAvailable), 10, 64)
+		if err != nil || available > capacity {
+			log.Error(err, "unable to determine image filesystem usage", "env", util.EnvImageFsAvailable)
+			os.Exit(generalErr)
+		}
+
+		policy.highUsage = capacity * uint64(*highWatermark) / 100
+		policy.lowUsage = capacity * uint64(*lowWatermark) / 100
+		policy.used = capacity - available
+	}
 
-		// json data is list of []unversioned.Image
-		data, err := io.ReadAll(f)
+	excluded, err = util.ParseExcluded()
+	if os.IsNotExist(err) {
+		log.Info("configmaps for exclusion do not exist")
+	} else if err != nil {
+		log.Error(err, "failed to parse exclusion list")
+		os.Exit(generalErr)
+	}
+
+	rules, err := util.ParseExclusionRules(filepath.Join(util.ImageExclusionsDir, util.ImageExclusionsFile))
+	if err != nil {
+		log.Error(err, "failed to parse image exclusions")
+		os.Exit(generalErr)
+	}
+	exclusions = util.NewExclusionTracker(rules)
+	excluded = exclusions.Merge(excluded)
+
+	if len(excluded) == 0 {
+		log.Info("no images to exclude")
+	}
+
+	selector, err = imagepolicy.NewSelector(*includePolicy, *excludePolicy)
+	if err != nil {
+		log.Error(err, "failed to compile image policy")
+		os.Exit(generalErr)
+	}
+
+	node, err = imagepolicy.CurrentNode()
+	if err != nil {
+		log.Error(err, "failed to read node labels")
+		os.Exit(generalErr)
+	}
+
+	if *quarantine {
+		released, err = util.ParseReleasedImages(filepath.Join(util.ReleasedImagesDir, util.ReleasedImagesFile))
 		if err != nil {
-			log.Error(err, "error reading non-compliant images")
+			log.Error(err, "failed to parse released images")
 			os.Exit(generalErr)
 		}
-		f.Close()
+		log.Info("non-compliant images are quarantined", "released", len(released))
+	}
 
-		nonCompliantImages := []unversioned.Image{}
-		if err = json.Unmarshal(data, &nonCompliantImages); err != nil {
-			log.Error(err, "error in unmarshal non-compliant images")
+	var imagelist []string
+	// the images to remove come from the collector and scanner of the pod
+	// unless they are given up front
+	var pipelineClient *pipeline.Client
+
+	if diskPressure {
+		imagelist = []string{"*"}
+	} else if *imageListPtr == "" {
+		ctx := context.Background()
+
+		pipelineClient, err = pipeline.Dial(ctx, pipeline.SocketPath, pipeline.StageEraser)
+		if err != nil {
+			log.Error(err, "failed to connect to pipeline", "socket", pipeline.SocketPath)
 			os.Exit(generalErr)
 		}
 
-		for _, img := range nonCompliantImages {
-			imagelist = append(imagelist, img.ImageID)
+		err = pipelineClient.WatchVerdicts(ctx, func(v pipeline.Verdict) error {
+			if v.Remove() {
+				imagelist = append(imagelist, v.Image.ImageID)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Error(err, "error receiving non-compliant images")
+			os.Exit(generalErr)
 		}
 
 		log.Info("successfully created imagelist from scanned non-compliant images")
@@ -119,23 +192,31 @@ func main() {
 		log.Info("successfully parsed image list file")
 	}
 
-	excluded, err = util.ParseExcluded()
-	if os.IsNotExist(err) {
-		log.Info("configmaps for exclusion do not exist")
-	} else if err != nil {
-		log.Error(err, "failed to parse exclusion list")
-		os.Exit(generalErr)
+	result, err := removeImages(client, imagelist, *dryRun, policy)
+	if err != nil {
+		result.Error = err.Error()
 	}
-	if len(excluded) == 0 {
-		log.Info("no images to exclude")
+
+	result.Node = os.Getenv("NODE_NAME")
+	if err := util.WriteNodeResult(util.TerminationLogPath, &result); err != nil {
+		log.Error(err, "unable to report node result", "path", util.TerminationLogPath)
 	}
 
-	removed, err := removeImages(client, imagelist)
 	if err != nil {
 		log.Error(err, "failed to remove images")
+		if pipelineClient != nil {
+			if err := pipelineClient.Abort(context.Background(), err); err != nil {
+				log.Error(err, "unable to abort pipeline")
+			}
+		}
 		os.Exit(generalErr)
 	}
 
+	removed := len(result.Removed)
+	if result.DryRun {
+		removed = 0
+	}
+
 	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
 		// record metrics
 		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -150,35 +231,11 @@ func main() {
 		cancel()
 	}
 
-	if *imageListPtr == "" {
-		file, err := os.OpenFile(util.EraseCompleteCollectPath, os.O_WRONLY, 0)
-		if err != nil {
-			log.Error(err, "unable to open pipe", "pipeFile", util.EraseCompleteCollectPath)
+	if pipelineClient != nil {
+		if err := pipelineClient.PublishResults(context.Background(), pipeline.RemovalResults(&result)); err != nil {
+			log.Error(err, "unable to publish removal results")
 			os.Exit(generalErr)
 		}
-
-		if _, err := file.WriteString(util.EraseCompleteMessage); err != nil {
-			log.Error(err, "unable to write to pipe", "pipeFile", util.EraseCompleteCollectPath)
-			os.Exit(generalErr)
-		}
-
-		file.Close()
-
-		file, err = os.OpenFile(util.EraseCompleteScanPath, os.O_WRONLY, fs.ModeNamedPipe)
-		// if the scanner is disabled
-		if os.IsNotExist(err) {
-			return
-		}
-		if err != nil {
-			log.Error(err, "unable to open pipe", "pipeFile", util.EraseCompleteCollectPath)
-			os.Exit(generalErr)
-		}
-
-		if _, err := file.WriteString(util.EraseCompleteMessage); err != nil {
-			log.Error(err, "unable to write to pipe", "pipeFile", util.EraseCompleteCollectPath)
-			os.Exit(generalErr)
-		}
-
-		file.Close()
+		pipelineClient.Close()
 	}
 }
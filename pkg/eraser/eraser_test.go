@@ -1,9 +1,15 @@
 package main
 
 import (
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
 	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/Azure/eraser/api/unversioned"
+	imagepolicy "github.com/Azure/eraser/pkg/policy"
 )
 
 func TestRemoveImages(t *testing.T) {
@@ -12,6 +18,8 @@ func TestRemoveImages(t *testing.T) {
 		cached    []string
 		remove    []string
 		expect    []string
+		dryRun    bool
+		reported  []string
 		shouldErr bool
 	}
 
@@ -28,6 +36,8 @@ func TestRemoveImages(t *testing.T) {
 		"Remove all images by prune":             {cached: []string{"image1", "image2", "image3"}, remove: []string{"*"}, expect: []string{}},
 		"Prune and explicit image running=false": {cached: []string{"image1", "image2", "image3"}, remove: []string{"*", "image2"}, expect: []string{}},
 		"Prune and explicit image running=true":  {running: []string{"image1"}, cached: []string{"image2", "image3"}, remove: []string{"*", "image2"}, expect: []string{"image1"}},
+		"Dry run explicit images":                {cached: []string{"image1", "image2"}, running: []string{"image3"}, remove: []string{"image1", "image3"}, expect: []string{"image1", "image2", "image3"}, dryRun: true, reported: []string{"image1"}},
+		"Dry run prune":                          {cached: []string{"image1", "image2"}, running: []string{"image3"}, remove: []string{"*"}, expect: []string{"image1", "image2", "image3"}, dryRun: true, reported: []string{"image1", "image2"}},
 	}
 
 	for k, tc := range cases {
@@ -51,7 +61,7 @@ func TestRemoveImages(t *testing.T) {
 				}
 			}
 
-			_, err := removeImages(client, tc.remove)
+			result, err := removeImages(client, tc.remove, tc.dryRun, prunePolicy{})
 			if tc.shouldErr && err == nil {
 				t.Fatal("expected error, got none")
 			}
@@ -74,6 +84,13 @@ func TestRemoveImages(t *testing.T) {
 					t.Fatalf("expected image to still exist: %s", tc.expect[j])
 				}
 			}
+			if tc.dryRun {
+				if !reflect.DeepEqual(result.Removed, tc.reported) {
+					t.Fatalf("unexpected images reported as removed: expected: %v, got: %v", tc.reported, result.Removed)
+				}
+				return
+			}
+
 			for j := range tc.remove {
 				if _, ok := running[tc.remove[j]]; ok {
 					// Skip checking if image still exists if it is running
@@ -86,3 +103,275 @@ func TestRemoveImages(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveImagesResult(t *testing.T) {
+	client := &testClient{
+		t:          t,
+		images:     []*v1.Image{{Id: "image1"}, {Id: "image2"}},
+		containers: []*v1.Container{{Image: &v1.ImageSpec{Image: "image2"}}},
+	}
+
+	result, err := removeImages(client, []string{"image1", "image2", "image3"}, false, prunePolicy{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expected := unversioned.NodeResult{
+		Removed:      []string{"image1"},
+		RemovedCount: 1,
+		Running:      []string{"image2"},
+		NotPresent:   []string{"image3"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("unexpected result: expected: %+v, got: %+v", expected, result)
+	}
+}
+
+func TestRemoveImagesQuarantine(t *testing.T) {
+	released = map[string]struct{}{"image2": {}, "example.com/released:1": {}}
+	defer func() { released = nil }()
+
+	images := func() []*v1.Image {
+		return []*v1.Image{
+			{Id: "image1"},
+			{Id: "image2"},
+			{Id: "image3"},
+			{Id: "image4", RepoTags: []string{"example.com/held:1"}},
+			{Id: "image5", RepoTags: []string{"example.com/released:1"}},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		targets  []string
+		expected unversioned.NodeResult
+	}{
+		{
+			name:    "targets",
+			targets: []string{"image1", "image2", "image3", "example.com/held:1", "image5"},
+			expected: unversioned.NodeResult{
+				Removed:      []string{"image2", "image5"},
+				RemovedCount: 2,
+				Running:      []string{"image3"},
+				Quarantined:  []string{"example.com/held:1", "image1"},
+			},
+		},
+		{
+			name:    "prune",
+			targets: []string{"*"},
+			expected: unversioned.NodeResult{
+				Removed:      []string{"example.com/released:1", "image2"},
+				RemovedCount: 2,
+				Running:      []string{"image3"},
+				Quarantined:  []string{"example.com/held:1", "image1"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &testClient{
+				t:          t,
+				images:     images(),
+				containers: []*v1.Container{{Image: &v1.ImageSpec{Image: "image3"}}},
+			}
+
+			result, err := removeImages(client, tc.targets, false, prunePolicy{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Fatalf("unexpected result: expected: %+v, got: %+v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestRemoveImagesPrunePolicy(t *testing.T) {
+	now := time.Now()
+	created := map[string]time.Time{
+		"old":    now.Add(-30 * 24 * time.Hour),
+		"recent": now.Add(-time.Hour),
+		// built long ago, but only just pulled
+		"pulled": now.Add(-30 * 24 * time.Hour),
+	}
+	firstSeen := map[string]time.Time{
+		"old":    now.Add(-30 * 24 * time.Hour),
+		"pulled": now.Add(-5 * time.Minute),
+	}
+
+	cases := map[string]struct {
+		policy   prunePolicy
+		noState  bool
+		removed  []string
+		retained []string
+	}{
+		"No policy":                   {removed: []string{"old", "pulled", "recent", "unknown"}},
+		"Older than a week":           {policy: prunePolicy{olderThan: 7 * 24 * time.Hour}, removed: []string{"old", "pulled"}, retained: []string{"recent", "unknown"}},
+		"Unused for a day":            {policy: prunePolicy{unusedFor: 24 * time.Hour}, removed: []string{"old"}, retained: []string{"pulled", "recent", "unknown"}},
+		"Unused for more than a year": {policy: prunePolicy{unusedFor: 365 * 24 * time.Hour}, retained: []string{"old", "pulled", "recent", "unknown"}},
+		"Unused without first seen":   {policy: prunePolicy{unusedFor: 24 * time.Hour}, noState: true, retained: []string{"old", "pulled", "recent", "unknown"}},
+	}
+
+	for k, tc := range cases {
+		tc := tc
+		t.Run(k, func(t *testing.T) {
+			if !tc.noState {
+				stateDir = t.TempDir()
+				defer func() { stateDir = "" }()
+				if err := writeFirstSeen(filepath.Join(stateDir, firstSeenFile), firstSeen); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			client := &testClient{
+				t:       t,
+				images:  []*v1.Image{{Id: "old"}, {Id: "recent"}, {Id: "unknown"}, {Id: "pulled"}},
+				created: created,
+			}
+
+			result, err := removeImages(client, []string{"*"}, false, tc.policy)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Removed, tc.removed) {
+				t.Errorf("unexpected images removed: expected: %v, got: %v", tc.removed, result.Removed)
+			}
+			if !reflect.DeepEqual(result.Retained, tc.retained) {
+				t.Errorf("unexpected images retained: expected: %v, got: %v", tc.retained, result.Retained)
+			}
+		})
+	}
+}
+
+func TestRecordFirstSeen(t *testing.T) {
+	dir := t.TempDir()
+	before := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := writeFirstSeen(filepath.Join(dir, firstSeenFile), map[string]time.Time{"kept": before, "gone": before}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	seen := recordFirstSeen(dir, []unversioned.Image{{ImageID: "kept"}, {ImageID: "new"}}, now)
+	expected := map[string]time.Time{"kept": before, "new": now}
+	if len(seen) != len(expected) || !seen["kept"].Equal(before) || !seen["new"].Equal(now) {
+		t.Fatalf("unexpected first seen times: expected: %v, got: %v", expected, seen)
+	}
+
+	// the times are kept for the next run
+	seen = recordFirstSeen(dir, []unversioned.Image{{ImageID: "new"}}, now.Add(time.Hour))
+	if len(seen) != 1 || !seen["new"].Equal(now) {
+		t.Fatalf("unexpected first seen times: expected: %v, got: %v", map[string]time.Time{"new": now}, seen)
+	}
+
+	if seen := recordFirstSeen("", []unversioned.Image{{ImageID: "new"}}, now); seen != nil {
+		t.Fatalf("expected no first seen times without a state directory, got: %v", seen)
+	}
+}
+
+func TestRemoveImagesWatermarks(t *testing.T) {
+	now := time.Now()
+
+	cases := map[string]struct {
+		policy   prunePolicy
+		removed  []string
+		retained []string
+	}{
+		"Below high watermark":      {policy: prunePolicy{highUsage: 400, lowUsage: 100, used: 300}, retained: []string{"newest", "older", "oldest"}},
+		"Prune to low watermark":    {policy: prunePolicy{highUsage: 300, lowUsage: 150, used: 300}, removed: []string{"older", "oldest"}, retained: []string{"newest"}},
+		"Prune everything":          {policy: prunePolicy{highUsage: 300, lowUsage: 0, used: 300}, removed: []string{"newest", "older", "oldest"}},
+		"Watermarks and age policy": {policy: prunePolicy{highUsage: 300, lowUsage: 0, used: 300, olderThan: 48 * time.Hour}, removed: []string{"older", "oldest"}, retained: []string{"newest"}},
+		// logs and volumes fill the filesystem, not only images
+		"Usage beyond images": {policy: prunePolicy{highUsage: 600, lowUsage: 500, used: 700}, removed: []string{"older", "oldest"}, retained: []string{"newest"}},
+	}
+
+	for k, tc := range cases {
+		tc := tc
+		t.Run(k, func(t *testing.T) {
+			client := &testClient{
+				t: t,
+				images: []*v1.Image{
+					{Id: "newest", Size_: 100},
+					{Id: "oldest", Size_: 100},
+					{Id: "older", Size_: 100},
+				},
+				created: map[string]time.Time{
+					"newest": now.Add(-time.Hour),
+					"oldest": now.Add(-72 * time.Hour),
+					"older":  now.Add(-71 * time.Hour),
+				},
+			}
+
+			result, err := removeImages(client, []string{"*"}, false, tc.policy)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Removed, tc.removed) {
+				t.Errorf("unexpected images removed: expected: %v, got: %v", tc.removed, result.Removed)
+			}
+			if !reflect.DeepEqual(result.Retained, tc.retained) {
+				t.Errorf("unexpected images retained: expected: %v, got: %v", tc.retained, result.Retained)
+			}
+			if want := int64(100 * len(tc.removed)); result.RemovedBytes != want {
+				t.Errorf("unexpected size removed: expected: %d, got: %d", want, result.RemovedBytes)
+			}
+			if want := int64(len(tc.removed)); result.RemovedCount != want {
+				t.Errorf("unexpected count removed: expected: %d, got: %d", want, result.RemovedCount)
+			}
+		})
+	}
+}
+
+func TestRemoveImagesImagePolicy(t *testing.T) {
+	cases := map[string]struct {
+		include  string
+		exclude  string
+		targets  []string
+		removed  []string
+		retained []string
+		excluded []string
+	}{
+		"Include by repo":       {include: `image.repo.startsWith("mcr.microsoft.com")`, targets: []string{"*"}, removed: []string{"mcr.microsoft.com/big:1"}, retained: []string{"docker.io/small:1", "pinned"}},
+		"Exclude pinned":        {exclude: `image.pinned`, targets: []string{"*"}, removed: []string{"docker.io/small:1", "mcr.microsoft.com/big:1"}, excluded: []string{"pinned"}},
+		"Exclude by size":       {exclude: `image.sizeBytes > quantity("1Gi")`, targets: []string{"mcr.microsoft.com/big:1"}, excluded: []string{"mcr.microsoft.com/big:1"}},
+		"Include ignores names": {include: `image.sizeBytes > quantity("1Gi")`, targets: []string{"docker.io/small:1"}, removed: []string{"docker.io/small:1"}},
+	}
+
+	for k, tc := range cases {
+		tc := tc
+		t.Run(k, func(t *testing.T) {
+			var err error
+			selector, err = imagepolicy.NewSelector(tc.include, tc.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { selector = nil }()
+
+			client := &testClient{
+				t: t,
+				images: []*v1.Image{
+					{Id: "big", RepoTags: []string{"mcr.microsoft.com/big:1"}, Size_: 2 << 30},
+					{Id: "small", RepoTags: []string{"docker.io/small:1"}, Size_: 1 << 20},
+					{Id: "pinned", Pinned: true},
+				},
+			}
+
+			result, err := removeImages(client, tc.targets, false, prunePolicy{})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Removed, tc.removed) {
+				t.Errorf("unexpected images removed: expected: %v, got: %v", tc.removed, result.Removed)
+			}
+			if !reflect.DeepEqual(result.Retained, tc.retained) {
+				t.Errorf("unexpected images retained: expected: %v, got: %v", tc.retained, result.Retained)
+			}
+			if !reflect.DeepEqual(result.Excluded, tc.excluded) {
+				t.Errorf("unexpected images excluded: expected: %v, got: %v", tc.excluded, result.Excluded)
+			}
+		})
+	}
+}
@@ -2,26 +2,38 @@ package main
 
 import (
 	"context"
+	"sort"
+	"time"
+
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
 
 	"github.com/Azure/eraser/api/unversioned"
 	"github.com/Azure/eraser/pkg/cri"
+	imagepolicy "github.com/Azure/eraser/pkg/policy"
 	util "github.com/Azure/eraser/pkg/utils"
 )
 
-func removeImages(c cri.Eraser, targetImages []string) (int, error) {
-	removed := 0
+// removeImages removes targetImages from the node and reports what was done
+// with each of them. When dryRun is set, nothing is deleted and Removed lists
+// the images that would have been. The prune policy only applies to "*".
+func removeImages(c cri.Eraser, targetImages []string, dryRun bool, policy prunePolicy) (unversioned.NodeResult, error) {
+	result := unversioned.NodeResult{DryRun: dryRun}
 
 	backgroundContext, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	images, err := c.ListImages(backgroundContext)
 	if err != nil {
-		return 0, err
+		return result, err
 	}
 
 	allImages := make([]unversioned.Image, 0, len(images))
 	// map with key: imageID, value: repoTag list (contains full name of image)
 	idToImageMap := make(map[string]unversioned.Image)
+	// map of imageID -> size in bytes
+	sizes := make(map[string]uint64, len(images))
+	// map of imageID -> metadata seen by the image policy
+	policyImages := make(map[string]imagepolicy.Image, len(images))
 
 	for _, img := range images {
 		repoTags := []string{}
@@ -40,11 +52,15 @@ func removeImages(c cri.Eraser, targetImages []string) (int, error) {
 		newImg.Digests = append(newImg.Digests, digests...)
 		allImages = append(allImages, newImg)
 		idToImageMap[img.Id] = newImg
+		sizes[img.Id] = img.Size_
+		policyImages[img.Id] = imagepolicy.Image{Image: newImg, SizeBytes: int64(img.Size_), Pinned: img.Pinned}
 	}
 
+	firstSeen := recordFirstSeen(stateDir, allImages, time.Now())
+
 	containers, err := c.ListContainers(backgroundContext)
 	if err != nil {
-		return 0, err
+		return result, err
 	}
 
 	// Images that are running
@@ -60,6 +76,13 @@ func removeImages(c cri.Eraser, targetImages []string) (int, error) {
 	log.V(1).Info("Map of running images", "runningImages", runningImages)
 	log.V(1).Info("Map of digest to image name(s)", "idToImageMap", idToImageMap)
 
+	deleteImage := func(imageID string) error {
+		if dryRun {
+			return nil
+		}
+		return c.DeleteImage(backgroundContext, imageID)
+	}
+
 	// remove target images
 	var prune bool
 	deletedImages := make(map[string]struct{}, len(targetImages))
@@ -70,54 +93,122 @@ func removeImages(c cri.Eraser, targetImages []string) (int, error) {
 		}
 
 		if imageID, isNonRunning := nonRunningImages[imgDigestOrTag]; isNonRunning {
-			if ex := util.IsExcluded(excluded, imgDigestOrTag, idToImageMap); ex {
+			if ex := util.IsExcluded(excluded, imgDigestOrTag, idToImageMap); ex || excludedByPolicy(policyImages[imageID]) {
+				if ex {
+					exclusions.Record(imageID, idToImageMap)
+				}
 				log.Info("image is excluded", "given", imgDigestOrTag, "imageID", imageID, "name", idToImageMap[imageID])
+				result.Excluded = append(result.Excluded, imgDigestOrTag)
 				continue
 			}
 
-			err = c.DeleteImage(backgroundContext, imageID)
+			if quarantined(imgDigestOrTag, idToImageMap[imageID]) {
+				log.Info("image is quarantined", "given", imgDigestOrTag, "imageID", imageID, "name", idToImageMap[imageID])
+				result.Quarantined = append(result.Quarantined, imgDigestOrTag)
+				continue
+			}
+
+			err = deleteImage(imageID)
 			if err != nil {
 				log.Error(err, "error removing image", "given", imgDigestOrTag, "imageID", imageID, "name", idToImageMap[imageID])
+				result.Errors = append(result.Errors, unversioned.ImageError{Image: imgDigestOrTag, Reason: err.Error()})
 				continue
 			}
 
 			deletedImages[imgDigestOrTag] = struct{}{}
-			log.Info("removed image", "given", imgDigestOrTag, "imageID", imageID, "name", idToImageMap[imageID])
-			removed++
+			deletedImages[imageID] = struct{}{}
+			log.Info("removed image", "given", imgDigestOrTag, "imageID", imageID, "name", idToImageMap[imageID], "dryRun", dryRun)
+			result.Removed = append(result.Removed, imgDigestOrTag)
+			result.RemovedCount++
+			result.RemovedBytes += int64(sizes[imageID])
 			continue
 		}
 
 		imageID, isRunning := runningImages[imgDigestOrTag]
 		if isRunning {
 			log.Info("image is running", "given", imgDigestOrTag, "imageID", imageID, "name", idToImageMap[imageID])
+			result.Running = append(result.Running, imgDigestOrTag)
 			continue
 		}
 
 		log.Info("image is not on node", "given", imgDigestOrTag)
+		result.NotPresent = append(result.NotPresent, imgDigestOrTag)
 	}
 
 	if prune {
+		created, lastUsed := policy.imageTimes(backgroundContext, c, containers, runningImages, allImages, firstSeen)
+		prunable := policy.filter(runningImages, allImages, idToImageMap, created, lastUsed)
+
+		used := policy.used
+		if policy.highUsage > 0 && used < policy.highUsage {
+			log.Info("image filesystem usage is below the high watermark", "usedBytes", used, "highWatermarkBytes", policy.highUsage)
+			prunable = nil
+		}
+
 		success := true
-		for _, imageID := range nonRunningImages {
+		for _, imageID := range pruneOrder(nonRunningImages, lastUsed, created) {
 			if _, deleted := deletedImages[imageID]; deleted {
 				continue
 			}
 
-			if util.IsExcluded(excluded, imageID, idToImageMap) {
+			if ex := util.IsExcluded(excluded, imageID, idToImageMap); ex || excludedByPolicy(policyImages[imageID]) {
+				if ex {
+					exclusions.Record(imageID, idToImageMap)
+				}
 				log.Info("image is excluded", "imageID", imageID, "name", idToImageMap[imageID])
+				result.Excluded = append(result.Excluded, imageName(idToImageMap[imageID]))
+				continue
+			}
+
+			if _, ok := prunable[imageID]; !ok || !includedByPolicy(policyImages[imageID]) {
+				log.Info("image is retained by prune policy", "imageID", imageID, "name", idToImageMap[imageID])
+				result.Retained = append(result.Retained, imageName(idToImageMap[imageID]))
 				continue
 			}
 
-			if err := c.DeleteImage(backgroundContext, imageID); err != nil {
+			if policy.highUsage > 0 && used <= policy.lowUsage {
+				log.Info("image is retained, image filesystem usage is below the low watermark", "imageID", imageID, "name", idToImageMap[imageID])
+				result.Retained = append(result.Retained, imageName(idToImageMap[imageID]))
+				continue
+			}
+
+			if name := imageName(idToImageMap[imageID]); quarantined(name, idToImageMap[imageID]) {
+				log.Info("image is quarantined", "imageID", imageID, "name", idToImageMap[imageID])
+				result.Quarantined = append(result.Quarantined, name)
+				continue
+			}
+
+			if err := deleteImage(imageID); err != nil {
 				success = false
 				log.Error(err, "error removing image", "imageID", imageID, "name", idToImageMap[imageID])
+				result.Errors = append(result.Errors, unversioned.ImageError{Image: imageName(idToImageMap[imageID]), Reason: err.Error()})
 				continue
 			}
 
-			log.Info("removed image", "digest", imageID)
+			log.Info("removed image", "digest", imageID, "dryRun", dryRun)
 			deletedImages[imageID] = struct{}{}
-			removed++
+			result.Removed = append(result.Removed, imageName(idToImageMap[imageID]))
+			result.RemovedCount++
+			result.RemovedBytes += int64(sizes[imageID])
+
+			// the stats are only refreshed periodically by the kubelet, so
+			// estimate the new usage from the image size instead.
+			if sizes[imageID] < used {
+				used -= sizes[imageID]
+			} else {
+				used = 0
+			}
+		}
+
+		running := make(map[string]struct{}, len(runningImages))
+		for _, imageID := range runningImages {
+			if _, ok := running[imageID]; ok {
+				continue
+			}
+			running[imageID] = struct{}{}
+			result.Running = append(result.Running, imageName(idToImageMap[imageID]))
 		}
+
 		if success {
 			log.Info("prune successful")
 		} else {
@@ -125,5 +216,171 @@ func removeImages(c cri.Eraser, targetImages []string) (int, error) {
 		}
 	}
 
-	return removed, nil
+	result.Exclusions = exclusions.Counts()
+
+	sort.Strings(result.Removed)
+	sort.Strings(result.Running)
+	sort.Strings(result.Excluded)
+	sort.Strings(result.Retained)
+	sort.Strings(result.NotPresent)
+	sort.Strings(result.Quarantined)
+	sort.Slice(result.Errors, func(i, j int) bool { return result.Errors[i].Image < result.Errors[j].Image })
+
+	return result, nil
+}
+
+// prunePolicy limits which non-running images are pruned. Zero values disable
+// the corresponding condition.
+type prunePolicy struct {
+	unusedFor time.Duration
+	olderThan time.Duration
+
+	// pruning only starts once the image filesystem uses at least highUsage
+	// bytes, and stops once it is estimated to use at most lowUsage bytes.
+	// used is what the filesystem used when the eraser started, images, logs
+	// and volumes alike.
+	highUsage uint64
+	lowUsage  uint64
+	used      uint64
+}
+
+// imageTimes returns when each non-running image was created and last used.
+// Images that no container has used count as last used when first seen on
+// the node, and their last use is unknown if that was not recorded. The
+// creation time is when the image was built, so it says nothing of its use.
+func (p prunePolicy) imageTimes(ctx context.Context, c cri.Eraser, containers []*v1.Container, runningImages map[string]string, allImages []unversioned.Image, firstSeen map[string]time.Time) (created, lastUsed map[string]time.Time) {
+	if p.unusedFor <= 0 && p.olderThan <= 0 && p.highUsage == 0 {
+		return nil, nil
+	}
+
+	created = make(map[string]time.Time, len(allImages))
+	for _, img := range allImages {
+		if _, isRunning := runningImages[img.ImageID]; isRunning {
+			continue
+		}
+
+		resp, err := c.ImageStatus(ctx, img.ImageID)
+		if err != nil {
+			log.Error(err, "error getting image status", "imageID", img.ImageID, "name", img.Names)
+			continue
+		}
+
+		if t, ok := util.ImageCreatedAt(resp); ok {
+			created[img.ImageID] = t
+		}
+	}
+
+	lastUsed = util.GetImageLastUsed(containers)
+	for imageID, t := range firstSeen {
+		if _, ok := lastUsed[imageID]; !ok {
+			lastUsed[imageID] = t
+		}
+	}
+
+	return created, lastUsed
+}
+
+// filter returns the non-running images that the policy allows pruning.
+func (p prunePolicy) filter(runningImages map[string]string, allImages []unversioned.Image, idToImageMap map[string]unversioned.Image, created, lastUsed map[string]time.Time) map[string]string {
+	prunable := util.GetNonRunningImages(runningImages, allImages, idToImageMap)
+
+	now := time.Now()
+	if p.unusedFor > 0 {
+		prunable = intersect(prunable, util.GetNonRunningImagesBefore(runningImages, allImages, idToImageMap, lastUsed, now.Add(-p.unusedFor)))
+	}
+
+	if p.olderThan > 0 {
+		prunable = intersect(prunable, util.GetNonRunningImagesBefore(runningImages, allImages, idToImageMap, created, now.Add(-p.olderThan)))
+	}
+
+	return prunable
+}
+
+// pruneOrder returns the IDs of nonRunningImages, least recently used first.
+// Images whose last use is unknown come last, oldest first.
+func pruneOrder(nonRunningImages map[string]string, lastUsed, created map[string]time.Time) []string {
+	seen := make(map[string]struct{}, len(nonRunningImages))
+	ids := make([]string, 0, len(nonRunningImages))
+	for _, imageID := range nonRunningImages {
+		if _, ok := seen[imageID]; ok {
+			continue
+		}
+		seen[imageID] = struct{}{}
+		ids = append(ids, imageID)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		ti, iok := lastUsed[ids[i]]
+		tj, jok := lastUsed[ids[j]]
+		if !iok && !jok {
+			ti, iok = created[ids[i]]
+			tj, jok = created[ids[j]]
+		}
+		switch {
+		case iok && jok && !ti.Equal(tj):
+			return ti.Before(tj)
+		case iok != jok:
+			return iok
+		default:
+			return ids[i] < ids[j]
+		}
+	})
+
+	return ids
+}
+
+func intersect(a, b map[string]string) map[string]string {
+	out := make(map[string]string, len(a))
+	for k, v := range a {
+		if _, ok := b[k]; ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// imageName returns the most readable reference for img.
+func imageName(img unversioned.Image) string {
+	if len(img.Names) > 0 {
+		return img.Names[0]
+	}
+	if len(img.Digests) > 0 {
+		return img.Digests[0]
+	}
+	return img.ImageID
+}
+
+// quarantined reports whether img, given as ref, is kept until the manager
+// releases it. Releasing any of its references, or its ID, releases it.
+func quarantined(ref string, img unversioned.Image) bool {
+	if released == nil {
+		return false
+	}
+	refs := append([]string{ref, img.ImageID}, img.Names...)
+	for _, r := range append(refs, img.Digests...) {
+		if _, ok := released[r]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// excludedByPolicy reports whether img matches the exclude policy. Images
+// are kept when the policy fails to evaluate.
+func excludedByPolicy(img imagepolicy.Image) bool {
+	excluded, err := selector.Excluded(img, node)
+	if err != nil {
+		log.Error(err, "error evaluating exclude policy", "imageID", img.ImageID)
+	}
+	return excluded
+}
+
+// includedByPolicy reports whether img matches the include policy, which
+// only restricts pruning.
+func includedByPolicy(img imagepolicy.Image) bool {
+	included, err := selector.Included(img, node)
+	if err != nil {
+		log.Error(err, "error evaluating include policy", "imageID", img.ImageID)
+	}
+	return included
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+// firstSeenFile is the file, in the state directory on the node, recording
+// when the eraser first saw each image.
+const firstSeenFile = "first-seen.json"
+
+// recordFirstSeen returns when each image was first seen on the node, and
+// records images seen for the first time at now. The runtime does not report
+// when an image was pulled, so this stands in for the last use of images that
+// no container has used. Images that are gone are forgotten. It returns nil
+// when no state directory is set.
+func recordFirstSeen(dir string, allImages []unversioned.Image, now time.Time) map[string]time.Time {
+	if dir == "" {
+		return nil
+	}
+	path := filepath.Join(dir, firstSeenFile)
+
+	previous := make(map[string]time.Time)
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		log.Error(err, "unable to read first seen times", "path", path)
+	default:
+		if err := json.Unmarshal(data, &previous); err != nil {
+			log.Error(err, "unable to parse first seen times", "path", path)
+		}
+	}
+
+	seen := make(map[string]time.Time, len(allImages))
+	for _, img := range allImages {
+		t, ok := previous[img.ImageID]
+		if !ok || t.After(now) {
+			t = now
+		}
+		seen[img.ImageID] = t
+	}
+
+	if err := writeFirstSeen(path, seen); err != nil {
+		log.Error(err, "unable to record first seen times", "path", path)
+	}
+	return seen
+}
+
+func writeFirstSeen(path string, seen map[string]time.Time) error {
+	data, err := json.Marshal(seen)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash never leaves a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -17,6 +18,7 @@ type testLogger interface {
 type testClient struct {
 	containers []*v1.Container
 	images     []*v1.Image
+	created    map[string]time.Time
 	t          testLogger
 }
 
@@ -114,6 +116,16 @@ func (c *testClient) DeleteImage(_ context.Context, image string) (err error) {
 	return errImageNotRemoved
 }
 
+func (c *testClient) ImageStatus(_ context.Context, image string) (*v1.ImageStatusResponse, error) {
+	created, ok := c.created[image]
+	if !ok {
+		return &v1.ImageStatusResponse{}, nil
+	}
+
+	info := fmt.Sprintf(`{"imageSpec":{"created":%q}}`, created.Format(time.RFC3339Nano))
+	return &v1.ImageStatusResponse{Info: map[string]string{"info": info}}, nil
+}
+
 func testEqImages(a, b []*v1.Image) bool {
 	if len(a) != len(b) {
 		return false
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "eraser"
+
+	LabelNode    = "node"
+	LabelScanner = "scanner"
+	LabelOwner   = "owner"
+	LabelResult  = "result"
+
+	// ResultSucceeded, ResultFailed and ResultSkipped are the values of the
+	// result label of the pods of an ImageJob.
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+	ResultSkipped   = "skipped"
+)
+
+// The metrics served by the manager on its /metrics endpoint. They are
+// aggregated from the results reported by the pods of each ImageJob, so that
+// they can be scraped without an OpenTelemetry collector. Labels are kept to
+// the node, the scanner, the owner of the job and its result.
+var (
+	imagesRemoved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "images_removed_total",
+		Help:      "Number of images removed, by node. Dry runs are not counted.",
+	}, []string{LabelNode})
+
+	removedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "removed_bytes_total",
+		Help:      "Size in bytes of the images removed, by node. Dry runs are not counted.",
+	}, []string{LabelNode})
+
+	removalErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_removal_errors_total",
+		Help:      "Number of images that could not be removed, by node.",
+	}, []string{LabelNode})
+
+	imagesScanned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "images_scanned_total",
+		Help:      "Number of images given to a scanner.",
+	}, []string{LabelScanner})
+
+	imagesNonCompliant = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "images_noncompliant_total",
+		Help:      "Number of images a scanner found non-compliant.",
+	}, []string{LabelScanner})
+
+	scanFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_scan_failures_total",
+		Help:      "Number of images a scanner failed to scan.",
+	}, []string{LabelScanner})
+
+	scanAborts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scan_aborts_total",
+		Help:      "Number of scans of a node that a scanner aborted or failed to complete.",
+	}, []string{LabelScanner})
+
+	scanDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scan_duration_seconds",
+		Help:      "Time a scanner took to scan the images of a node.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{LabelScanner})
+
+	imageJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "imagejobs_total",
+		Help:      "Number of ImageJobs that finished, by owner and result.",
+	}, []string{LabelOwner, LabelResult})
+
+	imageJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "imagejob_duration_seconds",
+		Help:      "Time from the creation of an ImageJob until its pods finished, by owner and result.",
+		Buckets:   prometheus.ExponentialBuckets(10, 2, 10),
+	}, []string{LabelOwner, LabelResult})
+
+	imageJobPods = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "imagejob_pods_total",
+		Help:      "Number of nodes of finished ImageJobs, by the result of their pod.",
+	}, []string{LabelResult})
+)
+
+// Register registers the metrics aggregated by the manager with r.
+func Register(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{
+		imagesRemoved,
+		removedBytes,
+		removalErrors,
+		imagesScanned,
+		imagesNonCompliant,
+		scanFailures,
+		scanAborts,
+		scanDuration,
+		imageJobs,
+		imageJobDuration,
+		imageJobPods,
+	} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordRemoval records what the eraser did on node.
+func RecordRemoval(node string, removed, bytes, errors int64) {
+	imagesRemoved.WithLabelValues(node).Add(float64(removed))
+	removedBytes.WithLabelValues(node).Add(float64(bytes))
+	removalErrors.WithLabelValues(node).Add(float64(errors))
+}
+
+// RecordScan records the outcome of scanner on a node, which aborted if
+// aborted is set.
+func RecordScan(scanner string, scanned, nonCompliant, failed int64, duration time.Duration, aborted bool) {
+	imagesScanned.WithLabelValues(scanner).Add(float64(scanned))
+	imagesNonCompliant.WithLabelValues(scanner).Add(float64(nonCompliant))
+	scanFailures.WithLabelValues(scanner).Add(float64(failed))
+	if aborted {
+		scanAborts.WithLabelValues(scanner).Inc()
+	}
+	scanDuration.WithLabelValues(scanner).Observe(duration.Seconds())
+}
+
+// RecordImageJob records an ImageJob that finished with result, and the
+// results of its pods.
+func RecordImageJob(owner, result string, duration time.Duration, succeeded, failed, skipped int) {
+	imageJobs.WithLabelValues(owner, result).Inc()
+	imageJobDuration.WithLabelValues(owner, result).Observe(duration.Seconds())
+
+	imageJobPods.WithLabelValues(ResultSucceeded).Add(float64(succeeded))
+	imageJobPods.WithLabelValues(ResultFailed).Add(float64(failed))
+	imageJobPods.WithLabelValues(ResultSkipped).Add(float64(skipped))
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegister(t *testing.T) {
+	r := prometheus.NewRegistry()
+	if err := Register(r); err != nil {
+		t.Fatalf("could not register metrics: %v", err)
+	}
+	if err := Register(r); err == nil {
+		t.Fatal("expected an error registering the metrics twice")
+	}
+}
+
+func TestRecordPrometheus(t *testing.T) {
+	RecordRemoval("node1", 3, 300, 1)
+	RecordRemoval("node1", 2, 200, 0)
+	if got := testutil.ToFloat64(imagesRemoved.WithLabelValues("node1")); got != 5 {
+		t.Errorf("unexpected images removed: expected: 5, got: %v", got)
+	}
+	if got := testutil.ToFloat64(removedBytes.WithLabelValues("node1")); got != 500 {
+		t.Errorf("unexpected bytes removed: expected: 500, got: %v", got)
+	}
+
+	RecordScan("trivy", 10, 4, 1, 90*time.Second, false)
+	if got := testutil.ToFloat64(imagesNonCompliant.WithLabelValues("trivy")); got != 4 {
+		t.Errorf("unexpected non-compliant images: expected: 4, got: %v", got)
+	}
+	RecordScan("trivy", 3, 0, 0, time.Second, true)
+	if got := testutil.ToFloat64(scanAborts.WithLabelValues("trivy")); got != 1 {
+		t.Errorf("unexpected aborted scans: expected: 1, got: %v", got)
+	}
+
+	RecordImageJob("imagelist-controller", ResultSucceeded, time.Minute, 2, 1, 0)
+	expected := `
+# HELP eraser_imagejobs_total Number of ImageJobs that finished, by owner and result.
+# TYPE eraser_imagejobs_total counter
+eraser_imagejobs_total{owner="imagelist-controller",result="succeeded"} 1
+`
+	if err := testutil.CollectAndCompare(imageJobs, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.ToFloat64(imageJobPods.WithLabelValues(ResultFailed)); got != 1 {
+		t.Errorf("unexpected failed pods: expected: 1, got: %v", got)
+	}
+
+	if problems, err := testutil.CollectAndLint(scanDuration); err != nil || len(problems) > 0 {
+		t.Errorf("unexpected lint problems: %v %v", problems, err)
+	}
+}
@@ -0,0 +1,197 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+// Client calls the pipeline service on behalf of a stage.
+type Client struct {
+	conn    *grpc.ClientConn
+	stage   Stage
+	scanner string
+}
+
+// Dial connects to the pipeline served at the unix socket path, waiting for
+// the collector to start serving it until ctx is done.
+func Dial(ctx context.Context, path string, stage Stage) (*Client, error) {
+	conn, err := grpc.DialContext(ctx, "unix://"+path,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{conn: conn, stage: stage}, nil
+}
+
+// DialScanner connects to the pipeline on behalf of the scanner called name.
+func DialScanner(ctx context.Context, path, name string) (*Client, error) {
+	c, err := Dial(ctx, path, StageScanner)
+	if err != nil {
+		return nil, err
+	}
+
+	c.scanner = name
+	return c, nil
+}
+
+// Close closes the connection to the pipeline.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Images returns the images collected on the node.
+func (c *Client) Images(ctx context.Context) ([]unversioned.Image, error) {
+	images := []unversioned.Image{}
+	err := follow(ctx, c, "ListImages", func(img *unversioned.Image) error {
+		images = append(images, *img)
+		return nil
+	})
+	return images, err
+}
+
+// WatchVerdicts calls fn with every verdict of the scanner until the scanner
+// is done. It fails if the pipeline is aborted.
+func (c *Client) WatchVerdicts(ctx context.Context, fn func(Verdict) error) error {
+	return follow(ctx, c, "WatchVerdicts", func(v *Verdict) error {
+		return fn(*v)
+	})
+}
+
+// WatchResults calls fn with every result of the eraser until the eraser is
+// done. It fails if the pipeline is aborted.
+func (c *Client) WatchResults(ctx context.Context, fn func(RemovalResult) error) error {
+	return follow(ctx, c, "WatchResults", func(r *RemovalResult) error {
+		return fn(*r)
+	})
+}
+
+// PublishVerdicts opens the stream of the verdicts of the scanner. Each
+// scanner may publish its verdicts once.
+func (c *Client) PublishVerdicts(ctx context.Context) (*Publisher, error) {
+	if c.scanner != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, scannerMetadataKey, c.scanner)
+	}
+	return c.publish(ctx, "PublishVerdicts")
+}
+
+// PublishResults sends the outcome of every image the eraser was asked to
+// remove, and marks the pipeline as complete.
+func (c *Client) PublishResults(ctx context.Context, results []RemovalResult) error {
+	p, err := c.publish(ctx, "PublishResults")
+	if err != nil {
+		return err
+	}
+
+	for i := range results {
+		if err := p.send(&results[i]); err != nil {
+			return err
+		}
+	}
+
+	return p.Close()
+}
+
+// Abort stops the pipeline because of cause. The other stages fail with its
+// message instead of waiting for results that will not come.
+func (c *Client) Abort(ctx context.Context, cause error) error {
+	req := &AbortRequest{Stage: c.stage, Message: cause.Error()}
+	return c.conn.Invoke(ctx, method("Abort"), req, &Empty{})
+}
+
+// Publisher streams messages to the pipeline.
+type Publisher struct {
+	stream grpc.ClientStream
+}
+
+// Send publishes verdicts.
+func (p *Publisher) Send(verdicts ...Verdict) error {
+	for i := range verdicts {
+		if err := p.send(&verdicts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Publisher) send(m interface{}) error {
+	err := p.stream.SendMsg(m)
+	if errors.Is(err, io.EOF) {
+		// the server ended the stream; its status has the reason
+		return p.stream.RecvMsg(&Empty{})
+	}
+	return err
+}
+
+// Close marks the stream as complete.
+func (p *Publisher) Close() error {
+	if err := p.stream.CloseSend(); err != nil {
+		return err
+	}
+	return p.stream.RecvMsg(&Empty{})
+}
+
+func (c *Client) publish(ctx context.Context, name string) (*Publisher, error) {
+	stream, err := c.conn.NewStream(ctx, streamDesc(name), method(name))
+	if err != nil {
+		return nil, err
+	}
+	return &Publisher{stream: stream}, nil
+}
+
+// follow opens the server stream name, and calls fn with every message until
+// the stream ends.
+func follow[T any](ctx context.Context, c *Client, name string, fn func(*T) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.conn.NewStream(ctx, streamDesc(name), method(name))
+	if err != nil {
+		return err
+	}
+
+	if err := stream.SendMsg(&StreamRequest{Stage: c.stage, Scanner: c.scanner}); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+
+	for {
+		m := new(T)
+		err := stream.RecvMsg(m)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+}
+
+func method(name string) string {
+	return "/" + ServiceName + "/" + name
+}
+
+func streamDesc(name string) *grpc.StreamDesc {
+	for i := range streamDescs {
+		if streamDescs[i].StreamName == name {
+			return &streamDescs[i]
+		}
+	}
+	panic("unknown pipeline stream " + name)
+}
@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+// combiner decides the verdict of each image from the verdicts of several
+// scanners. An image is non-compliant once the weights of the scanners
+// finding it non-compliant add up to the threshold. Images a scanner sends no
+// verdict for are compliant for that scanner.
+//
+// Every rule is a threshold: any scanner is a threshold of 1 with weights of
+// 1, and all scanners is a threshold of the number of scanners.
+type combiner struct {
+	mu        sync.Mutex
+	weights   map[string]int
+	threshold int
+
+	claimed  map[string]bool
+	open     map[string]bool
+	images   map[string]unversioned.Image
+	verdicts map[string]map[string]Status
+	decided  map[string]bool
+
+	// out receives the combined verdict of every image once it is decided.
+	out *feed[Verdict]
+}
+
+func newCombiner(images []unversioned.Image, weights map[string]int, threshold int, out *feed[Verdict]) *combiner {
+	c := &combiner{
+		weights:   weights,
+		threshold: threshold,
+		claimed:   make(map[string]bool, len(weights)),
+		open:      make(map[string]bool, len(weights)),
+		images:    make(map[string]unversioned.Image, len(images)),
+		verdicts:  make(map[string]map[string]Status, len(images)),
+		decided:   make(map[string]bool, len(images)),
+		out:       out,
+	}
+
+	for name := range weights {
+		c.open[name] = true
+	}
+	for _, img := range images {
+		c.images[img.ImageID] = img
+	}
+
+	return c
+}
+
+// resolve returns the scanner called name. A scanner without a name is the
+// only scanner, if there is one.
+func (c *combiner) resolve(name string) (string, error) {
+	if _, ok := c.weights[name]; ok {
+		return name, nil
+	}
+	if name == "" && len(c.weights) == 1 {
+		for only := range c.weights {
+			return only, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scanner %q", name)
+}
+
+// claim reserves the verdicts of scanner for a single stream.
+func (c *combiner) claim(scanner string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.claimed[scanner] {
+		return false
+	}
+	c.claimed[scanner] = true
+	return true
+}
+
+// add records the verdict of scanner, and publishes the combined verdict of
+// the image if it is decided.
+func (c *combiner) add(scanner string, v Verdict) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := v.Image.ImageID
+	if _, ok := c.images[id]; !ok {
+		c.images[id] = v.Image
+	}
+	if c.verdicts[id] == nil {
+		c.verdicts[id] = make(map[string]Status, len(c.weights))
+	}
+	c.verdicts[id][scanner] = v.Status
+
+	c.decide(id)
+}
+
+// done marks scanner as complete. Once every scanner is, every image is
+// decided and the combined verdicts are complete.
+func (c *combiner) done(scanner string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.open[scanner] {
+		return
+	}
+	delete(c.open, scanner)
+
+	for id := range c.images {
+		c.decide(id)
+	}
+
+	if len(c.open) == 0 {
+		c.out.close(nil)
+	}
+}
+
+// decide publishes the combined verdict of the image, unless it is already
+// decided or depends on scanners that are not done.
+func (c *combiner) decide(id string) {
+	if c.decided[id] {
+		return
+	}
+
+	nonCompliant, pending := 0, 0
+	failed := false
+	for scanner, weight := range c.weights {
+		status, ok := c.verdicts[id][scanner]
+		switch {
+		case !ok && c.open[scanner]:
+			pending += weight
+		case status == StatusNonCompliant:
+			nonCompliant += weight
+		case status == StatusFailed:
+			failed = true
+		}
+	}
+
+	var combined Status
+	switch {
+	case nonCompliant >= c.threshold:
+		combined = StatusNonCompliant
+	case nonCompliant+pending >= c.threshold:
+		// the scanners that are not done may still tip the balance
+		return
+	case failed:
+		combined = StatusFailed
+	default:
+		combined = StatusCompliant
+	}
+
+	c.decided[id] = true
+	c.out.publish(Verdict{Image: c.images[id], Status: combined})
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// feed is an append-only list of messages that any number of streams follow
+// until it is closed.
+type feed[T any] struct {
+	mu      sync.Mutex
+	items   []T
+	closed  bool
+	err     error
+	claimed bool
+	// changed is closed and replaced whenever the feed changes.
+	changed chan struct{}
+}
+
+func newFeed[T any]() *feed[T] {
+	return &feed[T]{changed: make(chan struct{})}
+}
+
+// claim reserves the feed for a single publisher.
+func (f *feed[T]) claim() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.claimed || f.closed {
+		return false
+	}
+	f.claimed = true
+	return true
+}
+
+func (f *feed[T]) publish(items ...T) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+	f.items = append(f.items, items...)
+	f.notify()
+}
+
+// close ends the feed. A nil err means that every message was published.
+// Only the first call has an effect.
+func (f *feed[T]) close(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return
+	}
+	f.closed = true
+	f.err = err
+	f.notify()
+}
+
+func (f *feed[T]) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *feed[T]) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.items)
+}
+
+// follow calls send with every message of the feed, waiting for new ones until
+// the feed is closed. It returns the error the feed was closed with.
+func (f *feed[T]) follow(ctx context.Context, send func(T) error) error {
+	next := 0
+	for {
+		f.mu.Lock()
+		items := f.items[next:]
+		closed, err, changed := f.closed, f.err, f.changed
+		f.mu.Unlock()
+
+		for _, item := range items {
+			if err := send(item); err != nil {
+				return err
+			}
+		}
+		next += len(items)
+
+		if closed {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// wait blocks until the feed is closed, and returns its error.
+func (f *feed[T]) wait(ctx context.Context) error {
+	return f.follow(ctx, func(T) error { return nil })
+}
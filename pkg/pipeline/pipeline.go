@@ -0,0 +1,170 @@
+// Package pipeline connects the collector, scanner and eraser containers of
+// an ImageJob pod with a gRPC service on a unix socket in their shared
+// volume.
+//
+// The collector serves the images it found. Each scanner streams verdicts for
+// them, which the collector combines into a single verdict per image. The
+// eraser streams back what it did with the images it was asked to remove.
+// Any container can abort the pipeline, which fails the streams of the others
+// with its error.
+//
+// Messages are encoded as JSON rather than protocol buffers. The service and
+// messages are not generated from a .proto: the wire format is documented as
+// a contract in docs/docs/custom-scanner.md, and must change with it.
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+const (
+	// SocketPath is where the collector serves the pipeline.
+	SocketPath = "/run/eraser.sh/shared-data/pipeline.sock"
+
+	// ServiceName is the versioned name of the gRPC service. Incompatible
+	// changes to the messages must come with a new version.
+	ServiceName = "eraser.pipeline.v1.Pipeline"
+
+	// ScannerNameEnv is set to the name of each scanner container, which
+	// the scanner sends with its verdicts.
+	ScannerNameEnv = "ERASER_SCANNER_NAME"
+
+	codecName = "json"
+)
+
+// streamDescs are the streaming calls of the service.
+var streamDescs = []grpc.StreamDesc{
+	{StreamName: "ListImages", ServerStreams: true},
+	{StreamName: "PublishVerdicts", ClientStreams: true},
+	{StreamName: "WatchVerdicts", ServerStreams: true},
+	{StreamName: "PublishResults", ClientStreams: true},
+	{StreamName: "WatchResults", ServerStreams: true},
+}
+
+// Stage names a container of the pipeline.
+type Stage string
+
+const (
+	StageCollector Stage = "collector"
+	StageScanner   Stage = "scanner"
+	StageEraser    Stage = "eraser"
+)
+
+// Status is the outcome of scanning an image.
+type Status string
+
+const (
+	// StatusNonCompliant images are removed.
+	StatusNonCompliant Status = "NonCompliant"
+	// StatusCompliant images are kept.
+	StatusCompliant Status = "Compliant"
+	// StatusFailed images could not be scanned and are kept.
+	StatusFailed Status = "Failed"
+	// StatusUnscanned images were collected without a scanner, and are
+	// removed.
+	StatusUnscanned Status = "Unscanned"
+)
+
+// Outcome is what the eraser did with an image.
+type Outcome string
+
+const (
+	OutcomeRemoved     Outcome = "Removed"
+	OutcomeRunning     Outcome = "Running"
+	OutcomeExcluded    Outcome = "Excluded"
+	OutcomeRetained    Outcome = "Retained"
+	OutcomeNotPresent  Outcome = "NotPresent"
+	OutcomeQuarantined Outcome = "Quarantined"
+	OutcomeError       Outcome = "Error"
+)
+
+type (
+	// StreamRequest opens a stream on behalf of a stage.
+	StreamRequest struct {
+		Stage Stage `json:"stage"`
+		// Scanner names the scanner of the scanner stage.
+		Scanner string `json:"scanner,omitempty"`
+	}
+
+	// Verdict is the scan status of an image.
+	Verdict struct {
+		Image  unversioned.Image `json:"image"`
+		Status Status            `json:"status"`
+	}
+
+	// RemovalResult is the outcome of removing an image.
+	RemovalResult struct {
+		Image   string  `json:"image"`
+		Outcome Outcome `json:"outcome"`
+		Reason  string  `json:"reason,omitempty"`
+	}
+
+	// AbortRequest stops the pipeline because a stage failed.
+	AbortRequest struct {
+		Stage   Stage  `json:"stage"`
+		Message string `json:"message"`
+	}
+
+	// Empty is the response of calls that only return a status.
+	Empty struct{}
+)
+
+// Remove reports whether the eraser should remove the image of v.
+func (v Verdict) Remove() bool {
+	return v.Status == StatusNonCompliant || v.Status == StatusUnscanned
+}
+
+// RemovalResults lists the outcome of every image in result.
+func RemovalResults(result *unversioned.NodeResult) []RemovalResult {
+	var results []RemovalResult
+	for _, group := range []struct {
+		images  []string
+		outcome Outcome
+	}{
+		{result.Removed, OutcomeRemoved},
+		{result.Running, OutcomeRunning},
+		{result.Excluded, OutcomeExcluded},
+		{result.Retained, OutcomeRetained},
+		{result.NotPresent, OutcomeNotPresent},
+		{result.Quarantined, OutcomeQuarantined},
+	} {
+		for _, img := range group.images {
+			results = append(results, RemovalResult{Image: img, Outcome: group.outcome})
+		}
+	}
+
+	for _, e := range result.Errors {
+		results = append(results, RemovalResult{Image: e.Image, Outcome: OutcomeError, Reason: e.Reason})
+	}
+
+	return results
+}
+
+// jsonCodec encodes the messages of the service, which are plain structs, as
+// JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid %T message: %w", v, err)
+	}
+	return nil
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
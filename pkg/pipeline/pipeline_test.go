@@ -0,0 +1,380 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+var testImages = []unversioned.Image{
+	{ImageID: "sha256:aaa", Names: []string{"docker.io/library/a:1"}},
+	{ImageID: "sha256:bbb", Names: []string{"docker.io/library/b:1"}},
+	{ImageID: "sha256:ccc", Names: []string{"docker.io/library/c:1"}},
+}
+
+// oneScanner is a single scanner of weight 1.
+var oneScanner = map[string]int{"trivy-scanner": 1}
+
+func startServer(t *testing.T, weights map[string]int, threshold int) (*Server, string) {
+	t.Helper()
+
+	// unix socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "pipeline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "pipeline.sock")
+	lis, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewServer(testImages, weights, threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(s.Stop)
+
+	return s, path
+}
+
+func dial(ctx context.Context, t *testing.T, path string, stage Stage) *Client {
+	t.Helper()
+
+	c, err := Dial(ctx, path, stage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+// runEraser removes the images the scanner found non-compliant, and
+// publishes the results.
+func runEraser(ctx context.Context, c *Client) ([]string, error) {
+	var removed []string
+	err := c.WatchVerdicts(ctx, func(v Verdict) error {
+		if v.Remove() {
+			removed = append(removed, v.Image.ImageID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := unversioned.NodeResult{Removed: removed}
+	return removed, c.PublishResults(ctx, RemovalResults(&result))
+}
+
+func TestPipeline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s, path := startServer(t, oneScanner, 1)
+	scanner := dial(ctx, t, path, StageScanner)
+	eraser := dial(ctx, t, path, StageEraser)
+
+	type eraserOutput struct {
+		removed []string
+		err     error
+	}
+	eraserDone := make(chan eraserOutput)
+	go func() {
+		removed, err := runEraser(ctx, eraser)
+		eraserDone <- eraserOutput{removed, err}
+	}()
+
+	images, err := scanner.Images(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != len(testImages) {
+		t.Fatalf("expected %d images, got %d", len(testImages), len(images))
+	}
+
+	p, err := scanner.PublishVerdicts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Send(
+		Verdict{Image: images[0], Status: StatusNonCompliant},
+		Verdict{Image: images[1], Status: StatusCompliant},
+		Verdict{Image: images[2], Status: StatusFailed},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []RemovalResult
+	if err := scanner.WatchResults(ctx, func(r RemovalResult) error {
+		results = append(results, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := <-eraserDone
+	if out.err != nil {
+		t.Fatal(out.err)
+	}
+	if len(out.removed) != 1 || out.removed[0] != "sha256:aaa" {
+		t.Errorf("expected only sha256:aaa to be removed, got %v", out.removed)
+	}
+
+	if len(results) != 1 || results[0].Outcome != OutcomeRemoved {
+		t.Errorf("expected the scanner to see one removed image, got %v", results)
+	}
+
+	collected, err := s.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collected) != 1 {
+		t.Errorf("expected the collector to see one result, got %v", collected)
+	}
+}
+
+func TestPipelineScanDisabled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s, path := startServer(t, nil, 0)
+	eraser := dial(ctx, t, path, StageEraser)
+
+	removed, err := runEraser(ctx, eraser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != len(testImages) {
+		t.Errorf("expected every unscanned image to be removed, got %v", removed)
+	}
+
+	if _, err := s.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPipelineAbort(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s, path := startServer(t, oneScanner, 1)
+	scanner := dial(ctx, t, path, StageScanner)
+	eraser := dial(ctx, t, path, StageEraser)
+
+	eraserErr := make(chan error)
+	go func() {
+		_, err := runEraser(ctx, eraser)
+		eraserErr <- err
+	}()
+
+	p, err := scanner.PublishVerdicts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Send(Verdict{Image: testImages[0], Status: StatusNonCompliant}); err != nil {
+		t.Fatal(err)
+	}
+	if err := scanner.Abort(ctx, errors.New("unable to download vulnerability DB")); err != nil {
+		t.Fatal(err)
+	}
+
+	err = <-eraserErr
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("expected the eraser to be aborted, got %v", err)
+	}
+
+	if _, err := s.Wait(ctx); status.Code(err) != codes.Aborted {
+		t.Errorf("expected the collector to be aborted, got %v", err)
+	}
+}
+
+func TestPipelineScannerDisconnect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, path := startServer(t, oneScanner, 1)
+	eraser := dial(ctx, t, path, StageEraser)
+
+	scannerCtx, cancelScanner := context.WithCancel(ctx)
+	scanner := dial(ctx, t, path, StageScanner)
+	p, err := scanner.PublishVerdicts(scannerCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Send(Verdict{Image: testImages[0], Status: StatusNonCompliant}); err != nil {
+		t.Fatal(err)
+	}
+	cancelScanner()
+
+	// nothing is removed from a partial list of verdicts
+	if _, err := runEraser(ctx, eraser); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected the eraser to fail, got %v", err)
+	}
+}
+
+func TestPipelineChainedScanners(t *testing.T) {
+	weights := map[string]int{"trivy-scanner": 2, "license": 1, "signature": 1}
+
+	tests := []struct {
+		name      string
+		threshold int
+		removed   []string
+	}{
+		{name: "any", threshold: 1, removed: []string{"sha256:aaa", "sha256:bbb"}},
+		{name: "weighted", threshold: 2, removed: []string{"sha256:aaa", "sha256:bbb"}},
+		{name: "majority", threshold: 3, removed: []string{"sha256:aaa"}},
+		{name: "all", threshold: 4, removed: nil},
+	}
+
+	// sha256:aaa is non-compliant for trivy and license, sha256:bbb for
+	// trivy only, and signature checks fail for sha256:ccc
+	verdicts := map[string][]Verdict{
+		"trivy-scanner": {
+			{Image: testImages[0], Status: StatusNonCompliant},
+			{Image: testImages[1], Status: StatusNonCompliant},
+		},
+		"license": {
+			{Image: testImages[0], Status: StatusNonCompliant},
+		},
+		"signature": {
+			{Image: testImages[2], Status: StatusFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, path := startServer(t, weights, tt.threshold)
+			eraser := dial(ctx, t, path, StageEraser)
+
+			for name, vs := range verdicts {
+				scanner, err := DialScanner(ctx, path, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer scanner.Close()
+
+				p, err := scanner.PublishVerdicts(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := p.Send(vs...); err != nil {
+					t.Fatal(err)
+				}
+				if err := p.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			removed, err := runEraser(ctx, eraser)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(removed)
+			if fmt.Sprint(removed) != fmt.Sprint(tt.removed) {
+				t.Errorf("expected %v to be removed, got %v", tt.removed, removed)
+			}
+		})
+	}
+}
+
+func TestPipelineUnknownScanner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, path := startServer(t, map[string]int{"trivy-scanner": 1, "license": 1}, 1)
+
+	scanner, err := DialScanner(ctx, path, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scanner.Close()
+
+	p, err := scanner.PublishVerdicts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); status.Code(err) != codes.NotFound {
+		t.Errorf("expected an unknown scanner to be rejected, got %v", err)
+	}
+}
+
+func TestNewServerThreshold(t *testing.T) {
+	for _, threshold := range []int{0, 3} {
+		if _, err := NewServer(testImages, map[string]int{"a": 1, "b": 1}, threshold); err == nil {
+			t.Errorf("expected threshold %d to be rejected", threshold)
+		}
+	}
+}
+
+// TestWireFormat checks the messages against the documented pipeline
+// protocol, which scanners outside this repository implement.
+func TestWireFormat(t *testing.T) {
+	testCases := []struct {
+		name    string
+		message interface{}
+		wire    string
+	}{
+		{
+			name:    "stream request",
+			message: &StreamRequest{Stage: StageScanner, Scanner: "trivy"},
+			wire:    `{"stage":"scanner","scanner":"trivy"}`,
+		},
+		{
+			name:    "verdict",
+			message: &Verdict{Image: unversioned.Image{ImageID: "sha256:a", Names: []string{"alpine:3.7.3"}, Size: 10}, Status: StatusNonCompliant},
+			wire:    `{"image":{"image_id":"sha256:a","names":["alpine:3.7.3"],"size":10},"status":"NonCompliant"}`,
+		},
+		{
+			name:    "removal result",
+			message: &RemovalResult{Image: "sha256:a", Outcome: OutcomeError, Reason: "in use"},
+			wire:    `{"image":"sha256:a","outcome":"Error","reason":"in use"}`,
+		},
+		{
+			name:    "abort request",
+			message: &AbortRequest{Stage: StageEraser, Message: "failed"},
+			wire:    `{"stage":"eraser","message":"failed"}`,
+		},
+		{
+			name:    "empty",
+			message: &Empty{},
+			wire:    `{}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := jsonCodec{}.Marshal(tc.message)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != tc.wire {
+				t.Errorf("unexpected wire format: expected: %s, got: %s", tc.wire, data)
+			}
+		})
+	}
+}
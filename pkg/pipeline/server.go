@@ -0,0 +1,322 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+// scannerGracePeriod is how long the server waits for the scanners to read
+// the removal results once the eraser is done.
+const scannerGracePeriod = time.Minute
+
+// scannerMetadataKey names the scanner publishing verdicts.
+const scannerMetadataKey = "eraser-scanner"
+
+var log = logf.Log.WithName("pipeline")
+
+// Server is the pipeline service run by the collector.
+type Server struct {
+	images []unversioned.Image
+
+	// combiner is nil without scanners.
+	combiner *combiner
+	verdicts *feed[Verdict]
+	results  *feed[RemovalResult]
+
+	// scannersDone has a channel per scanner, closed once the scanner has
+	// read every removal result.
+	scannersDone map[string]chan struct{}
+	mu           sync.Mutex
+	scannersRead map[string]bool
+
+	grpcServer *grpc.Server
+}
+
+// NewServer returns a server for images, scanned by the scanners named by
+// the keys of weights. An image is removed once the weights of the scanners
+// finding it non-compliant add up to threshold. Without scanners, every image
+// is sent to the eraser as unscanned.
+func NewServer(images []unversioned.Image, weights map[string]int, threshold int) (*Server, error) {
+	s := &Server{
+		images:       images,
+		verdicts:     newFeed[Verdict](),
+		results:      newFeed[RemovalResult](),
+		scannersDone: make(map[string]chan struct{}, len(weights)),
+		scannersRead: make(map[string]bool, len(weights)),
+	}
+
+	if len(weights) == 0 {
+		for _, img := range images {
+			s.verdicts.publish(Verdict{Image: img, Status: StatusUnscanned})
+		}
+		s.verdicts.close(nil)
+	} else {
+		total := 0
+		for name, weight := range weights {
+			if weight < 0 {
+				return nil, fmt.Errorf("negative weight %d of scanner %q", weight, name)
+			}
+			total += weight
+			s.scannersDone[name] = make(chan struct{})
+		}
+		if threshold < 1 || threshold > total {
+			return nil, fmt.Errorf("verdict threshold %d must be between 1 and the total weight of the scanners, %d", threshold, total)
+		}
+		s.combiner = newCombiner(images, weights, threshold, s.verdicts)
+	}
+
+	s.grpcServer = grpc.NewServer()
+	s.grpcServer.RegisterService(s.serviceDesc(), s)
+
+	return s, nil
+}
+
+// Listen listens on the unix socket at path, replacing a stale socket.
+func Listen(path string) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the containers of the pod may run as different users
+	if err := os.Chmod(path, 0o666); err != nil {
+		lis.Close()
+		return nil, err
+	}
+
+	return lis, nil
+}
+
+// Serve serves the pipeline on lis until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpcServer.Serve(lis)
+}
+
+// Stop stops the server once the running calls are done.
+func (s *Server) Stop() {
+	s.grpcServer.GracefulStop()
+}
+
+// Wait blocks until the eraser has published its results and the scanners, if
+// any, have read them. It returns the results, or the error the pipeline was
+// aborted with.
+func (s *Server) Wait(ctx context.Context) ([]RemovalResult, error) {
+	var results []RemovalResult
+	err := s.results.follow(ctx, func(r RemovalResult) error {
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		return results, err
+	}
+
+	grace := time.After(scannerGracePeriod)
+	for name, done := range s.scannersDone {
+		select {
+		case <-done:
+		case <-grace:
+			log.Info("scanner did not read the removal results", "scanner", name, "timeout", scannerGracePeriod)
+			return results, nil
+		case <-ctx.Done():
+			return results, ctx.Err()
+		}
+	}
+
+	return results, nil
+}
+
+func (s *Server) abort(req *AbortRequest) {
+	log.Info("pipeline aborted", "stage", req.Stage, "message", req.Message)
+
+	err := status.Errorf(codes.Aborted, "%s: %s", req.Stage, req.Message)
+	s.verdicts.close(err)
+	s.results.close(err)
+}
+
+func (s *Server) listImages(req *StreamRequest, stream grpc.ServerStream) error {
+	log.V(1).Info("sending images", "stage", req.Stage, "images", len(s.images))
+	for i := range s.images {
+		if err := stream.SendMsg(&s.images[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) publishVerdicts(stream grpc.ServerStream) error {
+	if s.combiner == nil {
+		return status.Error(codes.FailedPrecondition, "scanning is disabled")
+	}
+
+	var name string
+	if md, ok := metadata.FromIncomingContext(stream.Context()); ok {
+		if names := md.Get(scannerMetadataKey); len(names) > 0 {
+			name = names[0]
+		}
+	}
+	scanner, err := s.combiner.resolve(name)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if !s.combiner.claim(scanner) {
+		return status.Errorf(codes.FailedPrecondition, "verdicts of scanner %q are already being published", scanner)
+	}
+
+	received := 0
+	for {
+		var v Verdict
+		err := stream.RecvMsg(&v)
+		if errors.Is(err, io.EOF) {
+			log.Info("received all verdicts", "scanner", scanner, "verdicts", received)
+			s.combiner.done(scanner)
+			return stream.SendMsg(&Empty{})
+		}
+		if err != nil {
+			// the scanner went away without finishing; nothing may be removed
+			// from a partial list
+			s.verdicts.close(status.Errorf(codes.Unavailable, "scanner %q disconnected: %v", scanner, err))
+			return err
+		}
+
+		received++
+		s.combiner.add(scanner, v)
+		log.V(1).Info("received verdict", "scanner", scanner, "image", v.Image.ImageID, "status", v.Status)
+	}
+}
+
+func (s *Server) watchVerdicts(req *StreamRequest, stream grpc.ServerStream) error {
+	log.V(1).Info("sending verdicts", "stage", req.Stage)
+	return s.verdicts.follow(stream.Context(), func(v Verdict) error {
+		return stream.SendMsg(&v)
+	})
+}
+
+func (s *Server) publishResults(stream grpc.ServerStream) error {
+	if !s.results.claim() {
+		return status.Error(codes.FailedPrecondition, "results are already being published")
+	}
+
+	for {
+		var r RemovalResult
+		err := stream.RecvMsg(&r)
+		if errors.Is(err, io.EOF) {
+			log.Info("received all removal results", "results", s.results.len())
+			s.results.close(nil)
+			return stream.SendMsg(&Empty{})
+		}
+		if err != nil {
+			s.results.close(status.Errorf(codes.Unavailable, "eraser disconnected: %v", err))
+			return err
+		}
+
+		s.results.publish(r)
+	}
+}
+
+func (s *Server) watchResults(req *StreamRequest, stream grpc.ServerStream) error {
+	err := s.results.follow(stream.Context(), func(r RemovalResult) error {
+		return stream.SendMsg(&r)
+	})
+	if req.Stage == StageScanner {
+		s.scannerRead(req.Scanner)
+	}
+	return err
+}
+
+// scannerRead records that the scanner called name has read the removal
+// results.
+func (s *Server) scannerRead(name string) {
+	if s.combiner == nil {
+		return
+	}
+	scanner, err := s.combiner.resolve(name)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.scannersRead[scanner] {
+		s.scannersRead[scanner] = true
+		close(s.scannersDone[scanner])
+	}
+}
+
+// serviceDesc describes the service to gRPC, with handlers calling s. It
+// follows the documented pipeline protocol, which clients outside this
+// package rely on.
+func (s *Server) serviceDesc() *grpc.ServiceDesc {
+	streams := map[string]grpc.StreamHandler{
+		"ListImages": func(_ interface{}, stream grpc.ServerStream) error {
+			req := &StreamRequest{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return s.listImages(req, stream)
+		},
+		"PublishVerdicts": func(_ interface{}, stream grpc.ServerStream) error {
+			return s.publishVerdicts(stream)
+		},
+		"WatchVerdicts": func(_ interface{}, stream grpc.ServerStream) error {
+			req := &StreamRequest{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return s.watchVerdicts(req, stream)
+		},
+		"PublishResults": func(_ interface{}, stream grpc.ServerStream) error {
+			return s.publishResults(stream)
+		},
+		"WatchResults": func(_ interface{}, stream grpc.ServerStream) error {
+			req := &StreamRequest{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return s.watchResults(req, stream)
+		},
+	}
+
+	desc := &grpc.ServiceDesc{
+		ServiceName: ServiceName,
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "Abort",
+				Handler: func(_ interface{}, _ context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+					req := &AbortRequest{}
+					if err := dec(req); err != nil {
+						return nil, err
+					}
+					s.abort(req)
+					return &Empty{}, nil
+				},
+			},
+		},
+	}
+
+	for _, stream := range streamDescs {
+		stream.Handler = streams[stream.StreamName]
+		desc.Streams = append(desc.Streams, stream)
+	}
+
+	return desc
+}
@@ -0,0 +1,330 @@
+// Package policy evaluates image selection rules written in the Common
+// Expression Language (CEL), such as
+//
+//	image.repo.startsWith("mcr.microsoft.com") && image.sizeBytes > quantity("1Gi") && !image.pinned
+//
+// Expressions see the image as `image` and the node as `node`, and may call
+// quantity() to turn a Kubernetes quantity such as "512Mi" into an int.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/Azure/eraser/api/unversioned"
+	util "github.com/Azure/eraser/pkg/utils"
+)
+
+const (
+	imageType = "eraser.Image"
+	nodeType  = "eraser.Node"
+
+	quantityFunction = "quantity"
+)
+
+// Image is the metadata a policy sees for an image as `image`.
+type Image struct {
+	unversioned.Image
+	SizeBytes int64
+	Pinned    bool
+}
+
+// Node is the node a policy is evaluated on, seen as `node`.
+type Node struct {
+	Name   string
+	Labels map[string]string
+}
+
+// fields declares the fields of `image` and `node`, so that expressions
+// referring to unknown fields or comparing them to the wrong type are
+// rejected when they are compiled.
+var fields = map[string]map[string]*exprpb.Type{
+	imageType: {
+		"id":        decls.String,
+		"name":      decls.String,
+		"names":     decls.NewListType(decls.String),
+		"digests":   decls.NewListType(decls.String),
+		"repo":      decls.String,
+		"tag":       decls.String,
+		"sizeBytes": decls.Int,
+		"pinned":    decls.Bool,
+	},
+	nodeType: {
+		"name":   decls.String,
+		"labels": decls.NewMapType(decls.String, decls.String),
+	},
+}
+
+// provider declares the image and node types on top of the CEL types. The
+// values are passed as maps, which CEL selects fields from.
+type provider struct {
+	ref.TypeProvider
+}
+
+func (p provider) FindType(typeName string) (*exprpb.Type, bool) {
+	if _, ok := fields[typeName]; ok {
+		return decls.NewTypeType(decls.NewObjectType(typeName)), true
+	}
+	return p.TypeProvider.FindType(typeName)
+}
+
+func (p provider) FindFieldType(messageType, fieldName string) (*ref.FieldType, bool) {
+	if f, ok := fields[messageType]; ok {
+		t, ok := f[fieldName]
+		if !ok {
+			return nil, false
+		}
+		return &ref.FieldType{Type: t}, true
+	}
+	return p.TypeProvider.FindFieldType(messageType, fieldName)
+}
+
+var env, envErr = newEnv()
+
+// newEnv returns the CEL environment policies are compiled in.
+func newEnv() (*cel.Env, error) {
+	registry, err := types.NewRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	return cel.NewEnv(
+		cel.CustomTypeProvider(provider{registry}),
+		cel.Variable("image", cel.ObjectType(imageType)),
+		cel.Variable("node", cel.ObjectType(nodeType)),
+		cel.Function(quantityFunction,
+			cel.Overload("quantity_string", []*cel.Type{cel.StringType}, cel.IntType,
+				cel.UnaryBinding(func(arg ref.Val) ref.Val {
+					q, err := parseQuantity(string(arg.(types.String)))
+					if err != nil {
+						return types.NewErr(err.Error())
+					}
+					return types.Int(q)
+				}),
+			),
+		),
+	)
+}
+
+// Policy is a compiled policy expression.
+type Policy struct {
+	source  string
+	program cel.Program
+}
+
+// Compile parses and type checks expr, which must evaluate to a bool.
+func Compile(expr string) (*Policy, error) {
+	if envErr != nil {
+		return nil, envErr
+	}
+
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("invalid policy %q: %w", expr, iss.Err())
+	}
+	if !ast.OutputType().IsAssignableType(cel.BoolType) {
+		return nil, fmt.Errorf("invalid policy %q: evaluates to %s, expected bool", expr, ast.OutputType())
+	}
+	if err := checkQuantities(ast.Expr()); err != nil {
+		return nil, fmt.Errorf("invalid policy %q: %w", expr, err)
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %q: %w", expr, err)
+	}
+	return &Policy{source: expr, program: program}, nil
+}
+
+// String returns the source of the policy.
+func (p *Policy) String() string {
+	return p.source
+}
+
+// Matches evaluates the policy for img on n.
+func (p *Policy) Matches(img Image, n Node) (bool, error) {
+	v, _, err := p.program.Eval(map[string]interface{}{
+		"image": imageValue(img),
+		"node":  nodeValue(n),
+	})
+	if err != nil {
+		return false, fmt.Errorf("evaluating policy %q: %w", p.source, err)
+	}
+
+	b, ok := v.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("policy %q evaluated to %s, expected bool", p.source, v.Type().TypeName())
+	}
+	return bool(b), nil
+}
+
+// Selector decides which images may be removed. Images must match the
+// include policy, if any, and must not match the exclude policy.
+type Selector struct {
+	include *Policy
+	exclude *Policy
+}
+
+// NewSelector compiles the include and exclude expressions. Either may be
+// empty.
+func NewSelector(include, exclude string) (*Selector, error) {
+	s := &Selector{}
+	var err error
+	if include != "" {
+		if s.include, err = Compile(include); err != nil {
+			return nil, err
+		}
+	}
+	if exclude != "" {
+		if s.exclude, err = Compile(exclude); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Included reports whether img matches the include policy. Without an
+// include policy every image is included. If the policy fails to evaluate,
+// the image is not included.
+func (s *Selector) Included(img Image, n Node) (bool, error) {
+	if s == nil || s.include == nil {
+		return true, nil
+	}
+	return s.include.Matches(img, n)
+}
+
+// Excluded reports whether img matches the exclude policy. If the policy
+// fails to evaluate, the image is excluded.
+func (s *Selector) Excluded(img Image, n Node) (bool, error) {
+	if s == nil || s.exclude == nil {
+		return false, nil
+	}
+	excluded, err := s.exclude.Matches(img, n)
+	if err != nil {
+		return true, err
+	}
+	return excluded, nil
+}
+
+// CurrentNode returns the node the process runs on, from the NODE_NAME and
+// NODE_LABELS environment variables set on eraser pods.
+func CurrentNode() (Node, error) {
+	n := Node{Name: os.Getenv("NODE_NAME"), Labels: map[string]string{}}
+	if labels := os.Getenv(util.EnvNodeLabels); labels != "" {
+		if err := json.Unmarshal([]byte(labels), &n.Labels); err != nil {
+			return n, fmt.Errorf("invalid %s: %w", util.EnvNodeLabels, err)
+		}
+	}
+	return n, nil
+}
+
+func imageValue(img Image) map[string]interface{} {
+	name := ""
+	if len(img.Names) > 0 {
+		name = img.Names[0]
+	}
+
+	repo := util.Repository(name)
+	named, _, _ := strings.Cut(name, "@")
+	tag := strings.TrimPrefix(strings.TrimPrefix(named, repo), ":")
+	if repo == "" && len(img.Digests) > 0 {
+		repo = util.Repository(img.Digests[0])
+	}
+
+	return map[string]interface{}{
+		"id":        img.ImageID,
+		"name":      name,
+		"names":     nonNil(img.Names),
+		"digests":   nonNil(img.Digests),
+		"repo":      repo,
+		"tag":       tag,
+		"sizeBytes": img.SizeBytes,
+		"pinned":    img.Pinned,
+	}
+}
+
+func nodeValue(n Node) map[string]interface{} {
+	labels := n.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return map[string]interface{}{
+		"name":   n.Name,
+		"labels": labels,
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func parseQuantity(s string) (int64, error) {
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q: %w", s, err)
+	}
+	return q.Value(), nil
+}
+
+// checkQuantities parses the literal arguments of quantity() in e, so that
+// a policy with an invalid quantity is rejected when it is compiled rather
+// than failing on every image.
+func checkQuantities(e *exprpb.Expr) error {
+	var check func(*exprpb.Expr) error
+	checkAll := func(exprs ...*exprpb.Expr) error {
+		for _, e := range exprs {
+			if err := check(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	check = func(e *exprpb.Expr) error {
+		switch k := e.GetExprKind().(type) {
+		case *exprpb.Expr_CallExpr:
+			call := k.CallExpr
+			if call.GetFunction() == quantityFunction && len(call.GetArgs()) == 1 {
+				if c := call.GetArgs()[0].GetConstExpr(); c != nil {
+					if _, err := parseQuantity(c.GetStringValue()); err != nil {
+						return err
+					}
+				}
+			}
+			if call.GetTarget() != nil {
+				if err := check(call.GetTarget()); err != nil {
+					return err
+				}
+			}
+			return checkAll(call.GetArgs()...)
+		case *exprpb.Expr_SelectExpr:
+			return check(k.SelectExpr.GetOperand())
+		case *exprpb.Expr_ListExpr:
+			return checkAll(k.ListExpr.GetElements()...)
+		case *exprpb.Expr_StructExpr:
+			for _, entry := range k.StructExpr.GetEntries() {
+				if err := checkAll(entry.GetMapKey(), entry.GetValue()); err != nil {
+					return err
+				}
+			}
+		case *exprpb.Expr_ComprehensionExpr:
+			c := k.ComprehensionExpr
+			return checkAll(c.GetIterRange(), c.GetAccuInit(), c.GetLoopCondition(), c.GetLoopStep(), c.GetResult())
+		}
+		return nil
+	}
+
+	return check(e)
+}
@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+var (
+	testImage = Image{
+		Image: unversioned.Image{
+			ImageID: "sha256:abc",
+			Names:   []string{"mcr.microsoft.com/oss/nginx:1.21"},
+			Digests: []string{"mcr.microsoft.com/oss/nginx@sha256:def"},
+		},
+		SizeBytes: 2 * 1024 * 1024 * 1024,
+	}
+	testNode = Node{
+		Name:   "node-1",
+		Labels: map[string]string{"kubernetes.io/os": "linux", "pool": "gpu"},
+	}
+)
+
+func TestMatches(t *testing.T) {
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`image.repo.startsWith("mcr.microsoft.com") && image.sizeBytes > quantity("1Gi") && !image.pinned`, true},
+		{`image.sizeBytes > quantity("4Gi")`, false},
+		{`image.tag == "1.21"`, true},
+		{`image.repo == 'mcr.microsoft.com/oss/nginx'`, true},
+		{`image.name.matches("^mcr\\.microsoft\\.com/.*:1\\.[0-9]+$")`, true},
+		{`image.names.exists(n, n.endsWith(":latest"))`, false},
+		{`image.digests.all(d, d.contains("@sha256:"))`, true},
+		{`size(image.names) == 1 && image.digests.size() == 1`, true},
+		{`"pool" in node.labels && node.labels["pool"] == "gpu"`, true},
+		{`"zone" in node.labels`, false},
+		{`node.name in ["node-1", "node-2"]`, true},
+		{`image.pinned ? false : 1 + 2 * 3 == 7`, true},
+		{`-(2 - 5) % 2 == 1 || image.sizeBytes / 0 == 1`, true},
+		{`(image.sizeBytes / quantity("1Mi")) >= 2048`, true},
+		{`has(node.labels.pool) && !has(node.labels.zone)`, true},
+	}
+
+	for _, tc := range cases {
+		p, err := Compile(tc.expr)
+		if err != nil {
+			t.Errorf("Compile(%s) returned error: %v", tc.expr, err)
+			continue
+		}
+
+		got, err := p.Matches(testImage, testNode)
+		if err != nil {
+			t.Errorf("Matches(%s) returned error: %v", tc.expr, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Matches(%s) = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`image.repo ==`,
+		`container.name == "x"`,
+		`image.repo.hasPrefix("x")`,
+		`image.repo.startsWith()`,
+		`unknown(image)`,
+		`"unterminated`,
+		`1Zz > 0`,
+		`image.names.exists(n, m == "x")`,
+		`(image.pinned`,
+		`image.repo`,
+		`image.sizeBytes > "1"`,
+		`image.missing == 1`,
+		`image.sizeBytes > 1Gi`,
+		`image.sizeBytes > quantity("1Zz")`,
+		`node.labels["pool"] == 1`,
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("Compile(%s) expected error", expr)
+		}
+	}
+}
+
+func TestEvaluationErrors(t *testing.T) {
+	for _, expr := range []string{
+		`node.labels["zone"] == "a"`,
+		`image.sizeBytes / 0 == 1`,
+		`image.name.matches("[")`,
+	} {
+		p, err := Compile(expr)
+		if err != nil {
+			t.Fatalf("Compile(%s) returned error: %v", expr, err)
+		}
+		if _, err := p.Matches(testImage, testNode); err == nil {
+			t.Errorf("Matches(%s) expected error", expr)
+		}
+	}
+}
+
+func TestSelector(t *testing.T) {
+	s, err := NewSelector(`image.repo.startsWith("mcr.microsoft.com")`, `node.labels["zone"] == "a"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if included, err := s.Included(testImage, testNode); err != nil || !included {
+		t.Errorf("Included() = %v, %v, want true", included, err)
+	}
+
+	// an exclude policy that fails to evaluate keeps the image
+	if excluded, err := s.Excluded(testImage, testNode); err == nil || !excluded {
+		t.Errorf("Excluded() = %v, %v, want true with error", excluded, err)
+	}
+
+	var empty *Selector
+	if included, _ := empty.Included(testImage, testNode); !included {
+		t.Error("a nil selector should include every image")
+	}
+	if excluded, _ := empty.Excluded(testImage, testNode); excluded {
+		t.Error("a nil selector should exclude no image")
+	}
+}
+
+func TestImageValue(t *testing.T) {
+	cases := []struct {
+		names, digests []string
+		repo, tag      string
+	}{
+		{[]string{"nginx:latest"}, nil, "nginx", "latest"},
+		{[]string{"localhost:5000/nginx"}, nil, "localhost:5000/nginx", ""},
+		{[]string{"localhost:5000/nginx:1.0"}, nil, "localhost:5000/nginx", "1.0"},
+		{[]string{"docker.io/library/nginx@sha256:abc"}, nil, "docker.io/library/nginx", ""},
+		{nil, []string{"docker.io/library/nginx@sha256:abc"}, "docker.io/library/nginx", ""},
+	}
+
+	for _, tc := range cases {
+		v := imageValue(Image{Image: unversioned.Image{Names: tc.names, Digests: tc.digests}})
+		if v["repo"] != tc.repo || v["tag"] != tc.tag {
+			t.Errorf("imageValue(%q, %q) has repo %q and tag %q, want %q, %q", tc.names, tc.digests, v["repo"], v["tag"], tc.repo, tc.tag)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+// DefaultCheckTimeout is how long a conformance check may take when no
+// timeout is given.
+const DefaultCheckTimeout = time.Minute
+
+// SampleImages are scanned by the conformance checks when no images are
+// given.
+var SampleImages = SampleImagesNamed("docker.io/library/busybox:1.36.0", "docker.io/library/alpine:3.7.3")
+
+// SampleImagesNamed returns images to scan by name. Outside of a node, the
+// image IDs are placeholders derived from the names.
+func SampleImagesNamed(names ...string) []unversioned.Image {
+	images := make([]unversioned.Image, 0, len(names))
+	for _, name := range names {
+		images = append(images, unversioned.Image{
+			ImageID: fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(name))),
+			Names:   []string{name},
+		})
+	}
+	return images
+}
+
+// Check is a conformance check of a plugin.
+type Check struct {
+	Name string
+	// run returns why the plugin does not conform.
+	run func(ctx context.Context, plugin *Runner, images []unversioned.Image) error
+}
+
+// Checks are the conformance checks of the protocol. A conformant plugin
+// passes all of them.
+var Checks = []Check{
+	{
+		Name: "reports a verdict for every image",
+		run: func(ctx context.Context, plugin *Runner, images []unversioned.Image) error {
+			req := NewScanRequest("", images)
+
+			got := map[string]bool{}
+			err := plugin.exec(ctx, req, func(v Verdict) error {
+				got[v.ImageID] = true
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, img := range req.Images {
+				if !got[img.ID] {
+					return fmt.Errorf("no verdict for image %q", img.ID)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Name: "succeeds without images",
+		run: func(ctx context.Context, plugin *Runner, _ []unversioned.Image) error {
+			return plugin.exec(ctx, NewScanRequest("", nil), func(v Verdict) error {
+				return fmt.Errorf("unexpected verdict for image %q", v.ImageID)
+			})
+		},
+	},
+	{
+		Name: "rejects an unknown protocol version",
+		run: func(ctx context.Context, plugin *Runner, images []unversioned.Image) error {
+			req := NewScanRequest("", images)
+			req.APIVersion = "eraser.sh/scanner/v0"
+
+			err := plugin.exec(ctx, req, func(Verdict) error { return nil })
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("exited successfully for apiVersion %q", req.APIVersion)
+		},
+	},
+}
+
+// CheckResult is the outcome of a conformance check.
+type CheckResult struct {
+	Name string
+	// Err is nil if the plugin passed the check.
+	Err error
+}
+
+// Conformance runs every conformance check against the plugin started by
+// command, scanning images. A check taking longer than timeout, or
+// DefaultCheckTimeout if it is zero, fails. The standard error of the plugin
+// is written to stderr.
+func Conformance(ctx context.Context, command []string, images []unversioned.Image, timeout time.Duration, stderr io.Writer) []CheckResult {
+	plugin := &Runner{Command: command, Stderr: stderr, Log: logr.Discard()}
+	if timeout == 0 {
+		timeout = DefaultCheckTimeout
+	}
+
+	results := make([]CheckResult, 0, len(Checks))
+	for _, check := range Checks {
+		results = append(results, CheckResult{Name: check.Name, Err: check.runWithTimeout(ctx, plugin, images, timeout)})
+	}
+	return results
+}
+
+// runWithTimeout runs the check, stopping the plugin once timeout has passed.
+// A plugin killed on timeout fails the check, even if its exit status would
+// otherwise pass it.
+func (c Check) runWithTimeout(ctx context.Context, plugin *Runner, images []unversioned.Image, timeout time.Duration) error {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := c.run(checkCtx, plugin, images)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// Conformant reports whether every check passed.
+func Conformant(results []CheckResult) bool {
+	for _, r := range results {
+		if r.Err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,192 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/Azure/eraser/api/unversioned"
+	"github.com/Azure/eraser/pkg/pipeline"
+)
+
+// pluginEnv makes the test binary act as a scanner plugin with the behavior
+// it names.
+const pluginEnv = "ERASER_TEST_SCANNER_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if behavior := os.Getenv(pluginEnv); behavior != "" {
+		os.Exit(fakePlugin(behavior))
+	}
+	os.Exit(m.Run())
+}
+
+// fakePlugin finds images named "vulnerable" non-compliant, and misbehaves as
+// asked.
+func fakePlugin(behavior string) int {
+	var req ScanRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if req.APIVersion != APIVersion && behavior != "ignores-version" {
+		fmt.Fprintln(os.Stderr, "unsupported apiVersion", req.APIVersion)
+		return 1
+	}
+
+	if behavior == "hangs" {
+		time.Sleep(time.Hour)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for i, img := range req.Images {
+		v := Verdict{ImageID: img.ID, Status: pipeline.StatusCompliant}
+		if strings.Contains(img.Names[0], "vulnerable") {
+			v.Status = pipeline.StatusNonCompliant
+		}
+
+		switch {
+		case behavior == "partial" && i == len(req.Images)-1:
+			return 0
+		case behavior == "unknown-image":
+			v.ImageID = "sha256:unknown"
+		case behavior == "invalid-status":
+			v.Status = pipeline.StatusUnscanned
+		}
+
+		if err := enc.Encode(v); err != nil {
+			return 1
+		}
+	}
+
+	if behavior == "fails" {
+		return 2
+	}
+	return 0
+}
+
+func pluginCommand(t *testing.T, behavior string) []string {
+	t.Helper()
+	t.Setenv(pluginEnv, behavior)
+	return []string{os.Args[0]}
+}
+
+var testImages = SampleImagesNamed("docker.io/library/safe:1", "docker.io/library/vulnerable:1", "docker.io/library/other:1")
+
+type fakeProvider struct {
+	images       []unversioned.Image
+	nonCompliant []string
+	failed       []string
+}
+
+func (p *fakeProvider) ReceiveImages() ([]unversioned.Image, error) {
+	return p.images, nil
+}
+
+func (p *fakeProvider) SendImages(nonCompliant, failed []unversioned.Image) error {
+	for _, img := range nonCompliant {
+		p.nonCompliant = append(p.nonCompliant, img.Names[0])
+	}
+	for _, img := range failed {
+		p.failed = append(p.failed, img.Names[0])
+	}
+	return nil
+}
+
+func (p *fakeProvider) Finish() error {
+	return nil
+}
+
+func (p *fakeProvider) Abort(error) error {
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		behavior     string
+		wantErr      bool
+		nonCompliant []string
+		failed       []string
+	}{
+		{
+			behavior:     "conformant",
+			nonCompliant: []string{"docker.io/library/vulnerable:1"},
+		},
+		{
+			behavior:     "partial",
+			nonCompliant: []string{"docker.io/library/vulnerable:1"},
+			failed:       []string{"docker.io/library/other:1"},
+		},
+		{behavior: "fails", wantErr: true},
+		{behavior: "unknown-image", wantErr: true},
+		{behavior: "invalid-status", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.behavior, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			provider := &fakeProvider{images: testImages}
+			r := &Runner{Command: pluginCommand(t, tt.behavior), Stderr: io.Discard, Log: logr.Discard()}
+
+			err := r.Run(ctx, provider)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if fmt.Sprint(provider.nonCompliant) != fmt.Sprint(tt.nonCompliant) {
+				t.Errorf("expected non-compliant images %v, got %v", tt.nonCompliant, provider.nonCompliant)
+			}
+			if fmt.Sprint(provider.failed) != fmt.Sprint(tt.failed) {
+				t.Errorf("expected failed images %v, got %v", tt.failed, provider.failed)
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	tests := []struct {
+		behavior string
+		// failing names the checks the plugin fails
+		failing []string
+	}{
+		{behavior: "conformant"},
+		{behavior: "partial", failing: []string{"reports a verdict for every image"}},
+		{behavior: "fails", failing: []string{"reports a verdict for every image", "succeeds without images"}},
+		{behavior: "ignores-version", failing: []string{"rejects an unknown protocol version"}},
+		{behavior: "unknown-image", failing: []string{"reports a verdict for every image"}},
+		{behavior: "hangs", failing: []string{"reports a verdict for every image", "succeeds without images"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.behavior, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			results := Conformance(ctx, pluginCommand(t, tt.behavior), testImages, 2*time.Second, io.Discard)
+
+			var failing []string
+			for _, r := range results {
+				if r.Err != nil {
+					failing = append(failing, r.Name)
+				}
+			}
+			if fmt.Sprint(failing) != fmt.Sprint(tt.failing) {
+				t.Errorf("expected failing checks %v, got %v", tt.failing, failing)
+			}
+			if Conformant(results) != (len(tt.failing) == 0) {
+				t.Errorf("expected conformant to be %v", len(tt.failing) == 0)
+			}
+		})
+	}
+}
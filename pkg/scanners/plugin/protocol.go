@@ -0,0 +1,126 @@
+// Package plugin runs scanners that implement the stdio scanner protocol in a
+// separate process, so that they do not have to be built against eraser.
+//
+// The plugin is started once per ImageJob pod. It reads a single ScanRequest
+// as JSON from its standard input, and writes one Verdict per line as JSON to
+// its standard output, in any order and as soon as each image is scanned. Its
+// standard error is passed through to the logs of the scanner container. The
+// plugin exits with status 0 once every image has a verdict; any other exit
+// status stops the ImageJob without removing any image.
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Azure/eraser/api/unversioned"
+	"github.com/Azure/eraser/pkg/pipeline"
+)
+
+const (
+	// APIVersion is the version of the protocol. A plugin must exit with a
+	// non-zero status when asked to scan with a version it does not
+	// implement.
+	APIVersion = "eraser.sh/scanner/v1"
+
+	// KindScanRequest is the kind of the request written to the plugin.
+	KindScanRequest = "ScanRequest"
+
+	// maxVerdictSize bounds the length of a line written by the plugin.
+	maxVerdictSize = 1 << 20
+)
+
+type (
+	// ScanRequest lists the images a plugin must scan.
+	ScanRequest struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		// Config is the scanner configuration of the eraser config, passed
+		// through as is.
+		Config string  `json:"config,omitempty"`
+		Images []Image `json:"images"`
+	}
+
+	// Image is an image found on the node.
+	Image struct {
+		ID      string   `json:"imageID"`
+		Names   []string `json:"names,omitempty"`
+		Digests []string `json:"digests,omitempty"`
+	}
+
+	// Verdict is the result of scanning an image, written by the plugin.
+	Verdict struct {
+		ImageID string          `json:"imageID"`
+		Status  pipeline.Status `json:"status"`
+		// Reason is logged with the verdict.
+		Reason string `json:"reason,omitempty"`
+	}
+)
+
+// validStatus are the statuses a plugin may report. Compliant and failed
+// images are kept.
+var validStatus = map[pipeline.Status]bool{
+	pipeline.StatusNonCompliant: true,
+	pipeline.StatusCompliant:    true,
+	pipeline.StatusFailed:       true,
+}
+
+// NewScanRequest returns the request to scan images.
+func NewScanRequest(config string, images []unversioned.Image) *ScanRequest {
+	req := &ScanRequest{
+		APIVersion: APIVersion,
+		Kind:       KindScanRequest,
+		Config:     config,
+		Images:     make([]Image, 0, len(images)),
+	}
+
+	for _, img := range images {
+		req.Images = append(req.Images, Image{ID: img.ImageID, Names: img.Names, Digests: img.Digests})
+	}
+
+	return req
+}
+
+// readVerdicts calls fn with every verdict the plugin writes to r, until r is
+// closed. A verdict for an image that was not requested, or for an image that
+// already has one, breaks the protocol.
+func readVerdicts(r io.Reader, req *ScanRequest, fn func(Verdict) error) error {
+	requested := make(map[string]bool, len(req.Images))
+	for _, img := range req.Images {
+		requested[img.ID] = true
+	}
+	seen := make(map[string]bool, len(req.Images))
+
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 0, 64*1024), maxVerdictSize)
+
+	for n := 1; lines.Scan(); n++ {
+		if len(lines.Bytes()) == 0 {
+			continue
+		}
+
+		var v Verdict
+		if err := json.Unmarshal(lines.Bytes(), &v); err != nil {
+			return fmt.Errorf("line %d: invalid verdict: %w", n, err)
+		}
+		if !requested[v.ImageID] {
+			return fmt.Errorf("line %d: verdict for image %q, which was not requested", n, v.ImageID)
+		}
+		if seen[v.ImageID] {
+			return fmt.Errorf("line %d: second verdict for image %q", n, v.ImageID)
+		}
+		if !validStatus[v.Status] {
+			return fmt.Errorf("line %d: invalid status %q for image %q, must be one of %q, %q or %q",
+				n, v.Status, v.ImageID, pipeline.StatusNonCompliant, pipeline.StatusCompliant, pipeline.StatusFailed)
+		}
+		seen[v.ImageID] = true
+
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+
+	return lines.Err()
+}
@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/go-logr/logr"
+
+	"github.com/Azure/eraser/api/unversioned"
+	"github.com/Azure/eraser/pkg/pipeline"
+	"github.com/Azure/eraser/pkg/scanners/template"
+)
+
+// Runner scans the images of an ImageProvider with a plugin.
+type Runner struct {
+	// Command starts the plugin.
+	Command []string
+	// Config is passed to the plugin in the ScanRequest.
+	Config string
+	// Stderr receives the standard error of the plugin.
+	Stderr io.Writer
+	Log    logr.Logger
+}
+
+// Run scans the images received from provider with the plugin, and sends each
+// verdict to provider as soon as the plugin reports it. Images left without a
+// verdict are sent as failed. Run does not complete or abort the provider.
+func (r *Runner) Run(ctx context.Context, provider template.ImageProvider) error {
+	if len(r.Command) == 0 {
+		return errors.New("no scanner plugin command")
+	}
+
+	images, err := provider.ReceiveImages()
+	if err != nil {
+		return fmt.Errorf("unable to receive images: %w", err)
+	}
+
+	byID := make(map[string]unversioned.Image, len(images))
+	for _, img := range images {
+		byID[img.ImageID] = img
+	}
+
+	req := NewScanRequest(r.Config, images)
+	scanned := make(map[string]bool, len(images))
+	err = r.exec(ctx, req, func(v Verdict) error {
+		scanned[v.ImageID] = true
+
+		img := byID[v.ImageID]
+		r.Log.Info("scanned image", "image", img.ImageID, "names", img.Names, "status", v.Status, "reason", v.Reason)
+
+		switch v.Status {
+		case pipeline.StatusNonCompliant:
+			return provider.SendImages([]unversioned.Image{img}, nil)
+		case pipeline.StatusFailed:
+			return provider.SendImages(nil, []unversioned.Image{img})
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	var missing []unversioned.Image
+	for _, img := range images {
+		if !scanned[img.ImageID] {
+			missing = append(missing, img)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	r.Log.Info("scanner plugin reported no verdict, treating images as failed", "images", len(missing))
+	return provider.SendImages(nil, missing)
+}
+
+// exec runs the plugin with req and calls fn with its verdicts. It fails if
+// the plugin breaks the protocol or does not exit successfully.
+func (r *Runner) exec(ctx context.Context, req *ScanRequest, fn func(Verdict) error) error {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// #nosec G204 -- the command is set by the cluster administrator
+	cmd := exec.CommandContext(ctx, r.Command[0], r.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = r.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to start scanner plugin: %w", err)
+	}
+
+	readErr := readVerdicts(stdout, req, fn)
+	if readErr != nil {
+		// the remaining verdicts cannot be trusted
+		cancel()
+		// drain the pipe, so the plugin is not blocked on writing
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+
+	waitErr := cmd.Wait()
+	if readErr != nil {
+		return fmt.Errorf("unable to read scanner plugin verdicts: %w", readErr)
+	}
+	if waitErr != nil {
+		return fmt.Errorf("scanner plugin failed: %w", waitErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,228 @@
+// The scanner shim runs a scanner plugin in the scanner container of an
+// ImageJob pod, and connects it to the collector and eraser containers.
+//
+// Usage:
+//
+//	scanner-shim install DIR
+//	scanner-shim run [--config=FILE] -- COMMAND [ARG...]
+//	scanner-shim conformance [--image=NAME...] [--timeout=DURATION] -- COMMAND [ARG...]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/pkg/logger"
+	"github.com/Azure/eraser/pkg/pipeline"
+	"github.com/Azure/eraser/pkg/scanners/plugin"
+	"github.com/Azure/eraser/pkg/scanners/template"
+)
+
+const (
+	generalErr = 1
+	usageErr   = 2
+
+	// binaryName is the name of the shim once installed.
+	binaryName = "scanner-shim"
+)
+
+var log = logf.Log.WithName("scanner").WithValues("provider", "plugin")
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	if err := logger.Configure(); err != nil {
+		fmt.Fprintf(os.Stderr, "error setting up logger: %s", err)
+		os.Exit(generalErr)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "install":
+		err = install(args)
+	case "run":
+		err = run(args)
+	case "conformance":
+		err = conformance(args)
+	default:
+		usage()
+	}
+
+	if err != nil {
+		log.Error(err, "scanner shim failed", "command", os.Args[1])
+		os.Exit(generalErr)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: scanner-shim install DIR")
+	fmt.Fprintln(os.Stderr, "       scanner-shim run [--config=FILE] -- COMMAND [ARG...]")
+	fmt.Fprintln(os.Stderr, "       scanner-shim conformance [--image=NAME...] [--timeout=DURATION] -- COMMAND [ARG...]")
+	os.Exit(usageErr)
+}
+
+// install copies the shim into dir, which is shared with the scanner
+// container.
+func install(args []string) error {
+	if len(args) != 1 {
+		usage()
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(self)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	dst := filepath.Join(args[0], binaryName)
+	// #nosec G302 -- the shim is run by the scanner container
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o755)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	log.Info("installed scanner shim", "path", dst)
+	return out.Close()
+}
+
+// run scans the images of the ImageJob pod with the plugin.
+func run(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	config := flags.String("config", "", "path to the eraser configuration file")
+	command := parse(flags, args)
+
+	scannerConfig, err := loadScannerConfig(*config)
+	if err != nil {
+		return fmt.Errorf("unable to read config: %w", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	provider := template.NewImageProvider(
+		template.WithContext(ctx),
+		template.WithLogger(log),
+		template.WithMetrics(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != ""),
+		// the plugin decides which failed images are removed
+		template.WithDeleteScanFailedImages(false),
+	)
+
+	runner := &plugin.Runner{
+		Command: command,
+		Config:  scannerConfig,
+		Stderr:  os.Stderr,
+		Log:     log,
+	}
+	if err := runner.Run(ctx, provider); err != nil {
+		if abortErr := template.Abort(provider, err); abortErr != nil {
+			log.Error(abortErr, "unable to abort image removal")
+		}
+		return err
+	}
+
+	log.Info("scanning complete, waiting for eraser to finish...")
+	return provider.Finish()
+}
+
+// conformance checks that the plugin implements the protocol.
+func conformance(args []string) error {
+	flags := flag.NewFlagSet("conformance", flag.ExitOnError)
+	var names stringList
+	flags.Var(&names, "image", "name of an image to scan, may be repeated. defaults to sample public images")
+	timeout := flags.Duration("timeout", plugin.DefaultCheckTimeout, "how long each check may take before it fails")
+	command := parse(flags, args)
+
+	images := plugin.SampleImages
+	if len(names) > 0 {
+		images = plugin.SampleImagesNamed(names...)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	results := plugin.Conformance(ctx, command, images, *timeout, os.Stderr)
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("FAIL: %s: %v\n", r.Name, r.Err)
+			continue
+		}
+		fmt.Printf("PASS: %s\n", r.Name)
+	}
+
+	if !plugin.Conformant(results) {
+		return errors.New("scanner plugin is not conformant")
+	}
+	return nil
+}
+
+// parse parses the flags of a subcommand, and returns the plugin command
+// that follows them.
+func parse(flags *flag.FlagSet, args []string) []string {
+	if err := flags.Parse(args); err != nil {
+		usage()
+	}
+	if flags.NArg() == 0 {
+		usage()
+	}
+	return flags.Args()
+}
+
+// loadScannerConfig returns the scanner configuration of the eraser config
+// file, which is passed to the plugin as is.
+func loadScannerConfig(filename string) (string, error) {
+	if filename == "" {
+		return "", nil
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	var eraserConfig eraserv1alpha1.EraserConfig
+	if err := yaml.Unmarshal(b, &eraserConfig); err != nil {
+		return "", err
+	}
+
+	// the pod may run other scanners, with their own config
+	scanner := eraserConfig.Components.ScannerNamed(os.Getenv(pipeline.ScannerNameEnv))
+	if scanner.Config == nil {
+		return "", nil
+	}
+	return *scanner.Config, nil
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
@@ -0,0 +1,211 @@
+// The signature scanner finds the images that are not signed as required by
+// trust policies: images from the repositories of a policy must carry a
+// cosign signature, and optionally attestations, made with one of the keys of
+// the policy. Signatures are read from the registries of the images, or from
+// an OCI image layout. Only key-based cosign signatures are verified: notation
+// signatures, keyless signatures and transparency logs are out of scope.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	_ "net/http/pprof"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/Azure/eraser/api/unversioned"
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/pkg/logger"
+	"github.com/Azure/eraser/pkg/pipeline"
+	"github.com/Azure/eraser/pkg/scanners/template"
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+const generalErr = 1
+
+var (
+	config        = flag.String("config", "", "path to the configuration file")
+	enableProfile = flag.Bool("enable-pprof", false, "enable pprof profiling")
+	profilePort   = flag.Int("pprof-port", 6060, "port for pprof profiling. defaulted to 6060 if unspecified")
+	pullSecrets   = flag.String("pull-secrets", utils.PullSecretsDir, "directory holding the image pull secrets used for registry authentication")
+
+	log = logf.Log.WithName("scanner").WithValues("provider", "signature")
+)
+
+func main() {
+	flag.Parse()
+
+	if err := logger.Configure(); err != nil {
+		fmt.Fprintf(os.Stderr, "error setting up logger: %s", err)
+		os.Exit(generalErr)
+	}
+
+	userConfig := *DefaultConfig()
+	if *config != "" {
+		var err error
+		userConfig, err = loadConfig(*config)
+		if err != nil {
+			log.Error(err, "unable to read config")
+			os.Exit(generalErr)
+		}
+	}
+
+	if *enableProfile {
+		go runProfileServer()
+	}
+
+	if err := scanImages(&userConfig); err != nil {
+		log.Error(err, "signature scan failed")
+		os.Exit(generalErr)
+	}
+
+	log.Info("eraser job completed, shutting down...")
+}
+
+// scanImages verifies the images of the ImageJob pod, and waits for the
+// eraser to remove the untrusted ones.
+func scanImages(cfg *Config) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	provider := template.NewImageProvider(
+		template.WithContext(ctx),
+		template.WithLogger(log),
+		template.WithMetrics(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != ""),
+		template.WithDeleteScanFailedImages(cfg.DeleteFailedImages),
+	)
+
+	if err := run(ctx, cfg, provider); err != nil {
+		if abortErr := template.Abort(provider, err); abortErr != nil {
+			log.Error(abortErr, "unable to abort image removal")
+		}
+		return err
+	}
+
+	log.Info("scanning complete, waiting for eraser to finish...")
+	return provider.Finish()
+}
+
+func run(ctx context.Context, cfg *Config, provider template.ImageProvider) error {
+	v, err := newVerifier(cfg)
+	if err != nil {
+		return fmt.Errorf("error initializing verifier: %w", err)
+	}
+
+	images, err := provider.ReceiveImages()
+	if err != nil {
+		return fmt.Errorf("unable to read images from provider: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Timeout.Total))
+	defer cancel()
+
+	for _, img := range images {
+		res := verifyImage(ctx, v, img, time.Duration(cfg.Timeout.PerImage))
+
+		var nonCompliant, failed []unversioned.Image
+		switch res.status {
+		case statusNonCompliant:
+			log.Info("untrusted image found", "img", img, "reason", res.reason)
+			nonCompliant = append(nonCompliant, img)
+		case statusFailed:
+			log.Info("unable to verify image", "img", img, "reason", res.reason)
+			failed = append(failed, img)
+		default:
+			continue
+		}
+
+		// verdicts are sent as they come so images are removed early
+		if err := provider.SendImages(nonCompliant, failed); err != nil {
+			return fmt.Errorf("unable to write images: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func verifyImage(ctx context.Context, v *verifier, img unversioned.Image, timeout time.Duration) result {
+	if err := ctx.Err(); err != nil {
+		return result{status: statusFailed, reason: "image scan total timeout exceeded"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return v.verify(ctx, img)
+}
+
+func newVerifier(cfg *Config) (*verifier, error) {
+	policies, err := loadPolicies(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := newSecretKeychain(*pullSecrets)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read pull secrets: %w", err)
+	}
+	if len(secrets) > 0 {
+		log.Info("using registry credentials from pull secrets", "registries", len(secrets))
+	}
+
+	var s store = registryStore{keychain: authn.NewMultiKeychain(secrets, authn.DefaultKeychain)}
+	if cfg.OCILayout != "" {
+		if s, err = newLayoutStore(cfg.OCILayout); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(policies) == 0 && !cfg.RejectUnmatched {
+		log.Info("no trust policies configured, every image is trusted")
+	}
+
+	return &verifier{policies: policies, store: s, rejectUnmatched: cfg.RejectUnmatched}, nil
+}
+
+func loadConfig(filename string) (Config, error) {
+	cfg := *DefaultConfig()
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return cfg, err
+	}
+
+	var eraserConfig eraserv1alpha1.EraserConfig
+	if err := yaml.Unmarshal(b, &eraserConfig); err != nil {
+		return cfg, err
+	}
+
+	// the pod may run other scanners, with their own config
+	scanCfgYaml := eraserConfig.Components.ScannerNamed(os.Getenv(pipeline.ScannerNameEnv)).Config
+	if scanCfgYaml == nil {
+		return cfg, nil
+	}
+
+	if err := yaml.Unmarshal([]byte(*scanCfgYaml), &cfg); err != nil {
+		return cfg, err
+	}
+	if time.Duration(cfg.Timeout.Total) <= 0 || time.Duration(cfg.Timeout.PerImage) <= 0 {
+		return cfg, errors.New("scan timeouts must be positive")
+	}
+
+	return cfg, nil
+}
+
+func runProfileServer() {
+	server := &http.Server{
+		Addr:              fmt.Sprintf("localhost:%d", *profilePort),
+		ReadHeaderTimeout: 3 * time.Second,
+	}
+	err := server.ListenAndServe()
+	log.Error(err, "pprof server failed")
+}
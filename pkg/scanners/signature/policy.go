@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// formatCosign is the only signature format verified: cosign signatures and
+// attestations made with a key. Keyless signatures and transparency log
+// entries are not. Notation signatures were requested too, but are left to a
+// separate change: a policy asking for them is rejected.
+const formatCosign = "cosign"
+
+// policy is a trust policy with its keys loaded.
+type policy struct {
+	name         string
+	scopes       []string
+	keys         []crypto.PublicKey
+	attestations []string
+}
+
+func loadPolicies(cfg *Config) ([]policy, error) {
+	policies := make([]policy, 0, len(cfg.Policies))
+	for i := range cfg.Policies {
+		tp := &cfg.Policies[i]
+		if len(tp.Scopes) == 0 {
+			return nil, fmt.Errorf("trust policy %q has no scopes", tp.Name)
+		}
+		if len(tp.PublicKeys) == 0 {
+			return nil, fmt.Errorf("trust policy %q has no public keys", tp.Name)
+		}
+		if tp.Format != "" && tp.Format != formatCosign {
+			return nil, fmt.Errorf("trust policy %q: unsupported signature format %q, only %q signatures are verified (notation is not supported yet)", tp.Name, tp.Format, formatCosign)
+		}
+
+		p := policy{name: tp.Name, attestations: tp.Attestations}
+		for _, scope := range tp.Scopes {
+			p.scopes = append(p.scopes, normalizeRepository(scope))
+		}
+		for _, k := range tp.PublicKeys {
+			key, err := loadPublicKey(k)
+			if err != nil {
+				return nil, fmt.Errorf("trust policy %q: %w", tp.Name, err)
+			}
+			p.keys = append(p.keys, key)
+		}
+
+		policies = append(policies, p)
+	}
+
+	return policies, nil
+}
+
+// loadPublicKey parses a PEM encoded public key, read from a file unless it
+// is inline.
+func loadPublicKey(s string) (crypto.PublicKey, error) {
+	b := []byte(s)
+	if !strings.Contains(s, "-----BEGIN") {
+		var err error
+		if b, err = os.ReadFile(s); err != nil {
+			return nil, err
+		}
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("public key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// match returns the first policy applying to repo, if any.
+func match(policies []policy, repo string) (*policy, bool) {
+	repo = normalizeRepository(repo)
+	for i := range policies {
+		for _, scope := range policies[i].scopes {
+			if matchScope(scope, repo) {
+				return &policies[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+func matchScope(scope, repo string) bool {
+	if strings.HasSuffix(scope, "/*") {
+		return strings.HasPrefix(repo, strings.TrimSuffix(scope, "*"))
+	}
+	ok, err := path.Match(scope, repo)
+	return err == nil && ok
+}
+
+// normalizeRepository spells out the registry of repo, so that nginx and
+// docker.io/library/nginx are the same repository.
+func normalizeRepository(repo string) string {
+	if strings.HasSuffix(repo, "/*") || strings.ContainsAny(repo, "*?[") {
+		return strings.Replace(repo, name.DefaultRegistry+"/", "docker.io/", 1)
+	}
+
+	r, err := name.NewRepository(repo)
+	if err != nil {
+		return repo
+	}
+	if registry := r.RegistryStr(); registry == name.DefaultRegistry {
+		return "docker.io/" + r.RepositoryStr()
+	}
+	return r.Name()
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+const (
+	testRepo = "docker.io/library/nginx"
+
+	provenance = "https://slsa.dev/provenance/v0.2"
+)
+
+var (
+	signedDigest   = testDigest("signed")
+	unsignedDigest = testDigest("unsigned")
+)
+
+func testDigest(s string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(s)))
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func publicKeyPEM(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, msg []byte) string {
+	t.Helper()
+
+	digest := sha256.Sum256(msg)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+// testLayout is an OCI layout holding cosign signatures and attestations.
+type testLayout struct {
+	t    *testing.T
+	path layout.Path
+}
+
+func newTestLayout(t *testing.T) *testLayout {
+	t.Helper()
+
+	p, err := layout.Write(t.TempDir(), empty.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testLayout{t: t, path: p}
+}
+
+func (l *testLayout) add(digest, suffix string, mediaType types.MediaType, blob []byte, annotations map[string]string) {
+	l.t.Helper()
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer(blob, mediaType),
+		Annotations: annotations,
+		MediaType:   mediaType,
+	})
+	if err != nil {
+		l.t.Fatal(err)
+	}
+
+	ref := fmt.Sprintf("%s:sha256-%s.%s", testRepo, digest[len("sha256:"):], suffix)
+	if err := l.path.AppendImage(img, layout.WithAnnotations(map[string]string{annotationRefName: ref})); err != nil {
+		l.t.Fatal(err)
+	}
+}
+
+// sign attaches a signature of signedDigest, made with key, to digest.
+func (l *testLayout) sign(key *ecdsa.PrivateKey, digest, signedDigest string) {
+	l.t.Helper()
+
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, testRepo, signedDigest))
+	l.add(digest, signatureSuffix, simpleSigningMediaType, payload, map[string]string{signatureAnnotation: sign(l.t, key, payload)})
+}
+
+func (l *testLayout) attest(key *ecdsa.PrivateKey, digest, predicateType string) {
+	l.t.Helper()
+
+	payload := []byte(fmt.Sprintf(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":%q,"subject":[{"name":%q,"digest":{"sha256":%q}}],"predicate":{}}`,
+		predicateType, testRepo, digest[len("sha256:"):]))
+
+	env := map[string]interface{}{
+		"payloadType": inTotoPayloadType,
+		"payload":     base64.StdEncoding.EncodeToString(payload),
+		"signatures": []map[string]string{
+			{"sig": sign(l.t, key, preAuthEncoding(inTotoPayloadType, payload))},
+		},
+	}
+	blob, err := json.Marshal(env)
+	if err != nil {
+		l.t.Fatal(err)
+	}
+	l.add(digest, attestationSuffix, dsseMediaType, blob, map[string]string{"predicateType": predicateType})
+}
+
+func TestVerify(t *testing.T) {
+	key, otherKey := newKey(t), newKey(t)
+
+	l := newTestLayout(t)
+	l.sign(key, signedDigest, signedDigest)
+	l.attest(key, signedDigest, provenance)
+
+	wrongKeyDigest := testDigest("wrong key")
+	l.sign(otherKey, wrongKeyDigest, wrongKeyDigest)
+
+	copiedDigest := testDigest("copied")
+	l.sign(key, copiedDigest, signedDigest)
+
+	forgedAttestationDigest := testDigest("forged attestation")
+	l.sign(key, forgedAttestationDigest, forgedAttestationDigest)
+	l.attest(otherKey, forgedAttestationDigest, provenance)
+
+	nginx := func(digests ...string) unversioned.Image {
+		return unversioned.Image{ImageID: "nginx", Names: []string{"nginx:latest"}, Digests: digests}
+	}
+	other := unversioned.Image{ImageID: "other", Names: []string{"ghcr.io/other/app:v1"}, Digests: []string{unsignedDigest}}
+
+	cases := []struct {
+		name            string
+		policy          TrustPolicy
+		rejectUnmatched bool
+		image           unversioned.Image
+		expected        scanStatus
+	}{
+		{
+			name:     "signed",
+			image:    nginx(signedDigest),
+			expected: statusCompliant,
+		},
+		{
+			name:     "one of the digests is signed",
+			image:    nginx(unsignedDigest, signedDigest),
+			expected: statusCompliant,
+		},
+		{
+			name:     "unsigned",
+			image:    nginx(unsignedDigest),
+			expected: statusNonCompliant,
+		},
+		{
+			name:     "signed with an untrusted key",
+			image:    nginx(wrongKeyDigest),
+			expected: statusNonCompliant,
+		},
+		{
+			name:     "signature of another image",
+			image:    nginx(copiedDigest),
+			expected: statusNonCompliant,
+		},
+		{
+			name:     "no digest",
+			image:    nginx(),
+			expected: statusNonCompliant,
+		},
+		{
+			name:     "attested",
+			policy:   TrustPolicy{Attestations: []string{provenance}},
+			image:    nginx(signedDigest),
+			expected: statusCompliant,
+		},
+		{
+			name:     "missing attestation",
+			policy:   TrustPolicy{Attestations: []string{"https://spdx.dev/Document"}},
+			image:    nginx(signedDigest),
+			expected: statusNonCompliant,
+		},
+		{
+			name:     "attestation signed with an untrusted key",
+			policy:   TrustPolicy{Attestations: []string{provenance}},
+			image:    nginx(forgedAttestationDigest),
+			expected: statusNonCompliant,
+		},
+		{
+			name:     "unmatched",
+			image:    other,
+			expected: statusCompliant,
+		},
+		{
+			name:            "unmatched rejected",
+			rejectUnmatched: true,
+			image:           other,
+			expected:        statusNonCompliant,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.policy
+			p.Name = "nginx"
+			p.Scopes = []string{"docker.io/library/nginx"}
+			p.PublicKeys = []string{publicKeyPEM(t, key)}
+
+			v, err := newVerifier(&Config{Policies: []TrustPolicy{p}, RejectUnmatched: tc.rejectUnmatched, OCILayout: string(l.path)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := v.verify(context.Background(), tc.image)
+			if res.status != tc.expected {
+				t.Errorf("expected status %d, got %d (%s)", tc.expected, res.status, res.reason)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	policies := []policy{
+		{name: "library", scopes: []string{normalizeRepository("docker.io/library/*")}},
+		{name: "app", scopes: []string{normalizeRepository("ghcr.io/org/app-*")}},
+	}
+
+	cases := []struct {
+		repo     string
+		expected string
+	}{
+		{repo: "nginx", expected: "library"},
+		{repo: "index.docker.io/library/nginx", expected: "library"},
+		{repo: "docker.io/library/nested/repo", expected: "library"},
+		{repo: "docker.io/org/nginx", expected: ""},
+		{repo: "ghcr.io/org/app-api", expected: "app"},
+		{repo: "ghcr.io/org/app-api/nested", expected: ""},
+		{repo: "ghcr.io/org/other", expected: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.repo, func(t *testing.T) {
+			name := ""
+			if p, ok := match(policies, tc.repo); ok {
+				name = p.name
+			}
+			if name != tc.expected {
+				t.Errorf("expected policy %q, got %q", tc.expected, name)
+			}
+		})
+	}
+}
+
+func TestLoadPublicKey(t *testing.T) {
+	key := newKey(t)
+
+	loaded, err := loadPublicKey(publicKeyPEM(t, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.PublicKey.Equal(loaded) {
+		t.Error("loaded a different key")
+	}
+
+	if _, err := loadPublicKey("-----BEGIN PUBLIC KEY-----\ngarbage\n-----END PUBLIC KEY-----\n"); err == nil {
+		t.Error("expected an error loading an invalid key")
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	key := publicKeyPEM(t, newKey(t))
+	trustPolicy := func(format string) TrustPolicy {
+		return TrustPolicy{Name: "internal", Scopes: []string{"example.com/*"}, PublicKeys: []string{key}, Format: format}
+	}
+
+	testCases := []struct {
+		name   string
+		policy TrustPolicy
+		err    bool
+	}{
+		{
+			name:   "default format",
+			policy: trustPolicy(""),
+		},
+		{
+			name:   "cosign",
+			policy: trustPolicy("cosign"),
+		},
+		{
+			name:   "notation",
+			policy: trustPolicy("notation"),
+			err:    true,
+		},
+		{
+			name:   "no scopes",
+			policy: TrustPolicy{Name: "internal", PublicKeys: []string{key}},
+			err:    true,
+		},
+		{
+			name:   "no keys",
+			policy: TrustPolicy{Name: "internal", Scopes: []string{"example.com/*"}},
+			err:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadPolicies(&Config{Policies: []TrustPolicy{tc.policy}})
+			if tc.err && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tc.err && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSecretKeychain(t *testing.T) {
+	dir := t.TempDir()
+	secrets := map[string]string{
+		"acr":       `{"auths":{"myregistry.azurecr.io":{"username":"user","password":"pass"}}}`,
+		"dockerhub": `{"auths":{"https://index.docker.io/v1/":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("hub:secret")) + `"}}}`,
+	}
+	for secret, cfg := range secrets {
+		if err := os.MkdirAll(filepath.Join(dir, secret), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, secret, ".dockerconfigjson"), []byte(cfg), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	k, err := newSecretKeychain(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		repo     string
+		username string
+		password string
+	}{
+		{repo: "myregistry.azurecr.io/app", username: "user", password: "pass"},
+		{repo: "nginx", username: "hub", password: "secret"},
+		{repo: "ghcr.io/org/app"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.repo, func(t *testing.T) {
+			repo, err := name.NewRepository(tc.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth, err := k.Resolve(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := auth.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Username != tc.username || cfg.Password != tc.password {
+				t.Errorf("unexpected credentials: expected: %s:%s, got: %s:%s", tc.username, tc.password, cfg.Username, cfg.Password)
+			}
+		})
+	}
+
+	if k, err := newSecretKeychain(filepath.Join(dir, "missing")); err != nil || len(k) != 0 {
+		t.Errorf("expected no credentials without pull secrets, got %v, %v", k, err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// annotationRefName names the images of an OCI layout.
+const annotationRefName = "org.opencontainers.image.ref.name"
+
+// errNotFound is returned by a store that has no image with the tag.
+var errNotFound = errors.New("not found")
+
+// store holds the signatures and attestations of images, as images tagged
+// after the digest of the image they belong to.
+type store interface {
+	image(ctx context.Context, tag name.Tag) (v1.Image, error)
+}
+
+// registryStore reads signatures from the registry of the image.
+type registryStore struct {
+	keychain authn.Keychain
+}
+
+func (s registryStore) image(ctx context.Context, tag name.Tag) (v1.Image, error) {
+	img, err := remote.Image(tag, remote.WithContext(ctx), remote.WithAuthFromKeychain(s.keychain))
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	return img, err
+}
+
+// secretKeychain holds the registry credentials of the image pull secrets
+// mounted in the scanner, by registry host. The pod has no docker config, so
+// the default keychain alone cannot read from private registries.
+type secretKeychain map[string]authn.AuthConfig
+
+// newSecretKeychain reads the docker config of each pull secret mounted in a
+// directory of its own under dir. There are none if dir does not exist.
+func newSecretKeychain(dir string) (secretKeychain, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", corev1.DockerConfigJsonKey))
+	if err != nil {
+		return nil, err
+	}
+
+	k := make(secretKeychain)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var cfg struct {
+			Auths map[string]authn.AuthConfig `json:"auths"`
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid docker config %s: %w", file, err)
+		}
+		for registry, auth := range cfg.Auths {
+			k[registryHost(registry)] = auth
+		}
+	}
+
+	return k, nil
+}
+
+func (k secretKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if auth, ok := k[target.RegistryStr()]; ok {
+		return authn.FromConfig(auth), nil
+	}
+	return authn.Anonymous, nil
+}
+
+// registryHost returns the host of a registry key of a docker config, such
+// as https://index.docker.io/v1/, as go-containerregistry names it.
+func registryHost(registry string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	switch host {
+	case "docker.io", "registry-1.docker.io":
+		return name.DefaultRegistry
+	}
+	return host
+}
+
+// layoutStore reads signatures from an OCI image layout, where they are
+// named after their repository and tag, e.g. docker.io/library/nginx:sha256-<hex>.sig.
+type layoutStore struct {
+	path layout.Path
+}
+
+func newLayoutStore(dir string) (layoutStore, error) {
+	p, err := layout.FromPath(dir)
+	if err != nil {
+		return layoutStore{}, fmt.Errorf("unable to open OCI layout: %w", err)
+	}
+	return layoutStore{path: p}, nil
+}
+
+func (s layoutStore) image(_ context.Context, tag name.Tag) (v1.Image, error) {
+	index, err := s.path.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	ref := normalizeRepository(tag.Context().Name()) + ":" + tag.TagStr()
+	for _, desc := range manifest.Manifests {
+		if desc.Annotations[annotationRefName] == ref {
+			return index.Image(desc.Digest)
+		}
+	}
+	return nil, errNotFound
+}
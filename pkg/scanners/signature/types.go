@@ -0,0 +1,69 @@
+package main
+
+import (
+	"time"
+
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+)
+
+const (
+	statusFailed scanStatus = iota
+	statusNonCompliant
+	statusCompliant
+)
+
+type (
+	Config struct {
+		// Policies are checked in order; the first policy with a scope
+		// matching the repository of an image applies to it.
+		Policies []TrustPolicy `json:"policies,omitempty"`
+		// RejectUnmatched removes the images no policy applies to, which are
+		// kept otherwise.
+		RejectUnmatched bool `json:"rejectUnmatched,omitempty"`
+		// OCILayout reads signatures from an OCI image layout instead of
+		// the registries of the images.
+		OCILayout          string        `json:"ociLayout,omitempty"`
+		DeleteFailedImages bool          `json:"deleteFailedImages,omitempty"`
+		Timeout            TimeoutConfig `json:"timeout,omitempty"`
+	}
+
+	TrustPolicy struct {
+		Name string `json:"name,omitempty"`
+		// Scopes are the repositories the policy applies to. A scope ending
+		// with /* matches every repository under it.
+		Scopes []string `json:"scopes,omitempty"`
+		// PublicKeys are PEM encoded public keys, or paths of files holding
+		// one. An image must be signed with one of them.
+		PublicKeys []string `json:"publicKeys,omitempty"`
+		// Attestations are the predicate types of the attestations an image
+		// must carry, signed with one of the keys.
+		Attestations []string `json:"attestations,omitempty"`
+		// Format is the format of the signatures, which must be cosign, the
+		// default. Notation signatures are not verified, so a policy asking
+		// for them is rejected rather than finding every image unsigned.
+		Format string `json:"format,omitempty"`
+	}
+
+	TimeoutConfig struct {
+		Total    eraserv1alpha1.Duration `json:"total,omitempty"`
+		PerImage eraserv1alpha1.Duration `json:"perImage,omitempty"`
+	}
+
+	scanStatus int
+
+	// result is the outcome of verifying an image.
+	result struct {
+		status scanStatus
+		reason string
+	}
+)
+
+func DefaultConfig() *Config {
+	return &Config{
+		DeleteFailedImages: false,
+		Timeout: TimeoutConfig{
+			Total:    eraserv1alpha1.Duration(time.Hour * 23),
+			PerImage: eraserv1alpha1.Duration(time.Minute * 5),
+		},
+	}
+}
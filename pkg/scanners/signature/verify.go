@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/eraser/api/unversioned"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// Media types and annotations of the signatures and attestations cosign
+	// attaches to images.
+	simpleSigningMediaType types.MediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	dsseMediaType          types.MediaType = "application/vnd.dsse.envelope.v1+json"
+	signatureAnnotation                    = "dev.cosignproject.cosign/signature"
+
+	inTotoPayloadType = "application/vnd.in-toto+json"
+
+	signatureSuffix   = "sig"
+	attestationSuffix = "att"
+
+	// maxBlobSize bounds the signatures and attestations read.
+	maxBlobSize = 4 << 20
+)
+
+var errNoSignature = errors.New("no valid signature")
+
+type (
+	// simpleSigning is the payload of a cosign signature.
+	simpleSigning struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+
+	envelope struct {
+		PayloadType string `json:"payloadType"`
+		Payload     string `json:"payload"`
+		Signatures  []struct {
+			Sig string `json:"sig"`
+		} `json:"signatures"`
+	}
+
+	statement struct {
+		PredicateType string `json:"predicateType"`
+		Subject       []struct {
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+	}
+)
+
+// verifier checks images against the trust policies, using the cosign
+// signatures and attestations of the images only.
+type verifier struct {
+	policies        []policy
+	store           store
+	rejectUnmatched bool
+}
+
+// verify checks every name of img a policy applies to. The image is
+// compliant if, in each of these repositories, one of its digests is signed
+// and attested as the policy requires.
+func (v *verifier) verify(ctx context.Context, img unversioned.Image) result {
+	matched := false
+	var failure error
+
+	for _, n := range img.Names {
+		ref, err := name.ParseReference(n)
+		if err != nil {
+			continue
+		}
+
+		p, ok := match(v.policies, ref.Context().Name())
+		if !ok {
+			continue
+		}
+		matched = true
+
+		if len(img.Digests) == 0 {
+			return result{status: statusNonCompliant, reason: fmt.Sprintf("%s has no digest to verify against policy %q", n, p.name)}
+		}
+
+		err = v.verifyRepository(ctx, ref.Context(), img.Digests, p)
+		switch {
+		case err == nil:
+		case errors.Is(err, errNoSignature):
+			return result{status: statusNonCompliant, reason: fmt.Sprintf("%s does not satisfy policy %q: %v", n, p.name, err)}
+		default:
+			failure = fmt.Errorf("%s: %w", n, err)
+		}
+	}
+
+	switch {
+	case failure != nil:
+		return result{status: statusFailed, reason: failure.Error()}
+	case matched:
+		return result{status: statusCompliant}
+	case v.rejectUnmatched:
+		return result{status: statusNonCompliant, reason: "no trust policy applies to the image"}
+	default:
+		return result{status: statusCompliant}
+	}
+}
+
+// verifyRepository returns nil if one of the digests is signed in repo as
+// required by p. It returns errNoSignature if none is.
+func (v *verifier) verifyRepository(ctx context.Context, repo name.Repository, digests []string, p *policy) error {
+	var last error
+	for _, digest := range digests {
+		err := v.verifyDigest(ctx, repo, digest, p)
+		if err == nil {
+			return nil
+		}
+		// a missing signature of a digest does not hide a lookup failure
+		// of another
+		if last == nil || !errors.Is(err, errNoSignature) {
+			last = err
+		}
+	}
+	return last
+}
+
+func (v *verifier) verifyDigest(ctx context.Context, repo name.Repository, digest string, p *policy) error {
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		return fmt.Errorf("%w: invalid digest %q", errNoSignature, digest)
+	}
+
+	sigs, err := v.layers(ctx, repo, h, signatureSuffix, simpleSigningMediaType)
+	if err != nil {
+		return err
+	}
+	if !anyLayer(sigs, func(l layer) bool { return verifySignature(l, h, p.keys) }) {
+		return fmt.Errorf("%w for %s", errNoSignature, digest)
+	}
+
+	if len(p.attestations) == 0 {
+		return nil
+	}
+
+	atts, err := v.layers(ctx, repo, h, attestationSuffix, dsseMediaType)
+	if err != nil {
+		return err
+	}
+	for _, predicateType := range p.attestations {
+		if !anyLayer(atts, func(l layer) bool { return verifyAttestation(l, h, predicateType, p.keys) }) {
+			return fmt.Errorf("%w: missing %s attestation for %s", errNoSignature, predicateType, digest)
+		}
+	}
+
+	return nil
+}
+
+// layer is a signature or attestation.
+type layer struct {
+	blob        []byte
+	annotations map[string]string
+}
+
+// layers returns the layers of the given media type attached to the image
+// with digest h, where cosign tags them sha256-<hex>.<suffix>.
+func (v *verifier) layers(ctx context.Context, repo name.Repository, h v1.Hash, suffix string, mediaType types.MediaType) ([]layer, error) {
+	tag := repo.Tag(fmt.Sprintf("%s-%s.%s", h.Algorithm, h.Hex, suffix))
+	img, err := v.store.image(ctx, tag)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s: %w", tag, err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", tag, err)
+	}
+
+	layers := make([]layer, 0, len(manifest.Layers))
+	for _, desc := range manifest.Layers {
+		if desc.MediaType != mediaType || desc.Size > maxBlobSize {
+			continue
+		}
+
+		blob, err := readLayer(img, desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", tag, err)
+		}
+		layers = append(layers, layer{blob: blob, annotations: desc.Annotations})
+	}
+
+	return layers, nil
+}
+
+func readLayer(img v1.Image, h v1.Hash) ([]byte, error) {
+	l, err := img.LayerByDigest(h)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := l.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(io.LimitReader(rc, maxBlobSize))
+}
+
+func anyLayer(layers []layer, fn func(layer) bool) bool {
+	for _, l := range layers {
+		if fn(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignature checks that the simple signing payload of l is signed by
+// one of keys, and names the image with digest h.
+func verifySignature(l layer, h v1.Hash, keys []crypto.PublicKey) bool {
+	sig, err := base64.StdEncoding.DecodeString(l.annotations[signatureAnnotation])
+	if err != nil || !verifyAny(keys, l.blob, sig) {
+		return false
+	}
+
+	var payload simpleSigning
+	if err := json.Unmarshal(l.blob, &payload); err != nil {
+		return false
+	}
+	return payload.Critical.Image.DockerManifestDigest == h.String()
+}
+
+// verifyAttestation checks that l is a DSSE envelope signed by one of keys,
+// holding an in-toto statement about the image with digest h.
+func verifyAttestation(l layer, h v1.Hash, predicateType string, keys []crypto.PublicKey) bool {
+	var env envelope
+	if err := json.Unmarshal(l.blob, &env); err != nil || env.PayloadType != inTotoPayloadType {
+		return false
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return false
+	}
+
+	signed := false
+	pae := preAuthEncoding(env.PayloadType, payload)
+	for _, s := range env.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err == nil && verifyAny(keys, pae, sig) {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		return false
+	}
+
+	var st statement
+	if err := json.Unmarshal(payload, &st); err != nil || st.PredicateType != predicateType {
+		return false
+	}
+	for _, subject := range st.Subject {
+		if subject.Digest[h.Algorithm] == h.Hex {
+			return true
+		}
+	}
+	return false
+}
+
+// preAuthEncoding is the DSSE encoding of the signed content of an envelope.
+func preAuthEncoding(payloadType string, payload []byte) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	b.Write(payload)
+	return []byte(b.String())
+}
+
+func verifyAny(keys []crypto.PublicKey, msg, sig []byte) bool {
+	digest := sha256.Sum256(msg)
+	for _, key := range keys {
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, digest[:], sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(k, msg, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
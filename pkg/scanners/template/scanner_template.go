@@ -2,16 +2,18 @@ package template
 
 import (
 	"context"
-	"io"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Azure/eraser/api/unversioned"
 	"github.com/go-logr/logr"
-	"golang.org/x/sys/unix"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/Azure/eraser/pkg/metrics"
+	"github.com/Azure/eraser/pkg/pipeline"
 	util "github.com/Azure/eraser/pkg/utils"
 	"go.opentelemetry.io/otel/metric/global"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -22,18 +24,49 @@ type ImageProvider interface {
 	// receive list of all non-running, non-excluded images from collector container to process.
 	ReceiveImages() ([]unversioned.Image, error)
 
-	// sends non-compliant images found to eraser container for removal.
+	// sends non-compliant images found to eraser container for removal. It
+	// can be called several times to report images as they are scanned.
 	SendImages(nonCompliantImages, failedImages []unversioned.Image) error
 
 	// completes scanner communication process - required after custom scanning finishes.
 	Finish() error
 }
 
+// Aborter is implemented by the ImageProvider returned by NewImageProvider.
+// It is kept out of ImageProvider so that existing implementations still
+// compile.
+type Aborter interface {
+	// stops the pipeline when the scanner cannot complete, so that no image
+	// is removed based on partial results.
+	Abort(err error) error
+}
+
+// Abort stops the pipeline with err through provider, which must implement
+// Aborter.
+func Abort(provider ImageProvider, err error) error {
+	aborter, ok := provider.(Aborter)
+	if !ok {
+		return fmt.Errorf("image provider %T cannot abort", provider)
+	}
+	return aborter.Abort(err)
+}
+
 type config struct {
 	ctx                    context.Context
 	log                    logr.Logger
 	deleteScanFailedImages bool
 	reportMetrics          bool
+	socketPath             string
+	name                   string
+
+	client    *pipeline.Client
+	publisher *pipeline.Publisher
+
+	// outcome of the scan, reported to the manager when it finishes
+	started      time.Time
+	scanned      int
+	nonCompliant int
+	failed       int
 }
 
 type ConfigFunc func(*config)
@@ -45,6 +78,8 @@ func NewImageProvider(funcs ...ConfigFunc) ImageProvider {
 		log:                    logf.Log.WithName("scanner"),
 		deleteScanFailedImages: true,
 		reportMetrics:          false,
+		socketPath:             pipeline.SocketPath,
+		name:                   os.Getenv(pipeline.ScannerNameEnv),
 	}
 
 	// apply user config
@@ -55,39 +90,105 @@ func NewImageProvider(funcs ...ConfigFunc) ImageProvider {
 	return cfg
 }
 
-func (cfg *config) ReceiveImages() ([]unversioned.Image, error) {
-	var err error
-
-	if err := unix.Mkfifo(util.EraseCompleteScanPath, util.PipeMode); err != nil {
-		cfg.log.Error(err, "failed to create pipe", "pipeName", util.EraseCompleteScanPath)
-		return nil, err
+// connect connects to the pipeline served by the collector, once.
+func (cfg *config) connect() error {
+	if cfg.client != nil {
+		return nil
 	}
 
-	err = os.Chmod(util.EraseCompleteScanPath, 0o666)
+	client, err := pipeline.DialScanner(cfg.ctx, cfg.socketPath, cfg.name)
 	if err != nil {
-		cfg.log.Error(err, "unable to enable pipe for writing", "pipeName", util.EraseCompleteScanPath)
+		cfg.log.Error(err, "failed to connect to pipeline", "socket", cfg.socketPath)
+		return err
+	}
+
+	cfg.client = client
+	return nil
+}
+
+func (cfg *config) ReceiveImages() ([]unversioned.Image, error) {
+	if err := cfg.connect(); err != nil {
+		cfg.writeResult(err)
 		return nil, err
 	}
 
-	allImages, err := util.ReadCollectScanPipe(cfg.ctx)
+	allImages, err := cfg.client.Images(cfg.ctx)
 	if err != nil {
-		cfg.log.Error(err, "unable to read images from collect scan pipe")
+		cfg.log.Error(err, "unable to receive images from collector")
+		cfg.writeResult(err)
 		return nil, err
 	}
 
+	if cfg.started.IsZero() {
+		cfg.started = time.Now()
+	}
+	cfg.scanned += len(allImages)
+
 	return allImages, nil
 }
 
 func (cfg *config) SendImages(nonCompliantImages, failedImages []unversioned.Image) error {
+	if err := cfg.openVerdicts(); err != nil {
+		cfg.writeResult(err)
+		return err
+	}
+
+	failedStatus := pipeline.StatusFailed
 	if cfg.deleteScanFailedImages {
-		nonCompliantImages = append(nonCompliantImages, failedImages...)
+		failedStatus = pipeline.StatusNonCompliant
+	}
+	cfg.nonCompliant += len(nonCompliantImages)
+	cfg.failed += len(failedImages)
+
+	verdicts := make([]pipeline.Verdict, 0, len(nonCompliantImages)+len(failedImages))
+	for _, img := range nonCompliantImages {
+		verdicts = append(verdicts, pipeline.Verdict{Image: img, Status: pipeline.StatusNonCompliant})
+	}
+	for _, img := range failedImages {
+		verdicts = append(verdicts, pipeline.Verdict{Image: img, Status: failedStatus})
+	}
+
+	if err := cfg.publisher.Send(verdicts...); err != nil {
+		cfg.log.Error(err, "unable to send non-compliant images to eraser")
+		cfg.writeResult(err)
+		return err
+	}
+
+	return nil
+}
+
+func (cfg *config) openVerdicts() error {
+	if cfg.publisher != nil {
+		return nil
+	}
+	if err := cfg.connect(); err != nil {
+		return err
+	}
+
+	publisher, err := cfg.client.PublishVerdicts(cfg.ctx)
+	if err != nil {
+		cfg.log.Error(err, "unable to open verdict stream")
+		return err
+	}
+
+	cfg.publisher = publisher
+	return nil
+}
+
+func (cfg *config) Finish() error {
+	if err := cfg.openVerdicts(); err != nil {
+		cfg.writeResult(err)
+		return err
 	}
 
-	if err := util.WriteScanErasePipe(nonCompliantImages); err != nil {
-		cfg.log.Error(err, "unable to write non-compliant images to scan erase pipe")
+	if err := cfg.publisher.Close(); err != nil {
+		cfg.log.Error(err, "unable to complete verdict stream")
+		cfg.writeResult(err)
 		return err
 	}
 
+	cfg.writeResult(nil)
+
 	if cfg.reportMetrics {
 		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer cancel()
@@ -95,38 +196,78 @@ func (cfg *config) SendImages(nonCompliantImages, failedImages []unversioned.Ima
 		exporter, reader, provider := metrics.ConfigureMetrics(ctx, cfg.log, os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
 		global.SetMeterProvider(provider)
 
-		if err := metrics.RecordMetricsScanner(ctx, global.MeterProvider(), len(nonCompliantImages)); err != nil {
+		// images that failed to scan are removed along with the non-compliant ones
+		nonCompliant := cfg.nonCompliant
+		if cfg.deleteScanFailedImages {
+			nonCompliant += cfg.failed
+		}
+
+		if err := metrics.RecordMetricsScanner(ctx, global.MeterProvider(), nonCompliant); err != nil {
 			cfg.log.Error(err, "error recording metrics")
 			return err
 		}
 
 		metrics.ExportMetrics(cfg.log, exporter, reader)
 	}
-	return nil
-}
 
-func (cfg *config) Finish() error {
-	file, err := os.OpenFile(util.EraseCompleteScanPath, os.O_RDONLY, 0)
+	// wait for the eraser to be done with the images
+	removed := 0
+	err := cfg.client.WatchResults(cfg.ctx, func(r pipeline.RemovalResult) error {
+		if r.Outcome == pipeline.OutcomeRemoved {
+			removed++
+		}
+		return nil
+	})
 	if err != nil {
-		cfg.log.Error(err, "failed to open pipe", "pipeName", util.EraseCompleteScanPath)
+		cfg.log.Error(err, "eraser did not complete")
 		return err
 	}
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		cfg.log.Error(err, "failed to read pipe", "pipeName", util.EraseCompleteScanPath)
-		return err
+	cfg.log.Info("scanning complete, exiting", "removed", removed)
+	return cfg.client.Close()
+}
+
+// writeResult reports the outcome of the scan in the termination message of
+// the scanner container, for the manager to aggregate. A scan that did not
+// complete is reported with its error, so that it is counted as aborted; a
+// later call replaces the result, as the scan goes on or is aborted.
+func (cfg *config) writeResult(scanErr error) {
+	var duration time.Duration
+	if !cfg.started.IsZero() {
+		duration = time.Since(cfg.started)
 	}
 
-	file.Close()
+	result := unversioned.NodeResult{
+		Node: os.Getenv("NODE_NAME"),
+		Scans: []unversioned.ScanResult{{
+			Scanner:      cfg.name,
+			Scanned:      int64(cfg.scanned),
+			NonCompliant: int64(cfg.nonCompliant),
+			Failed:       int64(cfg.failed),
+			Duration:     metav1.Duration{Duration: duration},
+		}},
+	}
+	if scanErr != nil {
+		result.Scans[0].Error = scanErr.Error()
+	}
+	if err := util.WriteNodeResult(util.TerminationLogPath, &result); err != nil {
+		cfg.log.Error(err, "unable to report scan result", "path", util.TerminationLogPath)
+	}
+}
 
-	if string(data) != util.EraseCompleteMessage {
-		cfg.log.Info("garbage in pipe", "pipeName", util.EraseCompleteScanPath, "in_pipe", string(data))
+func (cfg *config) Abort(cause error) error {
+	cfg.writeResult(cause)
+
+	if err := cfg.connect(); err != nil {
 		return err
 	}
 
-	cfg.log.Info("scanning complete, exiting")
-	return nil
+	if err := cfg.client.Abort(cfg.ctx, cause); err != nil {
+		cfg.log.Error(err, "unable to abort pipeline")
+		return err
+	}
+
+	return cfg.client.Close()
 }
 
 // provide custom context.
@@ -150,6 +291,21 @@ func WithLogger(log logr.Logger) ConfigFunc {
 	}
 }
 
+// provide the path of the pipeline socket.
+func WithSocketPath(path string) ConfigFunc {
+	return func(cfg *config) {
+		cfg.socketPath = path
+	}
+}
+
+// provide the name of the scanner, when the pod runs several scanners.
+// defaults to the value of the ERASER_SCANNER_NAME environment variable.
+func WithScannerName(name string) ConfigFunc {
+	return func(cfg *config) {
+		cfg.name = name
+	}
+}
+
 // sets boolean for recording metrics.
 func WithMetrics(reportMetrics bool) ConfigFunc {
 	return func(cfg *config) {
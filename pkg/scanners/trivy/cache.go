@@ -0,0 +1,343 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/eraser/api/unversioned"
+	"github.com/aquasecurity/trivy-db/pkg/metadata"
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+)
+
+const (
+	resultCacheFile = "scan-results.json"
+
+	// resultReportsDir holds the trivy reports of the cached results, for
+	// the scanners exporting them.
+	resultReportsDir = "reports"
+)
+
+type (
+	// resultCache stores the scan status of images by digest, for the scans
+	// made with the same trivy DB and scanner configuration. The margin of
+	// the last scan of each image is kept whatever the DB and configuration,
+	// to decide which images to scan first.
+	resultCache struct {
+		path       string
+		reportDir  string
+		dbVersion  string
+		configHash string
+		entries    map[string]cacheEntry
+		history    map[string]scanMargin
+	}
+
+	resultCacheData struct {
+		DBVersion  string                `json:"dbVersion"`
+		ConfigHash string                `json:"configHash"`
+		Entries    map[string]cacheEntry `json:"entries"`
+		History    map[string]scanMargin `json:"history,omitempty"`
+	}
+
+	cacheEntry struct {
+		Status    ScanStatus `json:"status"`
+		ScannedAt time.Time  `json:"scannedAt"`
+		// Report names the file in the reports directory holding the
+		// report of the scan, if it is exported.
+		Report string `json:"report,omitempty"`
+	}
+)
+
+// loadResultCache reads the scan results stored in dir. The results of a
+// previous trivy DB or scanner configuration are dropped.
+func loadResultCache(dir, dbVersion, configHash string) (*resultCache, error) {
+	c := &resultCache{
+		path:       filepath.Join(dir, resultCacheFile),
+		reportDir:  filepath.Join(dir, resultReportsDir),
+		dbVersion:  dbVersion,
+		configHash: configHash,
+		entries:    make(map[string]cacheEntry),
+		history:    make(map[string]scanMargin),
+	}
+
+	b, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data resultCacheData
+	if err := json.Unmarshal(b, &data); err != nil {
+		log.Error(err, "ignoring invalid scan result cache", "path", c.path)
+		return c, nil
+	}
+
+	for digest, margin := range data.History {
+		c.history[digest] = margin
+	}
+
+	if data.DBVersion != dbVersion || data.ConfigHash != configHash {
+		log.Info("scan result cache is stale, rescanning all images", "dbVersion", dbVersion, "cachedDBVersion", data.DBVersion)
+		return c, nil
+	}
+
+	for digest, entry := range data.Entries {
+		c.entries[digest] = entry
+	}
+
+	return c, nil
+}
+
+// get returns the cached status of img. A nil cache never hits.
+func (c *resultCache) get(img unversioned.Image) (ScanStatus, bool) {
+	if c == nil {
+		return StatusFailed, false
+	}
+
+	for _, key := range cacheKeys(img) {
+		if entry, ok := c.entries[key]; ok {
+			return entry.Status, true
+		}
+	}
+
+	return StatusFailed, false
+}
+
+// put records the status of img, and the file of its report stored by
+// putReport if any. Failed scans are not cached so that they are retried on
+// the next run.
+func (c *resultCache) put(img unversioned.Image, status ScanStatus, report string) {
+	if c == nil || status == StatusFailed {
+		return
+	}
+
+	entry := cacheEntry{Status: status, ScannedAt: time.Now().UTC(), Report: report}
+	for _, key := range cacheKeys(img) {
+		c.entries[key] = entry
+	}
+}
+
+// putReport stores the report of img and returns the name of its file, so
+// that the report can be exported again when the cached result is used. It
+// is safe for concurrent use. A nil cache stores nothing.
+func (c *resultCache) putReport(img unversioned.Image, report *trivyTypes.Report) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(c.reportDir, 0o700); err != nil {
+		return "", err
+	}
+
+	name := filepath.Base(strings.ReplaceAll(reportKey(img), ":", "-")) + ".json"
+	p := filepath.Join(c.reportDir, name)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return "", err
+	}
+	return name, os.Rename(tmp, p)
+}
+
+// report returns the report stored with the cached result of img.
+func (c *resultCache) report(img unversioned.Image) (*trivyTypes.Report, error) {
+	if c == nil {
+		return nil, errors.New("no scan result cache")
+	}
+
+	for _, key := range cacheKeys(img) {
+		entry, ok := c.entries[key]
+		if !ok {
+			continue
+		}
+		if entry.Report == "" {
+			return nil, errors.New("no report stored with the cached result")
+		}
+
+		b, err := os.ReadFile(filepath.Join(c.reportDir, filepath.Base(entry.Report)))
+		if err != nil {
+			return nil, err
+		}
+		var report trivyTypes.Report
+		if err := json.Unmarshal(b, &report); err != nil {
+			return nil, fmt.Errorf("invalid cached report: %w", err)
+		}
+		return &report, nil
+	}
+
+	return nil, errors.New("no cached result")
+}
+
+// lastMargin returns the margin of the last scan of img, if it was ever
+// scanned. A nil cache knows of no scan.
+func (c *resultCache) lastMargin(img unversioned.Image) (scanMargin, bool) {
+	if c == nil {
+		return scanMargin{}, false
+	}
+
+	for _, key := range cacheKeys(img) {
+		if margin, ok := c.history[key]; ok {
+			return margin, true
+		}
+	}
+
+	return scanMargin{}, false
+}
+
+// putMargin records the margin of the last scan of img.
+func (c *resultCache) putMargin(img unversioned.Image, margin scanMargin) {
+	if c == nil {
+		return
+	}
+
+	for _, key := range cacheKeys(img) {
+		c.history[key] = margin
+	}
+}
+
+// save writes the cache back to its file, keeping only the images in keep so
+// that images removed from the node do not accumulate.
+func (c *resultCache) save(keep []unversioned.Image) error {
+	if c == nil {
+		return nil
+	}
+
+	data := resultCacheData{
+		DBVersion:  c.dbVersion,
+		ConfigHash: c.configHash,
+		Entries:    make(map[string]cacheEntry),
+		History:    make(map[string]scanMargin),
+	}
+	reports := make(map[string]bool)
+	for _, img := range keep {
+		for _, key := range cacheKeys(img) {
+			if entry, ok := c.entries[key]; ok {
+				data.Entries[key] = entry
+				if entry.Report != "" {
+					reports[entry.Report] = true
+				}
+			}
+			if margin, ok := c.history[key]; ok {
+				data.History[key] = margin
+			}
+		}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so an interrupted scanner does not
+	// leave a truncated cache behind
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+
+	return c.pruneReports(reports)
+}
+
+// pruneReports deletes the stored reports that no cached result refers to.
+func (c *resultCache) pruneReports(keep map[string]bool) error {
+	files, err := os.ReadDir(c.reportDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if keep[f.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.reportDir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheKeys returns the digests identifying img, or its ID if it has none.
+func cacheKeys(img unversioned.Image) []string {
+	if len(img.Digests) > 0 {
+		return img.Digests
+	}
+	if img.ImageID != "" {
+		return []string{img.ImageID}
+	}
+	return nil
+}
+
+// dbVersion identifies the trivy DB in cacheDir by its schema version and
+// build time.
+func dbVersion(cacheDir string) (string, error) {
+	meta, err := metadata.NewClient(cacheDir).Get()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%s", meta.Version, meta.UpdatedAt.UTC().Format(time.RFC3339)), nil
+}
+
+// configHash hashes the settings that change the status of a scan.
+func configHash(cfg *Config) (string, error) {
+	settings := struct {
+		TrivyVersion   string
+		IgnoreUnfixed  bool
+		Types          []string
+		SecurityChecks []string
+		Severities     []string
+		Thresholds     ThresholdConfig
+		MinCount       int
+		Feeds          string
+		Ignore         []string
+		Policy         PolicyConfig
+	}{
+		TrivyVersion:   trivyVersion,
+		IgnoreUnfixed:  cfg.Vulnerabilities.IgnoreUnfixed,
+		Types:          sortedCopy(cfg.Vulnerabilities.Types),
+		SecurityChecks: sortedCopy(cfg.Vulnerabilities.SecurityChecks),
+		Severities:     sortedCopy(cfg.Vulnerabilities.Severities),
+		Thresholds:     cfg.Vulnerabilities.Thresholds,
+		MinCount:       cfg.Vulnerabilities.MinCount,
+		Ignore:         ignoreKeys(cfg.Vulnerabilities.Ignore, time.Now()),
+		Policy:         cfg.Policy,
+	}
+
+	// updated feeds change which vulnerabilities count
+	feeds, err := feedDigest(&cfg.Vulnerabilities.Thresholds)
+	if err != nil {
+		return "", err
+	}
+	settings.Feeds = feeds
+
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sortedCopy(s []string) []string {
+	c := append([]string(nil), s...)
+	sort.Strings(c)
+	return c
+}
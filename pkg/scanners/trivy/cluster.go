@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	fanalImage "github.com/aquasecurity/trivy/pkg/fanal/image"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/Azure/eraser/api/unversioned"
+	"github.com/Azure/eraser/pkg/utils"
+)
+
+// remoteFanalOptions only look for images in their registries.
+var remoteFanalOptions = []fanalImage.Option{
+	fanalImage.DisableDockerd(),
+	fanalImage.DisableContainerd(),
+	fanalImage.DisablePodman(),
+}
+
+type dockerConfig struct {
+	Auths map[string]json.RawMessage `json:"auths"`
+}
+
+// runClusterScan scans each image of a cluster scan once, from its registry,
+// and stores the digests to remove for the eraser on every node.
+func runClusterScan(userConfig *Config) error {
+	data, err := os.ReadFile(*clusterScan)
+	if err != nil {
+		return err
+	}
+
+	var images []utils.ClusterScanImage
+	if err := json.Unmarshal(data, &images); err != nil {
+		return fmt.Errorf("invalid cluster scan images: %w", err)
+	}
+
+	if *pullSecrets != "" {
+		if err := setupRegistryAuth(*pullSecrets); err != nil {
+			return fmt.Errorf("unable to set up registry authentication: %w", err)
+		}
+	}
+
+	s, err := newImageScanner(userConfig, remoteFanalOptions)
+	if err != nil {
+		return err
+	}
+
+	log.Info("starting cluster scan", "images", len(images), "parallelism", userConfig.Parallelism)
+
+	toScan := make([]unversioned.Image, 0, len(images))
+	platforms := make(map[string]string, len(images))
+	for _, img := range images {
+		toScan = append(toScan, unversioned.Image{ImageID: img.Digest, Names: img.Refs})
+		platforms[img.Digest] = img.Platform
+	}
+
+	vulnerableImages, failedImages, err := scan(&platformScanner{ImageScanner: s, platforms: platforms}, nil, toScan, userConfig.Parallelism)
+	if err != nil {
+		log.Error(err, "cluster scan incomplete")
+	}
+
+	var vulnerable, failed []string
+	for _, img := range vulnerableImages {
+		vulnerable = append(vulnerable, img.ImageID)
+	}
+	for _, img := range failedImages {
+		failed = append(failed, img.ImageID)
+	}
+
+	log.Info("cluster scan complete", "scanned", len(images), "vulnerable", len(vulnerable), "failed", len(failed))
+
+	if userConfig.DeleteFailedImages {
+		vulnerable = append(vulnerable, failed...)
+	}
+
+	return writeScanResult(context.Background(), vulnerable)
+}
+
+// platformScanner scans each image digest for the platform the nodes run.
+// An index digest is shared by all platforms.
+type platformScanner struct {
+	*ImageScanner
+	platforms map[string]string
+}
+
+func (p *platformScanner) Scan(img unversioned.Image) (ScanStatus, error) {
+	// a copy, as images are scanned concurrently
+	s := *p.ImageScanner
+	s.trivyScanConfig.dockerOptions.Platform = p.platforms[img.ImageID]
+	return s.Scan(img)
+}
+
+// setupRegistryAuth merges the docker config of every pull secret mounted in
+// dir into a single config, for the registry client to authenticate with.
+func setupRegistryAuth(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*", corev1.DockerConfigJsonKey))
+	if err != nil {
+		return err
+	}
+
+	merged := dockerConfig{Auths: make(map[string]json.RawMessage)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var cfg dockerConfig
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("invalid docker config %s: %w", file, err)
+		}
+		for registry, auth := range cfg.Auths {
+			merged.Auths[registry] = auth
+		}
+	}
+
+	configDir, err := os.MkdirTemp("", "docker-config")
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), data, 0o600); err != nil {
+		return err
+	}
+
+	log.Info("using registry credentials from pull secrets", "secrets", len(files), "registries", len(merged.Auths))
+	return os.Setenv("DOCKER_CONFIG", configDir)
+}
+
+// writeScanResult stores the digests to remove in a configmap for the
+// manager.
+func writeScanResult(ctx context.Context, digests []string) error {
+	if digests == nil {
+		digests = []string{}
+	}
+
+	data, err := json.Marshal(digests)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      utils.ScanResultName(*scanReport),
+			Namespace: utils.GetNamespace(),
+			Labels:    map[string]string{utils.ScanReportLabelKey: *scanReport},
+		},
+		Immutable: utils.BoolPtr(true),
+		Data:      map[string]string{utils.ScanResultDataKey: string(data)},
+	}
+
+	return c.Create(ctx, configMap)
+}
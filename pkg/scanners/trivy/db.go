@@ -0,0 +1,176 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	"github.com/aquasecurity/trivy-db/pkg/db"
+	"github.com/aquasecurity/trivy-db/pkg/metadata"
+)
+
+const (
+	dbFile       = "trivy.db"
+	metadataFile = "metadata.json"
+)
+
+// fetchDB puts the trivy DB in dir: extracted from the configured bundle,
+// downloaded from DBRepo, or already there when offline. The DB is
+// validated in every case.
+func fetchDB(cfg *Config, dir string) error {
+	switch {
+	case cfg.DB.Bundle != "":
+		if err := extractBundle(cfg.DB.Bundle, cfg.DB.BundleDigest, dir); err != nil {
+			return fmt.Errorf("unable to extract DB bundle %s: %w", cfg.DB.Bundle, err)
+		}
+	case cfg.DB.Offline:
+		log.Info("offline, using the DB in place", "dir", dir)
+	default:
+		if err := downloadDB(cfg, dir); err != nil {
+			return err
+		}
+	}
+
+	return validateDB(dir, cfg.DB.MaxAge)
+}
+
+// validateDB checks that dir holds a DB of the schema this trivy version
+// reads, updated no longer than maxAge ago if set.
+func validateDB(dir string, maxAge eraserv1alpha1.Duration) error {
+	meta, err := metadata.NewClient(dir).Get()
+	if err != nil {
+		return fmt.Errorf("no valid DB metadata in %s: %w", dir, err)
+	}
+	if meta.Version != db.SchemaVersion {
+		return fmt.Errorf("unsupported schema version %d of the DB in %s, expected %d", meta.Version, dir, db.SchemaVersion)
+	}
+	if _, err := os.Stat(db.Path(dir)); err != nil {
+		return err
+	}
+	if maxAge > 0 && time.Since(meta.UpdatedAt) > time.Duration(maxAge) {
+		return fmt.Errorf("DB in %s updated at %s, longer ago than the maximum age %s", dir, meta.UpdatedAt.UTC().Format(time.RFC3339), time.Duration(maxAge))
+	}
+
+	log.Info("trivy DB is valid", "dir", dir, "updatedAt", meta.UpdatedAt, "nextUpdate", meta.NextUpdate)
+	return nil
+}
+
+// importDB copies the DB fetched into the shared directory src to the cache
+// directory dst, where the scanner opens it for writing.
+func importDB(src, dst string, maxAge eraserv1alpha1.Duration) error {
+	if err := validateDB(src, maxAge); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(db.Dir(dst), 0o700); err != nil {
+		return err
+	}
+
+	for _, name := range []string{dbFile, metadataFile} {
+		f, err := os.Open(filepath.Join(db.Dir(src), name))
+		if err != nil {
+			return err
+		}
+		err = writeDBFile(filepath.Join(db.Dir(dst), name), f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("imported shared trivy DB", "dir", src)
+	return nil
+}
+
+// extractBundle extracts a DB bundle, the db.tar.gz archive of the trivy-db
+// artifact, into dir. The bundle must match digest if set.
+func extractBundle(bundle, digest, dir string) error {
+	f, err := os.Open(bundle)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if digest != "" {
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != strings.TrimPrefix(digest, "sha256:") {
+			return fmt.Errorf("digest sha256:%s does not match %s", sum, digest)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	if err := os.MkdirAll(db.Dir(dir), 0o700); err != nil {
+		return err
+	}
+
+	found := make(map[string]bool)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// only the DB files are extracted, whatever their directory
+		name := path.Base(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || (name != dbFile && name != metadataFile) {
+			continue
+		}
+		if err := writeDBFile(filepath.Join(db.Dir(dir), name), tr); err != nil {
+			return err
+		}
+		found[name] = true
+	}
+
+	for _, name := range []string{dbFile, metadataFile} {
+		if !found[name] {
+			return fmt.Errorf("no %s in bundle", name)
+		}
+	}
+
+	log.Info("extracted trivy DB bundle", "bundle", bundle, "dir", dir)
+	return nil
+}
+
+// writeDBFile writes the contents of r to path through a temporary file, so
+// that a failed write leaves any previous file in place.
+func writeDBFile(path string, r io.Reader) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
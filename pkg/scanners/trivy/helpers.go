@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
+	"github.com/Azure/eraser/pkg/pipeline"
 	"github.com/aquasecurity/trivy-db/pkg/db"
 	dlDb "github.com/aquasecurity/trivy/pkg/db"
 	"github.com/aquasecurity/trivy/pkg/detector/ospkg"
@@ -33,7 +34,9 @@ func loadConfig(filename string) (Config, error) {
 		return cfg, err
 	}
 
-	scanCfgYaml := eraserConfig.Components.Scanner.Config
+	// the pod may run other scanners, with their own config
+	scanner := eraserConfig.Components.ScannerNamed(os.Getenv(pipeline.ScannerNameEnv))
+	scanCfgYaml := scanner.Config
 	scanCfgBytes := []byte("")
 	if scanCfgYaml != nil {
 		scanCfgBytes = []byte(*scanCfgYaml)
@@ -44,9 +47,28 @@ func loadConfig(filename string) (Config, error) {
 		return cfg, err
 	}
 
+	if cfg.Parallelism == 0 {
+		cfg.Parallelism = defaultParallelism(&scanner.Request, &scanner.Limit)
+	}
+
 	return cfg, nil
 }
 
+// defaultParallelism returns how many images to scan at once: one per CPU
+// requested, as far as the memory limit allows scanMemory for each scan.
+func defaultParallelism(request, limit *eraserv1alpha1.ResourceRequirements) int {
+	n := int(request.CPU.MilliValue() / 1000)
+	if !limit.Mem.IsZero() {
+		if byMem := int(limit.Mem.Value() / scanMemory); byMem < n {
+			n = byMem
+		}
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
 // side effects: map `m` will be modified according to the values in `commaSeparatedList`.
 func parseCommaSeparatedOptions(m map[string]bool, commaSeparatedList string) error {
 	list := strings.Split(commaSeparatedList, ",")
@@ -67,7 +89,7 @@ func downloadAndInitDB(cfg *Config) error {
 		return fmt.Errorf("valid configuration required")
 	}
 
-	err := downloadDB(cfg)
+	err := prepareDB(cfg)
 	if err != nil {
 		return err
 	}
@@ -80,12 +102,29 @@ func downloadAndInitDB(cfg *Config) error {
 	return nil
 }
 
-func downloadDB(cfg *Config) error {
+// prepareDB puts the DB in the cache directory, copied from the shared
+// directory fetched by the manager if any. The scanner fetches the DB itself
+// when there is no valid shared DB.
+func prepareDB(cfg *Config) error {
+	if *sharedDBDir != "" {
+		err := importDB(*sharedDBDir, cfg.CacheDir, cfg.DB.MaxAge)
+		if err == nil {
+			return nil
+		}
+		log.Error(err, "unable to use the shared DB, fetching it", "dir", *sharedDBDir)
+	}
+
+	return fetchDB(cfg, cfg.CacheDir)
+}
+
+// downloadDB downloads the DB from DBRepo into dir, unless the DB there is
+// up to date.
+func downloadDB(cfg *Config, dir string) error {
 	if cfg == nil {
 		return fmt.Errorf("valid configuration required")
 	}
 
-	client := dlDb.NewClient(cfg.CacheDir, true, true, dlDb.WithDBRepository(cfg.DBRepo))
+	client := dlDb.NewClient(dir, true, true, dlDb.WithDBRepository(cfg.DBRepo))
 	ctx := context.Background()
 	needsUpdate, err := client.NeedsUpdate(trivyVersion, false)
 	if err != nil {
@@ -93,7 +132,7 @@ func downloadDB(cfg *Config) error {
 	}
 
 	if needsUpdate {
-		if err = client.Download(ctx, cfg.CacheDir); err != nil {
+		if err = client.Download(ctx, dir); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+// dateLayout is the layout of expiry dates, as in .trivyignore.yaml.
+const dateLayout = "2006-01-02"
+
+// Date is a day, or a time, after which an ignore entry no longer applies.
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(d.Format(dateLayout))
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		d.Time = time.Time{}
+		return nil
+	}
+
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, s); err != nil {
+			return fmt.Errorf("invalid date %q, must be YYYY-MM-DD or RFC 3339", s)
+		}
+	}
+	d.Time = t
+	return nil
+}
+
+// ignoreList holds the ignore entries that apply at the time of the scan.
+type ignoreList []IgnoreEntry
+
+func validateIgnores(entries []IgnoreEntry) error {
+	for i, entry := range entries {
+		if entry.ID == "" && entry.Package == "" {
+			return fmt.Errorf("ignore entry %d needs an id, a package or both", i)
+		}
+		if strings.TrimSpace(entry.Reason) == "" {
+			return fmt.Errorf("ignore entry %d (%s) needs a reason", i, entry)
+		}
+	}
+	return nil
+}
+
+// activeIgnores returns the entries that have not expired at now. Expired
+// entries are logged so that they are renewed or removed.
+func activeIgnores(entries []IgnoreEntry, now time.Time) ignoreList {
+	active := make(ignoreList, 0, len(entries))
+	for _, entry := range entries {
+		if entry.expired(now) {
+			log.Info("ignore entry expired, vulnerabilities it matches count again", "entry", entry.String(), "expires", entry.Expires.Format(dateLayout))
+			continue
+		}
+		active = append(active, entry)
+	}
+	return active
+}
+
+func (e IgnoreEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires.Time)
+}
+
+// match returns the entry ignoring the vulnerability, if any.
+func (l ignoreList) match(vuln *trivyTypes.DetectedVulnerability) (IgnoreEntry, bool) {
+	for _, entry := range l {
+		if entry.ID != "" && !strings.EqualFold(entry.ID, vuln.VulnerabilityID) {
+			continue
+		}
+		if entry.Package != "" && entry.Package != vuln.PkgName {
+			continue
+		}
+		return entry, true
+	}
+	return IgnoreEntry{}, false
+}
+
+// ignoreKeys identifies the entries that apply at now, for the result cache
+// to notice when they change or expire.
+func ignoreKeys(entries []IgnoreEntry, now time.Time) []string {
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.expired(now) {
+			keys = append(keys, entry.String())
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (e IgnoreEntry) String() string {
+	switch {
+	case e.ID == "":
+		return "package " + e.Package
+	case e.Package == "":
+		return e.ID
+	default:
+		return e.ID + " in package " + e.Package
+	}
+}
+
+type ignoredVulnerability struct {
+	vuln  *trivyTypes.DetectedVulnerability
+	entry IgnoreEntry
+}
+
+// reportIgnored logs the vulnerabilities that would have made img
+// non-compliant, and the entries that ignored them.
+func reportIgnored(img unversioned.Image, ignored []ignoredVulnerability) {
+	for _, iv := range ignored {
+		expires := "never"
+		if !iv.entry.Expires.IsZero() {
+			expires = iv.entry.Expires.Format(dateLayout)
+		}
+		log.Info("vulnerability ignored, image kept",
+			"imageID", img.ImageID,
+			"names", img.Names,
+			"vulnerability", iv.vuln.VulnerabilityID,
+			"package", iv.vuln.PkgName,
+			"installedVersion", iv.vuln.InstalledVersion,
+			"severity", iv.vuln.Severity,
+			"entry", iv.entry.String(),
+			"reason", iv.entry.Reason,
+			"expires", expires,
+		)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	apkversion "github.com/knqyf263/go-apk-version"
+	debversion "github.com/knqyf263/go-deb-version"
+	rpmversion "github.com/knqyf263/go-rpm-version"
+
+	"github.com/aquasecurity/go-version/pkg/version"
+	fanalos "github.com/aquasecurity/trivy/pkg/fanal/analyzer/os"
+	fanalTypes "github.com/aquasecurity/trivy/pkg/fanal/types"
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+)
+
+// validatePolicy checks the rules of the policy.
+func validatePolicy(cfg *PolicyConfig) error {
+	for i, rule := range cfg.Packages {
+		if rule.Name == "" {
+			return fmt.Errorf("package rule %d has no name", i)
+		}
+		if _, err := path.Match(rule.Name, ""); err != nil {
+			return fmt.Errorf("invalid package name pattern %q: %w", rule.Name, err)
+		}
+	}
+	for _, license := range cfg.Licenses {
+		if _, err := path.Match(license, ""); err != nil {
+			return fmt.Errorf("invalid license pattern %q: %w", license, err)
+		}
+	}
+	return nil
+}
+
+// needsPackages returns true if the policy checks the packages of images, so
+// that trivy lists them all.
+func (cfg *PolicyConfig) needsPackages() bool {
+	return len(cfg.Packages) > 0 || len(cfg.Licenses) > 0
+}
+
+// securityChecks returns the trivy security checks the policy needs.
+func (cfg *PolicyConfig) securityChecks() []string {
+	var checks []string
+	if len(cfg.Licenses) > 0 {
+		checks = append(checks, trivyTypes.SecurityCheckLicense)
+	}
+	if cfg.Secrets {
+		checks = append(checks, securityCheckSecret)
+	}
+	return checks
+}
+
+// evaluatePolicy returns the reason the report breaks the policy, if it
+// does.
+func evaluatePolicy(cfg *PolicyConfig, report *trivyTypes.Report) (string, bool) {
+	for i := range report.Results {
+		result := &report.Results[i]
+
+		for j := range result.Packages {
+			pkg := &result.Packages[j]
+			if reason, ok := matchPackage(cfg, result.Type, pkg); ok {
+				return reason, true
+			}
+		}
+
+		for j := range result.Licenses {
+			if pattern, ok := matchLicense(cfg.Licenses, result.Licenses[j].Name); ok {
+				return fmt.Sprintf("license %s matches %q", result.Licenses[j].Name, pattern), true
+			}
+		}
+
+		if cfg.Secrets && len(result.Secrets) > 0 {
+			secret := result.Secrets[0]
+			return fmt.Sprintf("secret %s found in %s", secret.RuleID, result.Target), true
+		}
+	}
+
+	return "", false
+}
+
+func matchPackage(cfg *PolicyConfig, pkgType string, pkg *fanalTypes.Package) (string, bool) {
+	for _, license := range pkg.Licenses {
+		if pattern, ok := matchLicense(cfg.Licenses, license); ok {
+			return fmt.Sprintf("package %s is licensed under %s, which matches %q", pkg.Name, license, pattern), true
+		}
+	}
+
+	for _, rule := range cfg.Packages {
+		if ok, _ := path.Match(rule.Name, pkg.Name); !ok {
+			continue
+		}
+		if rule.Type != "" && rule.Type != pkgType {
+			continue
+		}
+		if rule.Below == "" {
+			return fmt.Sprintf("package %s is banned", pkg.Name), true
+		}
+
+		v := packageVersion(pkg)
+		cmp, err := compareVersions(pkgType, v, rule.Below)
+		if err != nil {
+			log.V(1).Info("unable to compare package version", "package", pkg.Name, "version", v, "error", err.Error())
+			continue
+		}
+		if cmp < 0 {
+			return fmt.Sprintf("package %s %s is below %s", pkg.Name, v, rule.Below), true
+		}
+	}
+
+	return "", false
+}
+
+func matchLicense(patterns []string, license string) (string, bool) {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(license)); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// packageVersion returns the full version of pkg, as the package manager of
+// the image spells it.
+func packageVersion(pkg *fanalTypes.Package) string {
+	v := pkg.Version
+	if pkg.Release != "" {
+		v += "-" + pkg.Release
+	}
+	if pkg.Epoch != 0 {
+		v = fmt.Sprintf("%d:%s", pkg.Epoch, v)
+	}
+	return v
+}
+
+// compareVersions compares two versions of a package, following the rules
+// of the package manager it comes from: the type of the packages of an OS is
+// its family. Packages of languages are compared as semantic versions.
+func compareVersions(pkgType, a, b string) (int, error) {
+	switch pkgType {
+	case fanalos.Alpine:
+		va, err := apkversion.NewVersion(a)
+		if err != nil {
+			return 0, err
+		}
+		vb, err := apkversion.NewVersion(b)
+		if err != nil {
+			return 0, err
+		}
+		return va.Compare(vb), nil
+	case fanalos.Debian, fanalos.Ubuntu:
+		va, err := debversion.NewVersion(a)
+		if err != nil {
+			return 0, err
+		}
+		vb, err := debversion.NewVersion(b)
+		if err != nil {
+			return 0, err
+		}
+		return va.Compare(vb), nil
+	case fanalos.RedHat, fanalos.CentOS, fanalos.Rocky, fanalos.Alma, fanalos.Fedora, fanalos.Amazon,
+		fanalos.Oracle, fanalos.CBLMariner, fanalos.OpenSUSE, fanalos.OpenSUSELeap,
+		fanalos.OpenSUSETumbleweed, fanalos.SLES, fanalos.Photon:
+		return rpmversion.NewVersion(a).Compare(rpmversion.NewVersion(b)), nil
+	default:
+		va, err := version.Parse(a)
+		if err != nil {
+			return 0, err
+		}
+		vb, err := version.Parse(b)
+		if err != nil {
+			return 0, err
+		}
+		return va.Compare(vb), nil
+	}
+}
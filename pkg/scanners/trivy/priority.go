@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+type (
+	// scanMargin tells how close a scan came to making an image
+	// non-compliant.
+	scanMargin struct {
+		// Count is how many more vulnerabilities would have counted for the
+		// image to be non-compliant, 0 if it was.
+		Count int `json:"count"`
+		// CVSS is the highest CVSS score of the vulnerabilities found.
+		CVSS float64 `json:"cvss,omitempty"`
+	}
+
+	// marginScanner is implemented by scanners telling the margin of their
+	// last scan of an image.
+	marginScanner interface {
+		Margin(imageID string) (scanMargin, bool)
+	}
+
+	// marginStore records the margin of each image scanned, from concurrent
+	// scans.
+	marginStore struct {
+		mu      sync.Mutex
+		margins map[string]scanMargin
+	}
+)
+
+func newMarginStore() *marginStore {
+	return &marginStore{margins: make(map[string]scanMargin)}
+}
+
+func (m *marginStore) set(imageID string, margin scanMargin) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.margins[imageID] = margin
+}
+
+func (m *marginStore) get(imageID string) (scanMargin, bool) {
+	if m == nil {
+		return scanMargin{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	margin, ok := m.margins[imageID]
+	return margin, ok
+}
+
+// sortByPriority orders the indices of the images to scan so that the ones
+// most likely to be non-compliant are scanned before the total timeout:
+// those never scanned first, then those whose last scan came closest to
+// making them non-compliant, then the largest ones.
+func sortByPriority(images []unversioned.Image, pending []int, cache *resultCache) {
+	type priority struct {
+		margin  scanMargin
+		scanned bool
+	}
+
+	priorities := make(map[int]priority, len(pending))
+	for _, idx := range pending {
+		margin, scanned := cache.lastMargin(images[idx])
+		priorities[idx] = priority{margin: margin, scanned: scanned}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		a, b := priorities[pending[i]], priorities[pending[j]]
+		if a.scanned != b.scanned {
+			return !a.scanned
+		}
+		if a.scanned {
+			if a.margin.Count != b.margin.Count {
+				return a.margin.Count < b.margin.Count
+			}
+			if a.margin.CVSS != b.margin.CVSS {
+				return a.margin.CVSS > b.margin.CVSS
+			}
+		}
+		return images[pending[i]].Size > images[pending[j]].Size
+	})
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	trivyReport "github.com/aquasecurity/trivy/pkg/report"
+	"github.com/aquasecurity/trivy/pkg/report/cyclonedx"
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/Azure/eraser/api/unversioned"
+	"github.com/Azure/eraser/pkg/pipeline"
+)
+
+const (
+	reportFormatJSON      = "json"
+	reportFormatSARIF     = "sarif"
+	reportFormatCycloneDX = "cyclonedx"
+
+	defaultWebhookTimeout = 30 * time.Second
+
+	// reportConfigMediaType is the config media type of the OCI artifacts
+	// holding reports.
+	reportConfigMediaType = "application/vnd.eraser.report.config.v1+json"
+
+	annotationNode    = "sh.eraser.report.node"
+	annotationImageID = "sh.eraser.report.image-id"
+	annotationStatus  = "sh.eraser.report.status"
+)
+
+var reportExtensions = map[string]string{
+	reportFormatJSON:      ".json",
+	reportFormatSARIF:     ".sarif.json",
+	reportFormatCycloneDX: ".cdx.json",
+}
+
+var reportMediaTypes = map[string]string{
+	reportFormatJSON:      "application/vnd.eraser.report.v1+json",
+	reportFormatSARIF:     "application/sarif+json",
+	reportFormatCycloneDX: "application/vnd.cyclonedx+json",
+}
+
+var reportStatuses = map[ScanStatus]pipeline.Status{
+	StatusOK:           pipeline.StatusCompliant,
+	StatusNonCompliant: pipeline.StatusNonCompliant,
+	StatusFailed:       pipeline.StatusFailed,
+}
+
+type (
+	// imageReport is the JSON report of an image: the trivy report along
+	// with what identifies the image on its node.
+	imageReport struct {
+		Node    string             `json:"node,omitempty"`
+		ImageID string             `json:"imageID"`
+		Digests []string           `json:"digests,omitempty"`
+		Names   []string           `json:"names,omitempty"`
+		Status  pipeline.Status    `json:"status"`
+		Report  *trivyTypes.Report `json:"report"`
+	}
+
+	// exportScanner is implemented by scanners exporting the report or SBOM
+	// of each image they scan. Their reports are cached along with the scan
+	// results, so that images with a cached result are exported again.
+	exportScanner interface {
+		// Exports reports whether any report or SBOM is exported.
+		Exports() bool
+		// ScanReport scans img like Scan, and also returns its report, or
+		// nil if the scan failed.
+		ScanReport(img unversioned.Image) (ScanStatus, *trivyTypes.Report, error)
+		// Export exports the report of img, scanned with status.
+		Export(img unversioned.Image, report *trivyTypes.Report, status ScanStatus)
+	}
+
+	// reportExporter sends the reports of the images scanned to the sinks
+	// enabled in its configuration. It is safe for concurrent use.
+	reportExporter struct {
+		cfg    ReportConfig
+		node   string
+		client *http.Client
+	}
+)
+
+func validateReports(cfg *ReportConfig) error {
+	if !cfg.enabled() {
+		return nil
+	}
+	if _, ok := reportExtensions[cfg.Format]; !ok {
+		return fmt.Errorf("invalid report format %q, must be %q, %q or %q", cfg.Format, reportFormatJSON, reportFormatSARIF, reportFormatCycloneDX)
+	}
+	if cfg.Webhook.URL != "" {
+		u, err := url.Parse(cfg.Webhook.URL)
+		if err != nil {
+			return fmt.Errorf("invalid report webhook URL: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid report webhook URL %q, must be http or https", cfg.Webhook.URL)
+		}
+	}
+	if cfg.OCI.Repository != "" {
+		if _, err := name.NewRepository(cfg.OCI.Repository); err != nil {
+			return fmt.Errorf("invalid report repository: %w", err)
+		}
+	}
+	return nil
+}
+
+func (cfg *ReportConfig) enabled() bool {
+	return cfg.Dir != "" || cfg.Webhook.URL != "" || cfg.OCI.Repository != ""
+}
+
+// newReportExporter returns the exporter of the reports of the images
+// scanned on node, or nil if no sink is enabled.
+func newReportExporter(cfg *ReportConfig, node string) *reportExporter {
+	if !cfg.enabled() {
+		return nil
+	}
+
+	timeout := time.Duration(cfg.Webhook.Timeout)
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+
+	return &reportExporter{
+		cfg:    *cfg,
+		node:   node,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// export sends the report of img to every sink. A nil exporter exports
+// nothing.
+func (e *reportExporter) export(img unversioned.Image, report *trivyTypes.Report, status ScanStatus) error {
+	if e == nil {
+		return nil
+	}
+
+	b, err := encodeReport(e.cfg.Format, imageReport{
+		Node:    e.node,
+		ImageID: img.ImageID,
+		Digests: img.Digests,
+		Names:   img.Names,
+		Status:  reportStatuses[status],
+		Report:  report,
+	})
+	if err != nil {
+		return err
+	}
+
+	// one failing sink does not keep the report from the others
+	var errs []string
+	if e.cfg.Dir != "" {
+		if err := e.writeFile(img, b); err != nil {
+			errs = append(errs, fmt.Sprintf("dir: %s", err))
+		}
+	}
+	if e.cfg.Webhook.URL != "" {
+		if err := e.post(img, status, b); err != nil {
+			errs = append(errs, fmt.Sprintf("webhook: %s", err))
+		}
+	}
+	if e.cfg.OCI.Repository != "" {
+		if err := e.push(img, status, b); err != nil {
+			errs = append(errs, fmt.Sprintf("oci: %s", err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to export report: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// encodeReport renders r in format. Only the JSON format holds the node and
+// status of the image, the others are keyed by the sinks.
+func encodeReport(format string, r imageReport) ([]byte, error) {
+	var b bytes.Buffer
+	var err error
+	switch format {
+	case reportFormatSARIF:
+		err = trivyReport.SarifWriter{Output: &b, Version: trivyVersion}.Write(*r.Report)
+	case reportFormatCycloneDX:
+		err = cyclonedx.NewWriter(&b, trivyVersion).Write(*r.Report)
+	default:
+		err = json.NewEncoder(&b).Encode(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// reportKey returns the digest identifying img, or its ID if it has none.
+func reportKey(img unversioned.Image) string {
+	if len(img.Digests) > 0 {
+		if _, digest, ok := strings.Cut(img.Digests[0], "@"); ok {
+			return digest
+		}
+		return img.Digests[0]
+	}
+	return img.ImageID
+}
+
+// reportPath returns the file the report of img is written to: a directory
+// per node, and a file per image named after its digest.
+func (e *reportExporter) reportPath(img unversioned.Image) string {
+	key := strings.ReplaceAll(reportKey(img), ":", "-")
+	return filepath.Join(e.cfg.Dir, e.node, filepath.Base(key)+reportExtensions[e.cfg.Format])
+}
+
+func (e *reportExporter) writeFile(img unversioned.Image, b []byte) error {
+	p := e.reportPath(img)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never see a partial report
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// post sends the report to the webhook, with the image and node in headers.
+func (e *reportExporter) post(img unversioned.Image, status ScanStatus, b []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, e.cfg.Webhook.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", reportMediaTypes[e.cfg.Format])
+	req.Header.Set("X-Eraser-Node", e.node)
+	req.Header.Set("X-Eraser-Image-Digest", reportKey(img))
+	req.Header.Set("X-Eraser-Image-ID", img.ImageID)
+	req.Header.Set("X-Eraser-Status", string(reportStatuses[status]))
+
+	if e.cfg.Webhook.TokenFile != "" {
+		token, err := os.ReadFile(e.cfg.Webhook.TokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// reportRef returns the reference the report of img is pushed to: a
+// repository per node, and a tag per image named after its digest.
+func (e *reportExporter) reportRef(img unversioned.Image) (name.Reference, error) {
+	repo := e.cfg.OCI.Repository
+	if e.node != "" {
+		repo += "/" + e.node
+	}
+	tag := strings.ReplaceAll(reportKey(img), ":", "-") + "." + e.cfg.Format
+
+	var opts []name.Option
+	if e.cfg.OCI.Insecure {
+		opts = append(opts, name.Insecure)
+	}
+	return name.ParseReference(repo+":"+tag, opts...)
+}
+
+// push pushes the report as an OCI artifact with a single layer.
+func (e *reportExporter) push(img unversioned.Image, status ScanStatus, b []byte) error {
+	ref, err := e.reportRef(img)
+	if err != nil {
+		return err
+	}
+
+	layer := static.NewLayer(b, types.MediaType(reportMediaTypes[e.cfg.Format]))
+	artifact, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: layer,
+		Annotations: map[string]string{
+			"org.opencontainers.image.title": filepath.Base(e.reportPath(img)),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	artifact = mutate.MediaType(artifact, types.OCIManifestSchema1)
+	artifact = mutate.ConfigMediaType(artifact, reportConfigMediaType)
+	artifact = mutate.Annotations(artifact, map[string]string{
+		annotationNode:    e.node,
+		annotationImageID: img.ImageID,
+		annotationStatus:  string(reportStatuses[status]),
+	}).(v1.Image)
+
+	return remote.Write(ref, artifact, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/report/cyclonedx"
+	"github.com/aquasecurity/trivy/pkg/report/spdx"
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+const (
+	sbomFormatCycloneDX = "cyclonedx"
+	sbomFormatSPDX      = "spdx"
+	sbomFormatSPDXJSON  = "spdx-json"
+)
+
+var sbomExtensions = map[string]string{
+	sbomFormatCycloneDX: ".cdx.json",
+	sbomFormatSPDX:      ".spdx",
+	sbomFormatSPDXJSON:  ".spdx.json",
+}
+
+func validateSBOM(cfg *SBOMConfig) error {
+	if cfg.Dir == "" {
+		return nil
+	}
+	if _, ok := sbomExtensions[cfg.Format]; !ok {
+		return fmt.Errorf("invalid SBOM format %q, must be %q, %q or %q", cfg.Format, sbomFormatCycloneDX, sbomFormatSPDX, sbomFormatSPDXJSON)
+	}
+	return nil
+}
+
+// sbomPath returns the file the SBOM of img is written to, named after the
+// image ID.
+func sbomPath(cfg *SBOMConfig, img unversioned.Image) string {
+	id := strings.ReplaceAll(img.ImageID, ":", "-")
+	return filepath.Join(cfg.Dir, filepath.Base(id)+sbomExtensions[cfg.Format])
+}
+
+// writeSBOM exports the packages of the report as the SBOM of img, if SBOMs
+// are enabled.
+func writeSBOM(cfg *SBOMConfig, img unversioned.Image, report *trivyTypes.Report) error {
+	if cfg.Dir == "" {
+		return nil
+	}
+
+	var b bytes.Buffer
+	var err error
+	switch cfg.Format {
+	case sbomFormatCycloneDX:
+		err = cyclonedx.NewWriter(&b, trivyVersion).Write(*report)
+	default:
+		err = spdx.NewWriter(&b, trivyVersion, cfg.Format).Write(*report)
+	}
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never see a partial SBOM
+	p := sbomPath(cfg, img)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+)
+
+// vulnPolicy decides which vulnerabilities count towards making an image
+// non-compliant: those of a configured severity, or over any threshold.
+type vulnPolicy struct {
+	cvssScore float64
+	epssScore float64
+	minCount  int
+
+	// kev holds the IDs of the known exploited vulnerabilities, if they
+	// count.
+	kev  map[string]struct{}
+	epss map[string]float64
+}
+
+func validateThresholds(cfg *VulnConfig) error {
+	t := &cfg.Thresholds
+	if t.CVSSScore < 0 || t.CVSSScore > 10 {
+		return fmt.Errorf("invalid CVSS score threshold %g, must be between 0 and 10", t.CVSSScore)
+	}
+	if t.EPSSScore < 0 || t.EPSSScore > 1 {
+		return fmt.Errorf("invalid EPSS score threshold %g, must be between 0 and 1", t.EPSSScore)
+	}
+	if t.KnownExploited && t.KEVFile == "" {
+		return fmt.Errorf("known exploited vulnerabilities need the kevFile of the catalog")
+	}
+	if t.EPSSScore > 0 && t.EPSSFile == "" {
+		return fmt.Errorf("an EPSS score threshold needs the epssFile of the scores")
+	}
+	if cfg.MinCount < 0 {
+		return fmt.Errorf("invalid minimum count %d of vulnerabilities, must not be negative", cfg.MinCount)
+	}
+	return nil
+}
+
+// newVulnPolicy loads the vulnerability feeds the thresholds need.
+func newVulnPolicy(cfg *VulnConfig) (*vulnPolicy, error) {
+	p := &vulnPolicy{
+		cvssScore: cfg.Thresholds.CVSSScore,
+		epssScore: cfg.Thresholds.EPSSScore,
+		minCount:  cfg.MinCount,
+	}
+	if p.minCount < 1 {
+		p.minCount = 1
+	}
+
+	if cfg.Thresholds.KnownExploited {
+		kev, err := loadKEV(cfg.Thresholds.KEVFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load known exploited vulnerabilities: %w", err)
+		}
+		log.Info("loaded known exploited vulnerabilities", "file", cfg.Thresholds.KEVFile, "vulnerabilities", len(kev))
+		p.kev = kev
+	}
+
+	if p.epssScore > 0 {
+		epss, err := loadEPSS(cfg.Thresholds.EPSSFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load EPSS scores: %w", err)
+		}
+		log.Info("loaded EPSS scores", "file", cfg.Thresholds.EPSSFile, "vulnerabilities", len(epss))
+		p.epss = epss
+	}
+
+	return p, nil
+}
+
+// qualifies returns why vuln counts towards making an image non-compliant,
+// if it does.
+func (p *vulnPolicy) qualifies(vuln *trivyTypes.DetectedVulnerability) (string, bool) {
+	if severityMap[vuln.Severity] {
+		return "severity " + vuln.Severity, true
+	}
+
+	if p.cvssScore > 0 {
+		if score := cvssScore(vuln); score >= p.cvssScore {
+			return fmt.Sprintf("CVSS score %.1f", score), true
+		}
+	}
+
+	id := strings.ToUpper(vuln.VulnerabilityID)
+	if _, ok := p.kev[id]; ok {
+		return "known exploited", true
+	}
+
+	if p.epssScore > 0 {
+		if score, ok := p.epss[id]; ok && score >= p.epssScore {
+			return fmt.Sprintf("EPSS score %.3f", score), true
+		}
+	}
+
+	return "", false
+}
+
+// cvssScore returns the highest CVSS v3 base score given to vuln by any
+// source, or the highest v2 score if none has a v3 score.
+func cvssScore(vuln *trivyTypes.DetectedVulnerability) float64 {
+	var v2, v3 float64
+	for _, cvss := range vuln.CVSS {
+		if cvss.V3Score > v3 {
+			v3 = cvss.V3Score
+		}
+		if cvss.V2Score > v2 {
+			v2 = cvss.V2Score
+		}
+	}
+	if v3 > 0 {
+		return v3
+	}
+	return v2
+}
+
+// loadKEV reads the IDs in the CISA catalog of known exploited
+// vulnerabilities, in its JSON format.
+func loadKEV(path string) (map[string]struct{}, error) {
+	data, err := readFeed(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog struct {
+		Vulnerabilities []struct {
+			CveID string `json:"cveID"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("invalid catalog %s: %w", path, err)
+	}
+
+	kev := make(map[string]struct{}, len(catalog.Vulnerabilities))
+	for _, v := range catalog.Vulnerabilities {
+		kev[strings.ToUpper(v.CveID)] = struct{}{}
+	}
+	return kev, nil
+}
+
+// loadEPSS reads the EPSS scores published by FIRST, a CSV file with a cve
+// and an epss column.
+func loadEPSS(path string) (map[string]float64, error) {
+	data, err := readFeed(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid EPSS scores %s: %w", path, err)
+	}
+	cveCol, epssCol := -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(name) {
+		case "cve":
+			cveCol = i
+		case "epss":
+			epssCol = i
+		}
+	}
+	if cveCol < 0 || epssCol < 0 {
+		return nil, fmt.Errorf("invalid EPSS scores %s: no cve and epss columns", path)
+	}
+
+	epss := make(map[string]float64)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid EPSS scores %s: %w", path, err)
+		}
+		if len(record) <= cveCol || len(record) <= epssCol {
+			continue
+		}
+
+		score, err := strconv.ParseFloat(record[epssCol], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EPSS score of %s: %w", record[cveCol], err)
+		}
+		epss[strings.ToUpper(record[cveCol])] = score
+	}
+	return epss, nil
+}
+
+// readFeed reads a vulnerability feed, which may be gzipped as published.
+func readFeed(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// feedDigest identifies the contents of the feeds the thresholds use, for
+// the result cache to notice when they are updated.
+func feedDigest(cfg *ThresholdConfig) (string, error) {
+	var paths []string
+	if cfg.KnownExploited {
+		paths = append(paths, cfg.KEVFile)
+	}
+	if cfg.EPSSScore > 0 {
+		paths = append(paths, cfg.EPSSFile)
+	}
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	h := sha256.New()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -2,11 +2,11 @@ package main
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/Azure/eraser/api/unversioned"
@@ -19,12 +19,17 @@ import (
 	"github.com/Azure/eraser/pkg/utils"
 	fanalImage "github.com/aquasecurity/trivy/pkg/fanal/image"
 	trivylogger "github.com/aquasecurity/trivy/pkg/log"
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+	"golang.org/x/exp/slices"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
 	generalErr = 1
 
+	// scanMemory is the memory a single image scan is expected to need.
+	scanMemory = 512 * 1024 * 1024
+
 	severityCritical = "CRITICAL"
 	severityHigh     = "HIGH"
 	severityMedium   = "MEDIUM"
@@ -40,9 +45,15 @@ const (
 )
 
 var (
-	config        = flag.String("config", "", "path to the configuration file")
-	enableProfile = flag.Bool("enable-pprof", false, "enable pprof profiling")
-	profilePort   = flag.Int("pprof-port", 6060, "port for pprof profiling. defaulted to 6060 if unspecified")
+	config         = flag.String("config", "", "path to the configuration file")
+	enableProfile  = flag.Bool("enable-pprof", false, "enable pprof profiling")
+	profilePort    = flag.Int("pprof-port", 6060, "port for pprof profiling. defaulted to 6060 if unspecified")
+	clusterScan    = flag.String("cluster-scan", "", "path to the images to scan from their registries for the whole cluster")
+	scanReport     = flag.String("scan-report", "", "name of the collector ImageJob the cluster scan belongs to")
+	pullSecrets    = flag.String("pull-secrets", "", "directory holding the image pull secrets used for registry authentication")
+	resultCacheDir = flag.String("result-cache-dir", "", "directory of the node-local cache of scan results. the cache is disabled if empty")
+	fetchDBDir     = flag.String("fetch-db", "", "directory to fetch the trivy DB into for the scanners to share, then exit")
+	sharedDBDir    = flag.String("shared-db-dir", "", "directory holding the trivy DB fetched by the manager, used instead of fetching it")
 
 	// Will be modified by parseCommaSeparatedOptions() to reflect the
 	// `severity` CLI flag These are the only recognized severities and the
@@ -129,10 +140,27 @@ func main() {
 		os.Exit(generalErr)
 	}
 
+	if *fetchDBDir != "" {
+		if err := fetchDB(&userConfig, *fetchDBDir); err != nil {
+			log.Error(err, "unable to fetch trivy DB")
+			os.Exit(generalErr)
+		}
+		log.Info("fetched trivy DB", "dir", *fetchDBDir)
+		return
+	}
+
 	if *enableProfile {
 		go runProfileServer()
 	}
 
+	if *clusterScan != "" {
+		if err := runClusterScan(&userConfig); err != nil {
+			log.Error(err, "cluster scan failed")
+			os.Exit(generalErr)
+		}
+		return
+	}
+
 	recordMetrics := false
 	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
 		recordMetrics = true
@@ -155,13 +183,26 @@ func main() {
 	s, err := initScanner(&userConfig)
 	if err != nil {
 		log.Error(err, "error initializing scanner")
+		if err := template.Abort(provider, err); err != nil {
+			log.Error(err, "unable to abort image removal")
+		}
+		os.Exit(generalErr)
 	}
 
-	vulnerableImages, failedImages, err := scan(s, allImages)
+	cache, err := initResultCache(&userConfig)
+	if err != nil {
+		log.Error(err, "unable to load scan result cache, scanning all images")
+	}
+
+	vulnerableImages, failedImages, err := scan(s, cache, allImages, userConfig.Parallelism)
 	if err != nil {
 		log.Error(err, "total image scan timed out")
 	}
 
+	if err := cache.save(allImages); err != nil {
+		log.Error(err, "unable to save scan result cache")
+	}
+
 	log.Info("Vulnerable", "Images", vulnerableImages)
 
 	if len(failedImages) > 0 {
@@ -226,12 +267,48 @@ func runProfileServer() {
 }
 
 func initScanner(userConfig *Config) (Scanner, error) {
+	runtime := os.Getenv(utils.EnvEraserContainerRuntime)
+	imageSourceOptions, ok := runtimeFanalOptionsMap[runtime]
+	if !ok {
+		return nil, fmt.Errorf("unable to determine runtime from environment: %q", runtime)
+	}
+
+	return newImageScanner(userConfig, imageSourceOptions)
+}
+
+// newImageScanner initializes the trivy database and returns a scanner that
+// looks for images in the given sources.
+func newImageScanner(userConfig *Config, imageSourceOptions []fanalImage.Option) (*ImageScanner, error) {
 	if userConfig == nil {
 		return nil, fmt.Errorf("invalid trivy scanner config")
 	}
 
+	if err := validatePolicy(&userConfig.Policy); err != nil {
+		return nil, err
+	}
+	if err := validateSBOM(&userConfig.SBOM); err != nil {
+		return nil, err
+	}
+	if err := validateReports(&userConfig.Reports); err != nil {
+		return nil, err
+	}
+	if err := validateIgnores(userConfig.Vulnerabilities.Ignore); err != nil {
+		return nil, err
+	}
+	if err := validateThresholds(&userConfig.Vulnerabilities); err != nil {
+		return nil, err
+	}
+	if userConfig.Parallelism < 0 {
+		return nil, fmt.Errorf("invalid parallelism %d, must not be negative", userConfig.Parallelism)
+	}
+
+	vulns, err := newVulnPolicy(&userConfig.Vulnerabilities)
+	if err != nil {
+		return nil, err
+	}
+
 	cacheDir := userConfig.CacheDir
-	err := downloadAndInitDB(userConfig)
+	err = downloadAndInitDB(userConfig)
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize trivy db. cacheDir: %s, error: %w", cacheDir, err)
 	}
@@ -246,58 +323,185 @@ func initScanner(userConfig *Config) (Scanner, error) {
 
 	vulnTypeList := trueMapKeys(vulnTypeMap)
 	securityCheckList := trueMapKeys(securityCheckMap)
+	for _, check := range userConfig.Policy.securityChecks() {
+		if !slices.Contains(securityCheckList, check) {
+			securityCheckList = append(securityCheckList, check)
+		}
+	}
 
 	scanConfig, err := setupScanner(cacheDir, vulnTypeList, securityCheckList)
 	if err != nil {
 		return nil, err
 	}
-
-	runtime := os.Getenv(utils.EnvEraserContainerRuntime)
-	imageSourceOptions, ok := runtimeFanalOptionsMap[runtime]
-	if !ok {
-		return nil, fmt.Errorf("unable to determine runtime from environment: %w", err)
-	}
+	// the policy, SBOMs and CycloneDX reports need every package, not only
+	// the vulnerable ones
+	scanConfig.scanOptions.ListAllPackages = userConfig.Policy.needsPackages() || userConfig.SBOM.Dir != "" ||
+		(userConfig.Reports.enabled() && userConfig.Reports.Format == reportFormatCycloneDX)
 
 	totalTimeout := time.Duration(userConfig.Timeout.Total)
 	timer := time.NewTimer(totalTimeout)
 
-	var s Scanner = &ImageScanner{
+	s := &ImageScanner{
 		trivyScanConfig:    scanConfig,
 		imageSourceOptions: imageSourceOptions,
 		userConfig:         *userConfig,
+		vulns:              vulns,
+		ignores:            activeIgnores(userConfig.Vulnerabilities.Ignore, time.Now()),
+		margins:            newMarginStore(),
+		reports:            newReportExporter(&userConfig.Reports, os.Getenv("NODE_NAME")),
 		timer:              timer,
 	}
 	return s, nil
 }
 
-func scan(s Scanner, allImages []unversioned.Image) ([]unversioned.Image, []unversioned.Image, error) {
-	vulnerableImages := make([]unversioned.Image, 0, len(allImages))
-	failedImages := make([]unversioned.Image, 0, len(allImages))
-	// track total scan job time
+// scanImage scans img with s, along with its report if exporter is set.
+func scanImage(s Scanner, exporter exportScanner, img unversioned.Image) (ScanStatus, *trivyTypes.Report, error) {
+	if exporter == nil {
+		status, err := s.Scan(img)
+		return status, nil, err
+	}
+	return exporter.ScanReport(img)
+}
+
+// initResultCache loads the scan result cache if one is configured.
+func initResultCache(userConfig *Config) (*resultCache, error) {
+	if *resultCacheDir == "" {
+		return nil, nil
+	}
+
+	version, err := dbVersion(userConfig.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := configHash(userConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadResultCache(*resultCacheDir, version, hash)
+}
+
+// scan returns the vulnerable and failed images of allImages, in their order.
+// Images with a result in cache are not scanned again, but their cached
+// report is exported again if s exports reports. The others are scanned
+// by up to workers at once, most likely offenders first. Each scan gets the
+// whole per-image timeout from when it starts. Once the total timeout is
+// exceeded, the scans in progress finish and the images not started yet are
+// deferred to the next run: they are neither vulnerable nor failed.
+func scan(s Scanner, cache *resultCache, allImages []unversioned.Image, workers int) ([]unversioned.Image, []unversioned.Image, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	exporter, _ := s.(exportScanner)
+	if exporter != nil && !exporter.Exports() {
+		exporter = nil
+	}
 
+	statuses := make([]ScanStatus, len(allImages))
+	scanned := make([]bool, len(allImages))
+	reports := make([]string, len(allImages))
+
+	var wg sync.WaitGroup
+	work := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range work {
+				// Logs scan failures
+				status, report, err := scanImage(s, exporter, allImages[idx])
+				if err != nil {
+					log.Error(err, "scan failed")
+					continue
+				}
+				statuses[idx] = status
+				scanned[idx] = true
+
+				if report != nil {
+					if reports[idx], err = cache.putReport(allImages[idx], report); err != nil {
+						log.Error(err, "unable to cache report", "img", allImages[idx])
+					}
+				}
+			}
+		}()
+	}
+
+	var pending []int
 	for idx, img := range allImages {
-		select {
-		case <-s.Timer().C:
-			failedImages = append(failedImages, allImages[idx:]...)
-			return vulnerableImages, failedImages, errors.New("image scan total timeout exceeded")
-		default:
-			// Logs scan failures
-			status, err := s.Scan(img)
+		status, ok := cache.get(img)
+		if !ok {
+			pending = append(pending, idx)
+			continue
+		}
+
+		if exporter != nil {
+			report, err := cache.report(img)
 			if err != nil {
-				failedImages = append(failedImages, img)
-				log.Error(err, "scan failed")
+				log.V(1).Info("unable to export cached scan result, scanning again", "img", img, "error", err.Error())
+				pending = append(pending, idx)
 				continue
 			}
+			exporter.Export(img, report, status)
+		}
 
-			switch status {
-			case StatusNonCompliant:
-				log.Info("vulnerable image found", "img", img)
-				vulnerableImages = append(vulnerableImages, img)
-			case StatusFailed:
-				failedImages = append(failedImages, img)
+		log.V(1).Info("using cached scan result", "img", img)
+		statuses[idx] = status
+	}
+	sortByPriority(allImages, pending, cache)
+
+	timedOut := false
+	var deferred []unversioned.Image
+	for _, idx := range pending {
+		// a worker may be free when the timeout is exceeded, check it first
+		if !timedOut {
+			select {
+			case <-s.Timer().C:
+				timedOut = true
+			default:
 			}
 		}
+		if !timedOut {
+			select {
+			case work <- idx:
+				continue
+			case <-s.Timer().C:
+				timedOut = true
+			}
+		}
+		statuses[idx] = StatusOK
+		deferred = append(deferred, allImages[idx])
 	}
+	close(work)
+	wg.Wait()
+
+	margins, _ := s.(marginScanner)
 
+	vulnerableImages := make([]unversioned.Image, 0, len(allImages))
+	failedImages := make([]unversioned.Image, 0, len(allImages))
+	for idx, img := range allImages {
+		if scanned[idx] {
+			cache.put(img, statuses[idx], reports[idx])
+			if margins != nil {
+				if margin, ok := margins.Margin(img.ImageID); ok {
+					cache.putMargin(img, margin)
+				}
+			}
+		}
+
+		switch statuses[idx] {
+		case StatusNonCompliant:
+			log.Info("vulnerable image found", "img", img)
+			vulnerableImages = append(vulnerableImages, img)
+		case StatusFailed:
+			failedImages = append(failedImages, img)
+		}
+	}
+
+	if timedOut {
+		log.Info("deferred images to the next run", "images", deferred)
+		return vulnerableImages, failedImages, fmt.Errorf("image scan total timeout exceeded, %d images deferred", len(deferred))
+	}
 	return vulnerableImages, failedImages, nil
 }
@@ -1,9 +1,37 @@
 package main
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/aquasecurity/trivy-db/pkg/db"
+	"github.com/aquasecurity/trivy-db/pkg/metadata"
+	dbTypes "github.com/aquasecurity/trivy-db/pkg/types"
+	fanalTypes "github.com/aquasecurity/trivy/pkg/fanal/types"
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/Azure/eraser/api/unversioned"
+	eraserv1alpha1 "github.com/Azure/eraser/api/v1alpha1"
 )
 
 func TestParseCommaSeparatedOptions(t *testing.T) {
@@ -94,6 +122,74 @@ func TestDownloadAndInitDB(t *testing.T) {
 	}
 }
 
+func TestDBBundle(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "db.tar.gz")
+
+	meta, err := json.Marshal(metadata.Metadata{Version: db.SchemaVersion, UpdatedAt: time.Now().Add(-48 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.New()
+	zw := gzip.NewWriter(io.MultiWriter(f, h))
+	tw := tar.NewWriter(zw)
+	files := map[string][]byte{
+		"trivy.db":      []byte("db"),
+		"metadata.json": meta,
+		"../escape":     []byte("ignored"),
+	}
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []io.Closer{tw, zw, f} {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	digest := "sha256:" + hex.EncodeToString(h.Sum(nil))
+
+	cfg := DefaultConfig()
+	cfg.DB = DBConfig{Offline: true, Bundle: bundle, BundleDigest: digest}
+	shared := filepath.Join(dir, "shared")
+	if err := fetchDB(cfg, shared); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape")); err == nil {
+		t.Error("expected only the DB files to be extracted")
+	}
+
+	cache := filepath.Join(dir, "cache")
+	if err := importDB(shared, cache, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateDB(cache, 0); err != nil {
+		t.Error(err)
+	}
+	if err := validateDB(cache, eraserv1alpha1.Duration(24*time.Hour)); err == nil {
+		t.Error("expected a DB older than the maximum age to be invalid")
+	}
+
+	cfg.DB.BundleDigest = "sha256:" + strings.Repeat("0", 64)
+	if err := fetchDB(cfg, filepath.Join(dir, "other")); err == nil {
+		t.Error("expected a bundle with another digest to be rejected")
+	}
+
+	cfg.DB = DBConfig{Offline: true}
+	if err := fetchDB(cfg, filepath.Join(dir, "empty")); err == nil {
+		t.Error("expected no DB to be fetched offline without a bundle")
+	}
+}
+
 func TestSetupScanner(t *testing.T) {
 	tmp, err := os.MkdirTemp(os.TempDir(), "eraser-trivy-scanner-test")
 	defer os.RemoveAll(tmp)
@@ -134,3 +230,725 @@ func TestSetupScanner(t *testing.T) {
 		}
 	}
 }
+
+type fakeScanner struct {
+	statuses map[string]ScanStatus
+	scanned  []string
+	timer    *time.Timer
+
+	mu      sync.Mutex
+	delay   time.Duration
+	running int
+	peak    int
+}
+
+func (f *fakeScanner) Scan(img unversioned.Image) (ScanStatus, error) {
+	f.mu.Lock()
+	f.scanned = append(f.scanned, img.ImageID)
+	f.running++
+	if f.running > f.peak {
+		f.peak = f.running
+	}
+	f.mu.Unlock()
+
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	f.running--
+	f.mu.Unlock()
+	return f.statuses[img.ImageID], nil
+}
+
+func (f *fakeScanner) Timer() *time.Timer {
+	return f.timer
+}
+
+func TestScanResultCache(t *testing.T) {
+	tmp := t.TempDir()
+
+	images := []unversioned.Image{
+		{ImageID: "a", Digests: []string{"sha256:aaa"}},
+		{ImageID: "b", Digests: []string{"sha256:bbb"}},
+		{ImageID: "c", Digests: []string{"sha256:ccc"}},
+	}
+	s := &fakeScanner{
+		statuses: map[string]ScanStatus{"a": StatusOK, "b": StatusNonCompliant, "c": StatusFailed},
+		timer:    time.NewTimer(time.Hour),
+	}
+
+	cache, err := loadResultCache(tmp, "2-db1", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := scan(s, cache, images, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.save(images[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed image is scanned again, the others come from the cache
+	s.scanned = nil
+	cache, err = loadResultCache(tmp, "2-db1", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vulnerable, failed, err := scan(s, cache, images, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.scanned) != 1 || s.scanned[0] != "c" {
+		t.Errorf("expected only c to be scanned, got %v", s.scanned)
+	}
+	if len(vulnerable) != 1 || vulnerable[0].ImageID != "b" {
+		t.Errorf("expected b to be vulnerable, got %v", vulnerable)
+	}
+	if len(failed) != 1 || failed[0].ImageID != "c" {
+		t.Errorf("expected c to fail, got %v", failed)
+	}
+
+	// a new DB invalidates every result
+	s.scanned = nil
+	cache, err = loadResultCache(tmp, "2-db2", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := scan(s, cache, images, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.scanned) != len(images) {
+		t.Errorf("expected all images to be scanned after a DB update, got %v", s.scanned)
+	}
+
+	// so does a different scanner config
+	s.scanned = nil
+	cache, err = loadResultCache(tmp, "2-db1", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := scan(s, cache, images, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.scanned) != len(images) {
+		t.Errorf("expected all images to be scanned after a config change, got %v", s.scanned)
+	}
+}
+
+// fakeExportScanner exports a report naming each image it scans.
+type fakeExportScanner struct {
+	fakeScanner
+	exported map[string]ScanStatus
+}
+
+func (f *fakeExportScanner) Exports() bool {
+	return true
+}
+
+func (f *fakeExportScanner) ScanReport(img unversioned.Image) (ScanStatus, *trivyTypes.Report, error) {
+	status, err := f.Scan(img)
+	if status == StatusFailed {
+		return status, nil, err
+	}
+	report := &trivyTypes.Report{ArtifactName: img.ImageID}
+	f.Export(img, report, status)
+	return status, report, err
+}
+
+func (f *fakeExportScanner) Export(img unversioned.Image, report *trivyTypes.Report, status ScanStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exported[report.ArtifactName] = status
+}
+
+func TestScanResultCacheExports(t *testing.T) {
+	tmp := t.TempDir()
+
+	images := []unversioned.Image{
+		{ImageID: "a", Digests: []string{"docker.io/library/a@sha256:aaa"}},
+		{ImageID: "b", Digests: []string{"sha256:bbb"}},
+		{ImageID: "c"},
+	}
+	s := &fakeExportScanner{
+		fakeScanner: fakeScanner{
+			statuses: map[string]ScanStatus{"a": StatusOK, "b": StatusNonCompliant, "c": StatusNonCompliant},
+			timer:    time.NewTimer(time.Hour),
+		},
+	}
+
+	// results cached before exports were enabled have no report
+	cache, err := loadResultCache(tmp, "2-db1", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.put(images[2], StatusNonCompliant, "")
+	if err := cache.save(images); err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 2; run++ {
+		s.scanned = nil
+		s.exported = make(map[string]ScanStatus)
+
+		cache, err := loadResultCache(tmp, "2-db1", "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := scan(s, cache, images, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.save(images[:2]); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]ScanStatus{"a": StatusOK, "b": StatusNonCompliant, "c": StatusNonCompliant}
+		if !reflect.DeepEqual(s.exported, expected) {
+			t.Errorf("run %d: unexpected exports: expected: %v, got: %v", run, expected, s.exported)
+		}
+		// the second run only scans c, no longer cached
+		if run == 1 && !reflect.DeepEqual(s.scanned, []string{"c"}) {
+			t.Errorf("run %d: expected only c to be scanned, got %v", run, s.scanned)
+		}
+	}
+
+	files, err := os.ReadDir(filepath.Join(tmp, resultReportsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected the reports of a and b to be kept, got %d files", len(files))
+	}
+}
+
+func TestScanParallel(t *testing.T) {
+	var images []unversioned.Image
+	statuses := make(map[string]ScanStatus)
+	for i := 0; i < 20; i++ {
+		id := strconv.Itoa(i)
+		images = append(images, unversioned.Image{ImageID: id})
+		statuses[id] = StatusOK
+		if i%3 == 0 {
+			statuses[id] = StatusNonCompliant
+		}
+	}
+
+	s := &fakeScanner{statuses: statuses, timer: time.NewTimer(time.Hour), delay: 10 * time.Millisecond}
+	vulnerable, failed, err := scan(s, nil, images, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.peak < 2 || s.peak > 4 {
+		t.Errorf("expected between 2 and 4 concurrent scans, got %d", s.peak)
+	}
+	if len(failed) != 0 {
+		t.Errorf("expected no failed images, got %v", failed)
+	}
+
+	var ids []string
+	for _, img := range vulnerable {
+		ids = append(ids, img.ImageID)
+	}
+	if expected := "0,3,6,9,12,15,18"; strings.Join(ids, ",") != expected {
+		t.Errorf("expected vulnerable images %s in order, got %v", expected, ids)
+	}
+
+	// the images not started when the total timeout is exceeded are deferred
+	s = &fakeScanner{statuses: statuses, timer: time.NewTimer(0)}
+	time.Sleep(time.Millisecond)
+	vulnerable, failed, err = scan(s, nil, images, 4)
+	if err == nil {
+		t.Error("expected the total timeout to be reported")
+	}
+	if len(s.scanned) != 0 {
+		t.Errorf("expected no image to be scanned after the timeout, got %v", s.scanned)
+	}
+	if len(vulnerable) != 0 || len(failed) != 0 {
+		t.Errorf("expected deferred images to be neither vulnerable nor failed, got %v and %v", vulnerable, failed)
+	}
+}
+
+func TestScanPriority(t *testing.T) {
+	images := []unversioned.Image{
+		{ImageID: "compliant", Size: 1000},
+		{ImageID: "small", Size: 10},
+		{ImageID: "large", Size: 100},
+		{ImageID: "cached"},
+		{ImageID: "noncompliant"},
+		{ImageID: "close", Size: 1},
+		{ImageID: "closer"},
+	}
+
+	cache, err := loadResultCache(t.TempDir(), "2-db1", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.put(images[3], StatusOK, "")
+	cache.putMargin(images[0], scanMargin{Count: 1})
+	cache.putMargin(images[4], scanMargin{CVSS: 9.8})
+	cache.putMargin(images[5], scanMargin{Count: 1, CVSS: 5})
+	cache.putMargin(images[6], scanMargin{Count: 1, CVSS: 7.5})
+
+	s := &fakeScanner{timer: time.NewTimer(time.Hour)}
+	if _, _, err := scan(s, cache, images, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "large,small,noncompliant,closer,close,compliant"; strings.Join(s.scanned, ",") != expected {
+		t.Errorf("expected images to be scanned in order %s, got %v", expected, s.scanned)
+	}
+}
+
+func TestDefaultParallelism(t *testing.T) {
+	cases := []struct {
+		cpu      string
+		mem      string
+		expected int
+	}{
+		{cpu: "1000m", mem: "2Gi", expected: 1},
+		{cpu: "4", mem: "4Gi", expected: 4},
+		{cpu: "4", mem: "1Gi", expected: 2},
+		{cpu: "8", mem: "0", expected: 8},
+		{cpu: "100m", mem: "256Mi", expected: 1},
+	}
+	for _, tc := range cases {
+		request := eraserv1alpha1.ResourceRequirements{CPU: resource.MustParse(tc.cpu)}
+		limit := eraserv1alpha1.ResourceRequirements{Mem: resource.MustParse(tc.mem)}
+		if n := defaultParallelism(&request, &limit); n != tc.expected {
+			t.Errorf("cpu %s, mem %s: expected %d, got %d", tc.cpu, tc.mem, tc.expected, n)
+		}
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	a := DefaultConfig()
+	b := DefaultConfig()
+	b.Vulnerabilities.Types = []string{b.Vulnerabilities.Types[1], b.Vulnerabilities.Types[0]}
+
+	hashA, err := configHash(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashB, err := configHash(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashA != hashB {
+		t.Error("expected the order of vulnerability types not to change the hash")
+	}
+
+	b.Vulnerabilities.Severities = append(b.Vulnerabilities.Severities, severityHigh)
+	hashB, err = configHash(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashA == hashB {
+		t.Error("expected a new severity to change the hash")
+	}
+
+	c := DefaultConfig()
+	c.Policy.Secrets = true
+	hashC, err := configHash(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashA == hashC {
+		t.Error("expected a policy change to change the hash")
+	}
+
+	d := DefaultConfig()
+	d.Vulnerabilities.Thresholds.CVSSScore = 9
+	hashD, err := configHash(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashA == hashD {
+		t.Error("expected a threshold to change the hash")
+	}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	report := &trivyTypes.Report{
+		Results: trivyTypes.Results{
+			{
+				Target: "debian 11",
+				Type:   "debian",
+				Packages: []fanalTypes.Package{
+					{Name: "openssl", Version: "1.1.1n", Release: "0+deb11u3"},
+					{Name: "bash", Version: "5.1", Release: "2+deb11u1", Licenses: []string{"GPL-3.0"}},
+				},
+			},
+			{
+				Target: "app/package-lock.json",
+				Type:   "npm",
+				Packages: []fanalTypes.Package{
+					{Name: "lodash", Version: "4.17.20"},
+				},
+			},
+			{
+				Target:  "/etc/app/credentials",
+				Secrets: []fanalTypes.SecretFinding{{RuleID: "aws-access-key-id"}},
+			},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		policy   PolicyConfig
+		expected bool
+	}{
+		{
+			name:     "empty policy",
+			expected: false,
+		},
+		{
+			name:     "os package below version",
+			policy:   PolicyConfig{Packages: []PackageRule{{Name: "openssl", Below: "1.1.1n-0+deb11u4"}}},
+			expected: true,
+		},
+		{
+			name:     "os package above version",
+			policy:   PolicyConfig{Packages: []PackageRule{{Name: "openssl", Below: "1.1.1n-0+deb11u2"}}},
+			expected: false,
+		},
+		{
+			name:     "language package below version",
+			policy:   PolicyConfig{Packages: []PackageRule{{Name: "lodash", Below: "4.17.21"}}},
+			expected: true,
+		},
+		{
+			name:     "package of another type",
+			policy:   PolicyConfig{Packages: []PackageRule{{Name: "lodash", Type: "pip"}}},
+			expected: false,
+		},
+		{
+			name:     "banned package pattern",
+			policy:   PolicyConfig{Packages: []PackageRule{{Name: "open*"}}},
+			expected: true,
+		},
+		{
+			name:     "banned license",
+			policy:   PolicyConfig{Licenses: []string{"gpl-3.0*"}},
+			expected: true,
+		},
+		{
+			name:     "other license",
+			policy:   PolicyConfig{Licenses: []string{"AGPL-3.0"}},
+			expected: false,
+		},
+		{
+			name:     "secret",
+			policy:   PolicyConfig{Secrets: true},
+			expected: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, ok := evaluatePolicy(&tc.policy, report)
+			if ok != tc.expected {
+				t.Errorf("expected policy match %v, got %v (%s)", tc.expected, ok, reason)
+			}
+		})
+	}
+}
+
+func TestWriteSBOM(t *testing.T) {
+	report := &trivyTypes.Report{
+		ArtifactName: "docker.io/library/alpine:3.17",
+		ArtifactType: fanalTypes.ArtifactContainerImage,
+		Results: trivyTypes.Results{
+			{
+				Target:   "alpine 3.17",
+				Class:    trivyTypes.ClassOSPkg,
+				Type:     "alpine",
+				Packages: []fanalTypes.Package{{Name: "musl", Version: "1.2.3-r4"}},
+			},
+		},
+	}
+	img := unversioned.Image{ImageID: "sha256:abc"}
+
+	for _, format := range []string{sbomFormatCycloneDX, sbomFormatSPDX, sbomFormatSPDXJSON} {
+		t.Run(format, func(t *testing.T) {
+			cfg := &SBOMConfig{Dir: t.TempDir(), Format: format}
+			if err := writeSBOM(cfg, img, report); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(filepath.Join(cfg.Dir, "sha256-abc"+sbomExtensions[format]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(b), "musl") {
+				t.Errorf("expected the SBOM to list the packages, got %s", b)
+			}
+		})
+	}
+}
+
+func TestExportReport(t *testing.T) {
+	report := &trivyTypes.Report{
+		ArtifactName: "docker.io/library/alpine:3.17",
+		ArtifactType: fanalTypes.ArtifactContainerImage,
+		Results: trivyTypes.Results{
+			{
+				Target: "alpine 3.17",
+				Class:  trivyTypes.ClassOSPkg,
+				Type:   "alpine",
+				Vulnerabilities: []trivyTypes.DetectedVulnerability{
+					{VulnerabilityID: "CVE-2023-0001", PkgName: "musl", InstalledVersion: "1.2.3-r4", FixedVersion: "1.2.3-r5"},
+				},
+			},
+		},
+	}
+	img := unversioned.Image{
+		ImageID: "sha256:abc",
+		Digests: []string{"docker.io/library/alpine@sha256:def"},
+		Names:   []string{"docker.io/library/alpine:3.17"},
+	}
+
+	for _, format := range []string{reportFormatJSON, reportFormatSARIF, reportFormatCycloneDX} {
+		t.Run(format, func(t *testing.T) {
+			var posted []byte
+			var headers http.Header
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				posted, _ = io.ReadAll(r.Body)
+				headers = r.Header
+			}))
+			defer webhook.Close()
+
+			reg := httptest.NewServer(registry.New(registry.Logger(stdlog.New(io.Discard, "", 0))))
+			defer reg.Close()
+			repo := strings.TrimPrefix(reg.URL, "http://") + "/reports"
+
+			cfg := &ReportConfig{
+				Format:  format,
+				Dir:     t.TempDir(),
+				Webhook: WebhookConfig{URL: webhook.URL},
+				OCI:     OCIReportConfig{Repository: repo, Insecure: true},
+			}
+			if err := validateReports(cfg); err != nil {
+				t.Fatal(err)
+			}
+			e := newReportExporter(cfg, "node-a")
+			if err := e.export(img, report, StatusNonCompliant); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(filepath.Join(cfg.Dir, "node-a", "sha256-def"+reportExtensions[format]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(b), "CVE-2023-0001") {
+				t.Errorf("expected the report to list the vulnerabilities, got %s", b)
+			}
+
+			if string(posted) != string(b) {
+				t.Errorf("expected the webhook to receive the report, got %s", posted)
+			}
+			if headers.Get("X-Eraser-Node") != "node-a" || headers.Get("X-Eraser-Image-Digest") != "sha256:def" {
+				t.Errorf("expected the webhook to receive the node and digest, got %v", headers)
+			}
+
+			ref, err := name.ParseReference(repo+"/node-a:sha256-def."+format, name.Insecure)
+			if err != nil {
+				t.Fatal(err)
+			}
+			artifact, err := remote.Image(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			layers, err := artifact.Layers()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := layers[0].Uncompressed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			pushed, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(pushed) != string(b) {
+				t.Errorf("expected the artifact to hold the report, got %s", pushed)
+			}
+		})
+	}
+
+	var r imageReport
+	b, err := encodeReport(reportFormatJSON, imageReport{Node: "node-a", ImageID: img.ImageID, Status: reportStatuses[StatusOK], Report: report})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Node != "node-a" || r.Status != "Compliant" {
+		t.Errorf("expected the JSON report to hold the node and status, got %+v", r)
+	}
+
+	if err := validateReports(&ReportConfig{Format: "html", Dir: "/reports"}); err == nil {
+		t.Error("expected an invalid format to be rejected")
+	}
+	if newReportExporter(&ReportConfig{Format: reportFormatJSON}, "node-a") != nil {
+		t.Error("expected no exporter without a sink")
+	}
+}
+
+func TestIgnoreList(t *testing.T) {
+	var cfg VulnConfig
+	err := yaml.Unmarshal([]byte(`
+ignore:
+  - id: CVE-2023-0001
+    expires: 2023-06-01
+    reason: not reachable from the entrypoint
+  - id: CVE-2023-0002
+    package: openssl
+    reason: mitigated by network policy
+  - package: zlib
+    expires: 2023-01-01
+    reason: expired waiver
+`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateIgnores(cfg.Ignore); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	ignores := activeIgnores(cfg.Ignore, now)
+
+	cases := []struct {
+		id       string
+		pkg      string
+		expected bool
+	}{
+		{id: "CVE-2023-0001", pkg: "curl", expected: true},
+		{id: "cve-2023-0001", pkg: "bash", expected: true},
+		{id: "CVE-2023-0002", pkg: "openssl", expected: true},
+		{id: "CVE-2023-0002", pkg: "curl", expected: false},
+		{id: "CVE-2023-0003", pkg: "zlib", expected: false},
+	}
+	for _, tc := range cases {
+		vuln := &trivyTypes.DetectedVulnerability{VulnerabilityID: tc.id, PkgName: tc.pkg}
+		if _, ok := ignores.match(vuln); ok != tc.expected {
+			t.Errorf("expected %s in %s to be ignored: %v, got %v", tc.id, tc.pkg, tc.expected, ok)
+		}
+	}
+
+	later := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	if _, ok := activeIgnores(cfg.Ignore, later).match(&trivyTypes.DetectedVulnerability{VulnerabilityID: "CVE-2023-0001"}); ok {
+		t.Error("expected the entry to expire on its expiry date")
+	}
+	if len(ignoreKeys(cfg.Ignore, now)) == len(ignoreKeys(cfg.Ignore, later)) {
+		t.Error("expected an expired entry to change the cache keys")
+	}
+
+	if err := validateIgnores([]IgnoreEntry{{ID: "CVE-2023-0001"}}); err == nil {
+		t.Error("expected an entry without a reason to be invalid")
+	}
+	if err := validateIgnores([]IgnoreEntry{{Reason: "everything"}}); err == nil {
+		t.Error("expected an entry without an id or package to be invalid")
+	}
+}
+
+func TestVulnPolicy(t *testing.T) {
+	dir := t.TempDir()
+	kevFile := filepath.Join(dir, "known_exploited_vulnerabilities.json")
+	if err := os.WriteFile(kevFile, []byte(`{"vulnerabilities":[{"cveID":"CVE-2023-0003"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	epssFile := filepath.Join(dir, "epss_scores.csv.gz")
+	f, err := os.Create(epssFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write([]byte("#model_version:v2023.03.01,score_date:2023-03-01T00:00:00+0000\ncve,epss,percentile\nCVE-2023-0004,0.51,0.97\nCVE-2023-0005,0.02,0.50\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := VulnConfig{
+		Thresholds: ThresholdConfig{
+			CVSSScore:      9,
+			KnownExploited: true,
+			KEVFile:        kevFile,
+			EPSSScore:      0.5,
+			EPSSFile:       epssFile,
+		},
+	}
+	if err := validateThresholds(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newVulnPolicy(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.minCount != 1 {
+		t.Errorf("expected a minimum count of 1, got %d", p.minCount)
+	}
+
+	cases := []struct {
+		name     string
+		vuln     trivyTypes.DetectedVulnerability
+		expected bool
+	}{
+		{
+			name: "v3 score over threshold",
+			vuln: trivyTypes.DetectedVulnerability{
+				VulnerabilityID: "CVE-2023-0001",
+				Vulnerability: dbTypes.Vulnerability{CVSS: dbTypes.VendorCVSS{
+					"nvd":    {V3Score: 7.5, V2Score: 10},
+					"redhat": {V3Score: 9.8},
+				}},
+			},
+			expected: true,
+		},
+		{
+			name: "v3 score under threshold",
+			vuln: trivyTypes.DetectedVulnerability{
+				VulnerabilityID: "CVE-2023-0002",
+				Vulnerability:   dbTypes.Vulnerability{CVSS: dbTypes.VendorCVSS{"nvd": {V3Score: 7.5, V2Score: 10}}},
+			},
+			expected: false,
+		},
+		{
+			name:     "known exploited",
+			vuln:     trivyTypes.DetectedVulnerability{VulnerabilityID: "cve-2023-0003"},
+			expected: true,
+		},
+		{
+			name:     "EPSS score over threshold",
+			vuln:     trivyTypes.DetectedVulnerability{VulnerabilityID: "CVE-2023-0004"},
+			expected: true,
+		},
+		{
+			name:     "EPSS score under threshold",
+			vuln:     trivyTypes.DetectedVulnerability{VulnerabilityID: "CVE-2023-0005"},
+			expected: false,
+		},
+	}
+	for _, tc := range cases {
+		if _, ok := p.qualifies(&tc.vuln); ok != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, ok)
+		}
+	}
+
+	if err := validateThresholds(&VulnConfig{Thresholds: ThresholdConfig{CVSSScore: 11}}); err == nil {
+		t.Error("expected a CVSS score over 10 to be invalid")
+	}
+	if err := validateThresholds(&VulnConfig{Thresholds: ThresholdConfig{KnownExploited: true}}); err == nil {
+		t.Error("expected known exploited vulnerabilities without a catalog to be invalid")
+	}
+	if err := validateThresholds(&VulnConfig{Thresholds: ThresholdConfig{EPSSScore: 0.1}}); err == nil {
+		t.Error("expected an EPSS score threshold without scores to be invalid")
+	}
+}
@@ -28,8 +28,30 @@ type (
 		CacheDir           string        `json:"cacheDir,omitempty"`
 		DBRepo             string        `json:"dbRepo,omitempty"`
 		DeleteFailedImages bool          `json:"deleteFailedImages,omitempty"`
+		DB                 DBConfig      `json:"db,omitempty"`
 		Vulnerabilities    VulnConfig    `json:"vulnerabilities,omitempty"`
+		Policy             PolicyConfig  `json:"policy,omitempty"`
+		SBOM               SBOMConfig    `json:"sbom,omitempty"`
+		Reports            ReportConfig  `json:"reports,omitempty"`
 		Timeout            TimeoutConfig `json:"timeout,omitempty"`
+		// Parallelism is how many images are scanned at once. It is derived
+		// from the resources of the scanner container if unset.
+		Parallelism int `json:"parallelism,omitempty"`
+	}
+
+	// DBConfig sets where the vulnerability DB comes from, when it is not
+	// downloaded from DBRepo.
+	DBConfig struct {
+		// Offline never contacts a registry: the DB comes from Bundle, the
+		// shared DB of the manager or the cache directory.
+		Offline bool `json:"offline,omitempty"`
+		// Bundle is the path of a DB bundle, the db.tar.gz archive of the
+		// trivy-db artifact, used instead of downloading the DB.
+		Bundle string `json:"bundle,omitempty"`
+		// BundleDigest is the sha256 digest the bundle must have, if set.
+		BundleDigest string `json:"bundleDigest,omitempty"`
+		// MaxAge rejects a DB last updated longer ago, if set.
+		MaxAge eraserv1alpha1.Duration `json:"maxAge,omitempty"`
 	}
 
 	VulnConfig struct {
@@ -37,6 +59,101 @@ type (
 		Types          []string `json:"types,omitempty"`
 		SecurityChecks []string `json:"securityChecks,omitempty"`
 		Severities     []string `json:"severities,omitempty"`
+		// Thresholds make vulnerabilities count by score, whatever their
+		// severity.
+		Thresholds ThresholdConfig `json:"thresholds,omitempty"`
+		// MinCount is the number of vulnerabilities that count, of a listed
+		// severity or over a threshold, for an image to be non-compliant.
+		MinCount int `json:"minCount,omitempty"`
+		// Ignore lists the accepted vulnerabilities, which do not make an
+		// image non-compliant until they expire.
+		Ignore []IgnoreEntry `json:"ignore,omitempty"`
+	}
+
+	// ThresholdConfig holds the scores over which a vulnerability counts.
+	// Zero values disable the corresponding threshold.
+	ThresholdConfig struct {
+		// CVSSScore is the lowest CVSS base score that counts. The highest
+		// v3 score given by any source is used, or the v2 score without one.
+		CVSSScore float64 `json:"cvssScore,omitempty"`
+		// KnownExploited counts the vulnerabilities in the CISA catalog of
+		// known exploited vulnerabilities, read from KEVFile.
+		KnownExploited bool   `json:"knownExploited,omitempty"`
+		KEVFile        string `json:"kevFile,omitempty"`
+		// EPSSScore is the lowest EPSS probability of exploitation that
+		// counts, read from the scores published by FIRST in EPSSFile.
+		EPSSScore float64 `json:"epssScore,omitempty"`
+		EPSSFile  string  `json:"epssFile,omitempty"`
+	}
+
+	// IgnoreEntry ignores a vulnerability, every vulnerability of a package,
+	// or a vulnerability in a single package.
+	IgnoreEntry struct {
+		ID      string `json:"id,omitempty"`
+		Package string `json:"package,omitempty"`
+		// Expires is the day the entry stops applying. It never does if
+		// empty.
+		Expires Date `json:"expires,omitempty"`
+		// Reason justifies accepting the risk.
+		Reason string `json:"reason"`
+	}
+
+	// PolicyConfig holds rules on the contents of images. An image matching
+	// any rule is non-compliant, whatever its vulnerabilities.
+	PolicyConfig struct {
+		Packages []PackageRule `json:"packages,omitempty"`
+		// Licenses are the banned licenses, as case insensitive patterns
+		// such as GPL-3.0*.
+		Licenses []string `json:"licenses,omitempty"`
+		// Secrets bans images in which trivy finds secrets.
+		Secrets bool `json:"secrets,omitempty"`
+	}
+
+	PackageRule struct {
+		// Name is the package name, or a pattern such as log4j-*.
+		Name string `json:"name"`
+		// Below bans the versions of the package below it. All versions
+		// are banned if it is empty.
+		Below string `json:"below,omitempty"`
+		// Type restricts the rule to the packages of an OS family or
+		// language, e.g. debian or npm, as trivy names them.
+		Type string `json:"type,omitempty"`
+	}
+
+	// SBOMConfig exports the SBOM of each image scanned.
+	SBOMConfig struct {
+		// Dir is where SBOMs are written, one file per image. SBOMs are not
+		// exported if it is empty.
+		Dir    string `json:"dir,omitempty"`
+		Format string `json:"format,omitempty"`
+	}
+
+	// ReportConfig exports the vulnerability report of each image scanned to
+	// the sinks set. Reports are not exported if no sink is set.
+	ReportConfig struct {
+		// Format is json, sarif or cyclonedx. The json report holds the
+		// node and status of the image along with the trivy report.
+		Format string `json:"format,omitempty"`
+		// Dir is where reports are written, in a directory per node.
+		Dir     string          `json:"dir,omitempty"`
+		Webhook WebhookConfig   `json:"webhook,omitempty"`
+		OCI     OCIReportConfig `json:"oci,omitempty"`
+	}
+
+	// WebhookConfig posts each report to URL.
+	WebhookConfig struct {
+		URL string `json:"url,omitempty"`
+		// TokenFile holds a bearer token sent with each report.
+		TokenFile string                  `json:"tokenFile,omitempty"`
+		Timeout   eraserv1alpha1.Duration `json:"timeout,omitempty"`
+	}
+
+	// OCIReportConfig pushes each report as an OCI artifact under
+	// Repository.
+	OCIReportConfig struct {
+		Repository string `json:"repository,omitempty"`
+		// Insecure allows pushing to a registry over plain HTTP.
+		Insecure bool `json:"insecure,omitempty"`
 	}
 
 	TimeoutConfig struct {
@@ -77,6 +194,13 @@ func DefaultConfig() *Config {
 			},
 			SecurityChecks: []string{securityCheckVuln},
 			Severities:     []string{severityCritical},
+			MinCount:       1,
+		},
+		SBOM: SBOMConfig{
+			Format: sbomFormatCycloneDX,
+		},
+		Reports: ReportConfig{
+			Format: reportFormatJSON,
 		},
 		Timeout: TimeoutConfig{
 			Total:    eraserv1alpha1.Duration(time.Hour * 23),
@@ -89,10 +213,22 @@ type ImageScanner struct {
 	trivyScanConfig    scannerSetup
 	imageSourceOptions []fanalImage.Option
 	userConfig         Config
+	vulns              *vulnPolicy
+	ignores            ignoreList
+	margins            *marginStore
+	reports            *reportExporter
 	timer              *time.Timer
 }
 
-var _ Scanner = &ImageScanner{}
+var (
+	_ Scanner       = &ImageScanner{}
+	_ marginScanner = &ImageScanner{}
+	_ exportScanner = &ImageScanner{}
+)
+
+func (s *ImageScanner) Margin(imageID string) (scanMargin, bool) {
+	return s.margins.get(imageID)
+}
 
 func (s *ImageScanner) Timer() *time.Timer {
 	return s.timer
@@ -100,17 +236,22 @@ func (s *ImageScanner) Timer() *time.Timer {
 
 // Function never returns an error.
 func (s *ImageScanner) Scan(img unversioned.Image) (ScanStatus, error) {
+	status, _, err := s.ScanReport(img)
+	return status, err
+}
+
+// ScanReport scans img, exports its report and SBOM, and returns them. The
+// report is nil if the image could not be scanned.
+func (s *ImageScanner) ScanReport(img unversioned.Image) (ScanStatus, *trivyTypes.Report, error) {
 	refs := make([]string, 0, len(img.Names)+len(img.Digests))
 	refs = append(refs, img.Digests...)
 	refs = append(refs, img.Names...)
 
 	perImageTimeout := time.Duration(s.userConfig.Timeout.PerImage)
 
-	scanSucceeded := false
 	log.Info("scanning image with id", "imageID", img.ImageID, "refs", refs)
 
-	for i := 0; i < len(refs) && !scanSucceeded; i++ {
-		ref := refs[i]
+	for _, ref := range refs {
 		log.Info("scanning image with ref", "ref", ref)
 
 		dockerImage, cleanup, err := fanalImage.NewContainerImage(context.Background(), ref, s.trivyScanConfig.dockerOptions, s.imageSourceOptions...)
@@ -134,42 +275,103 @@ func (s *ImageScanner) Scan(img unversioned.Image) (ScanStatus, error) {
 		}
 
 		imageScanContext, cancel := context.WithTimeout(context.Background(), perImageTimeout)
-		defer cancel()
-
 		scanner := scanner.NewScanner(s.trivyScanConfig.localScanner, artifactToScan)
 		report, err := scanner.ScanArtifact(imageScanContext, s.trivyScanConfig.scanOptions)
+		// release the image once scanned, other scans run concurrently
+		cancel()
+		cleanup()
 		if err != nil {
 			log.Error(err, "error scanning image", "imageID", img.ImageID, "reference", ref)
-			cleanup()
 			continue
 		}
 
-		for i := range report.Results {
-			for j := range report.Results[i].Vulnerabilities {
-				if s.userConfig.Vulnerabilities.IgnoreUnfixed && report.Results[i].Vulnerabilities[j].FixedVersion == "" {
-					continue
-				}
+		status := s.evaluate(img, &report)
+		s.Export(img, &report, status)
 
-				if report.Results[i].Vulnerabilities[j].Severity == "" {
-					report.Results[i].Vulnerabilities[j].Severity = severityUnknown
-				}
+		return status, &report, nil
+	}
 
-				if severityMap[report.Results[i].Vulnerabilities[j].Severity] {
-					return StatusNonCompliant, nil
-				}
+	return StatusFailed, nil, nil
+}
+
+// Exports reports whether SBOMs or reports are exported.
+func (s *ImageScanner) Exports() bool {
+	return s.userConfig.SBOM.Dir != "" || s.reports != nil
+}
+
+// Export writes the SBOM of img and sends its report to the sinks. Failures
+// are logged, and do not change the status of the image.
+func (s *ImageScanner) Export(img unversioned.Image, report *trivyTypes.Report, status ScanStatus) {
+	if err := writeSBOM(&s.userConfig.SBOM, img, report); err != nil {
+		log.Error(err, "unable to export SBOM", "imageID", img.ImageID)
+	}
+	if err := s.reports.export(img, report, status); err != nil {
+		log.Error(err, "unable to export report", "imageID", img.ImageID)
+	}
+}
+
+// evaluate returns the status of img from its report, and records its
+// margin.
+func (s *ImageScanner) evaluate(img unversioned.Image, report *trivyTypes.Report) ScanStatus {
+	var ignored []ignoredVulnerability
+	// IDs of the vulnerabilities that count, and of those ignored
+	counted := make(map[string]struct{})
+	ignoredIDs := make(map[string]struct{})
+	var maxCVSS float64
+	for i := range report.Results {
+		for j := range report.Results[i].Vulnerabilities {
+			vuln := &report.Results[i].Vulnerabilities[j]
+			if s.userConfig.Vulnerabilities.IgnoreUnfixed && vuln.FixedVersion == "" {
+				continue
+			}
+
+			if vuln.Severity == "" {
+				vuln.Severity = severityUnknown
+			}
+			if score := cvssScore(vuln); score > maxCVSS {
+				maxCVSS = score
+			}
+
+			reason, ok := s.vulns.qualifies(vuln)
+			if !ok {
+				continue
+			}
+
+			if entry, ok := s.ignores.match(vuln); ok {
+				ignored = append(ignored, ignoredVulnerability{vuln: vuln, entry: entry})
+				ignoredIDs[vuln.VulnerabilityID] = struct{}{}
+				continue
+			}
+
+			counted[vuln.VulnerabilityID] = struct{}{}
+			if len(counted) >= s.vulns.minCount {
+				log.Info("image is vulnerable", "imageID", img.ImageID, "vulnerability", vuln.VulnerabilityID, "package", vuln.PkgName, "reason", reason, "count", len(counted))
+				s.margins.set(img.ImageID, scanMargin{CVSS: maxCVSS})
+				return StatusNonCompliant
 			}
 		}
+	}
 
-		cleanup()
+	if reason, ok := evaluatePolicy(&s.userConfig.Policy, report); ok {
+		log.Info("image breaks content policy", "imageID", img.ImageID, "reason", reason)
+		s.margins.set(img.ImageID, scanMargin{CVSS: maxCVSS})
+		return StatusNonCompliant
+	}
 
-		// causes a break from the loop
-		scanSucceeded = true
+	// the image would be non-compliant without the ignore list
+	for id := range ignoredIDs {
+		counted[id] = struct{}{}
+	}
+	if len(ignored) > 0 && len(counted) >= s.vulns.minCount {
+		reportIgnored(img, ignored)
 	}
 
-	status := StatusOK
-	if !scanSucceeded {
-		status = StatusFailed
+	// ignored vulnerabilities count towards the margin, as they expire
+	short := s.vulns.minCount - len(counted)
+	if short < 0 {
+		short = 0
 	}
+	s.margins.set(img.ImageID, scanMargin{Count: short, CVSS: maxCVSS})
 
-	return status, nil
+	return StatusOK
 }
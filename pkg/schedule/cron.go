@@ -0,0 +1,92 @@
+// Package schedule parses cron expressions and evaluates time windows built
+// from them.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// starBit is set by the cron parser in a field written as "*" or "?".
+const starBit = 1 << 63
+
+var (
+	parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+	// maxDays is the length of each month in a leap year.
+	maxDays = [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+)
+
+// Cron is a parsed five-field cron expression:
+// minute, hour, day of month, month and day of week.
+type Cron struct {
+	spec *cron.SpecSchedule
+}
+
+// Parse parses a standard five-field cron expression such as "0 3 * * SUN",
+// or one of the macros @yearly, @monthly, @weekly, @daily and @hourly.
+// Times are evaluated in loc, or UTC if loc is nil.
+func Parse(expr string, loc *time.Location) (*Cron, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "@") {
+		spec = strings.ToLower(spec)
+	}
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		return nil, fmt.Errorf("invalid cron expression %q: set the time zone with timeZone instead", expr)
+	}
+
+	sched, err := parser.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	s, ok := sched.(*cron.SpecSchedule)
+	if !ok {
+		return nil, fmt.Errorf("invalid cron expression %q: only fixed times are supported", expr)
+	}
+	s.Location = loc
+
+	c := &Cron{spec: s}
+	if !c.matchesSomeDay() {
+		return nil, fmt.Errorf("invalid cron expression %q: no day of the selected months matches", expr)
+	}
+
+	return c, nil
+}
+
+// matchesSomeDay reports whether the expression matches at least one date,
+// so that "0 0 31 FEB *" is rejected. A restricted day of week matches in
+// every month, so only the day of month needs checking.
+func (c *Cron) matchesSomeDay() bool {
+	if c.spec.Dow&starBit == 0 {
+		return true
+	}
+	for m := 1; m <= 12; m++ {
+		if c.spec.Month&(1<<uint(m)) != 0 && c.spec.Dom&(1<<uint(maxDays[m]+1)-1) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the first time matching the expression strictly after t, or
+// the zero time if there is none within the next eight years. Expressions
+// accepted by Parse always match within that time: eight years is the
+// longest gap between two leap days.
+func (c *Cron) Next(t time.Time) time.Time {
+	// the cron package gives up after five years, so search again from
+	// where it stopped
+	for i := 0; i < 2; i++ {
+		if next := c.spec.Next(t); !next.IsZero() {
+			return next
+		}
+		t = time.Date(t.In(c.spec.Location).Year()+6, time.January, 1, 0, 0, 0, 0, c.spec.Location).Add(-time.Second)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, expr string) *Cron {
+	t.Helper()
+	c, err := Parse(expr, time.UTC)
+	if err != nil {
+		t.Fatalf("Parse(%q) returned error: %v", expr, err)
+	}
+	return c
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 7",
+		"*/0 * * * *",
+		"1-5/0 * * * *",
+		"0-60/5 * * * *",
+		"1,2,foo * * * *",
+		"* * * JAN-FOO *",
+		"5-1 * * * *",
+		"* * * * FUNDAY",
+		"0 0 31 FEB *",
+		"0 0 30,31 2 *",
+		"0 0 31 APR,JUN,SEP,NOV *",
+		"@every 1h",
+		"CRON_TZ=Europe/Berlin 0 3 * * *",
+	} {
+		if _, err := Parse(expr, nil); err == nil {
+			t.Errorf("Parse(%q) expected error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	cases := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"0 3 * * SUN", "2023-03-01 12:00", "2023-03-05 03:00"},
+		{"0 3 * * 0", "2023-03-05 03:00", "2023-03-12 03:00"},
+		{"*/15 * * * *", "2023-03-01 12:07", "2023-03-01 12:15"},
+		{"5/20 * * * *", "2023-03-01 12:30", "2023-03-01 12:45"},
+		{"0 0 1,15 * *", "2023-03-02 00:00", "2023-03-15 00:00"},
+		{"30 22 * * MON-FRI", "2023-03-03 23:00", "2023-03-06 22:30"},
+		{"0 0 29 FEB *", "2023-01-01 00:00", "2024-02-29 00:00"},
+		{"0 0 29 FEB *", "2097-01-01 00:00", "2104-02-29 00:00"},
+		{"0 0 31 FEB MON", "2023-01-01 00:00", "2023-02-06 00:00"},
+		{"0 0 13 * FRI", "2023-03-01 00:00", "2023-03-03 00:00"},
+		{"@daily", "2023-12-31 23:59", "2024-01-01 00:00"},
+		{"@DAILY", "2023-03-01 12:00", "2023-03-02 00:00"},
+		{"@hourly", "2023-03-01 12:00", "2023-03-01 13:00"},
+	}
+
+	for _, tc := range cases {
+		got := mustParse(t, tc.expr).Next(date(tc.from))
+		if want := date(tc.want); !got.Equal(want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tc.expr, tc.from, got, want)
+		}
+	}
+}
+
+func TestNextFieldCombinations(t *testing.T) {
+	cases := []struct {
+		expr string
+		from string
+		want []string
+	}{
+		// step over a range
+		{"10-30/10 * * * *", "2023-03-01 12:00", []string{"2023-03-01 12:10", "2023-03-01 12:20", "2023-03-01 12:30", "2023-03-01 13:10"}},
+		// list of a value, a range and a step
+		{"0 1,4-5,*/12 * * *", "2023-03-01 00:30", []string{"2023-03-01 01:00", "2023-03-01 04:00", "2023-03-01 05:00", "2023-03-01 12:00", "2023-03-02 00:00"}},
+		// list of ranges with names
+		{"0 0 1 JAN-FEB,NOV-DEC *", "2023-01-15 00:00", []string{"2023-02-01 00:00", "2023-11-01 00:00", "2023-12-01 00:00", "2024-01-01 00:00"}},
+		// step over a named range of days of week
+		{"0 0 * * MON-FRI/2", "2023-03-01 12:00", []string{"2023-03-03 00:00", "2023-03-06 00:00", "2023-03-08 00:00"}},
+		// step on the day of month is still a restriction: OR with day of week
+		{"0 0 */10 * SUN", "2023-03-01 12:00", []string{"2023-03-05 00:00", "2023-03-11 00:00", "2023-03-12 00:00", "2023-03-19 00:00", "2023-03-21 00:00"}},
+		// both day fields restricted: either one matches
+		{"0 0 1,15 * MON", "2023-05-01 12:00", []string{"2023-05-08 00:00", "2023-05-15 00:00", "2023-05-22 00:00", "2023-05-29 00:00", "2023-06-01 00:00"}},
+		// day of week unrestricted: only the day of month counts
+		{"0 0 1,15 * *", "2023-05-01 12:00", []string{"2023-05-15 00:00", "2023-06-01 00:00"}},
+		// day of month unrestricted: only the day of week counts
+		{"0 0 * * MON", "2023-05-01 12:00", []string{"2023-05-08 00:00", "2023-05-15 00:00"}},
+		// "?" is an unrestricted day of month
+		{"0 0 ? * MON", "2023-05-01 12:00", []string{"2023-05-08 00:00"}},
+		// the month restricts both day fields
+		{"0 0 13 FEB FRI", "2023-01-01 00:00", []string{"2023-02-03 00:00", "2023-02-10 00:00", "2023-02-13 00:00", "2023-02-17 00:00", "2023-02-24 00:00", "2024-02-02 00:00"}},
+	}
+
+	for _, tc := range cases {
+		c := mustParse(t, tc.expr)
+		at := date(tc.from)
+		for _, want := range tc.want {
+			at = c.Next(at)
+			if !at.Equal(date(want)) {
+				t.Errorf("%q: got %s, want %s", tc.expr, at, want)
+				break
+			}
+		}
+	}
+}
+
+func TestNextTimeZone(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	c, err := Parse("0 3 * * *", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := c.Next(date("2023-03-01 00:00"))
+	if want := date("2023-03-01 01:00"); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDefer(t *testing.T) {
+	weekdays := Window{Start: mustParse(t, "0 8 * * MON-FRI"), Duration: 10 * time.Hour}
+	freeze := Window{Start: mustParse(t, "0 18 * * FRI"), Duration: 2 * time.Hour}
+	windows := []Window{weekdays, freeze}
+
+	cases := []struct {
+		at   string
+		want string
+	}{
+		// Wednesday before the window opens
+		{"2023-03-01 07:59", "2023-03-01 07:59"},
+		{"2023-03-01 08:00", "2023-03-01 18:00"},
+		{"2023-03-01 17:59", "2023-03-01 18:00"},
+		{"2023-03-01 18:00", "2023-03-01 18:00"},
+		// Friday: the second window starts as the first one ends
+		{"2023-03-03 12:00", "2023-03-03 20:00"},
+		// Saturday
+		{"2023-03-04 12:00", "2023-03-04 12:00"},
+	}
+
+	for _, tc := range cases {
+		if got, want := Defer(date(tc.at), windows), date(tc.want); !got.Equal(want) {
+			t.Errorf("Defer(%s) = %s, want %s", tc.at, got, want)
+		}
+	}
+}
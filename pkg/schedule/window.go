@@ -0,0 +1,45 @@
+package schedule
+
+import "time"
+
+// maxWindowSkips bounds how many back-to-back windows Defer will skip.
+const maxWindowSkips = 100
+
+// Window is a recurring period that opens at every time matched by Start
+// and stays open for Duration.
+type Window struct {
+	Start    *Cron
+	Duration time.Duration
+}
+
+// End returns when the window containing t closes. The second return value
+// is false if t is outside the window.
+func (w Window) End(t time.Time) (time.Time, bool) {
+	if w.Duration <= 0 {
+		return time.Time{}, false
+	}
+
+	// the earliest opening still in effect at t is the first one after t-Duration
+	start := w.Start.Next(t.Add(-w.Duration))
+	if start.IsZero() || start.After(t) {
+		return time.Time{}, false
+	}
+	return start.Add(w.Duration), true
+}
+
+// Defer returns the first time at or after t that is outside every window.
+func Defer(t time.Time, windows []Window) time.Time {
+	for i := 0; i < maxWindowSkips; i++ {
+		moved := false
+		for _, w := range windows {
+			if end, ok := w.End(t); ok {
+				t = end
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	return t
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Azure/eraser/api/unversioned"
+)
+
+const (
+	// ScanReportLabelKey labels the configmaps written for a cluster scan,
+	// with the name of the collector ImageJob they belong to.
+	ScanReportLabelKey = "eraser.sh/scan-report"
+	// ScanReportDataKey is the configmap key holding a ScanReport.
+	ScanReportDataKey = "report.json"
+	// ScanResultDataKey is the configmap key holding the digests a cluster
+	// scan found non-compliant, as a JSON list.
+	ScanResultDataKey = "images"
+)
+
+// ScanReport lists the images the collector found on a node for a cluster
+// scan.
+type ScanReport struct {
+	Node string `json:"node"`
+	// Platform of the node, such as linux/amd64.
+	Platform string              `json:"platform,omitempty"`
+	Images   []unversioned.Image `json:"images"`
+}
+
+// ClusterScanImage is an image that a cluster scan scans once for all the
+// nodes that have it.
+type ClusterScanImage struct {
+	Digest   string `json:"digest"`
+	Platform string `json:"platform,omitempty"`
+	// Refs are the repository@digest references the image can be fetched by.
+	Refs []string `json:"refs"`
+	// Nodes is the number of nodes that have the image.
+	Nodes int `json:"nodes"`
+}
+
+// ScanResultName is the name of the configmap holding the result of the
+// cluster scan for the collector ImageJob job.
+func ScanResultName(job string) string {
+	return job + "-scan-result"
+}
+
+// DedupeScanReports returns one ClusterScanImage per digest and platform in
+// reports. Images without a digest, such as images built on the node, cannot
+// be fetched from a registry and are left out.
+func DedupeScanReports(reports []ScanReport) []ClusterScanImage {
+	byKey := make(map[string]*ClusterScanImage)
+	refs := make(map[string]map[string]struct{})
+
+	for _, report := range reports {
+		seen := make(map[string]struct{})
+		for _, img := range report.Images {
+			for _, digest := range img.Digests {
+				key := digest + " " + report.Platform
+
+				csi, ok := byKey[key]
+				if !ok {
+					csi = &ClusterScanImage{Digest: digest, Platform: report.Platform}
+					byKey[key] = csi
+					refs[key] = make(map[string]struct{})
+				}
+				if _, ok := seen[key]; !ok {
+					seen[key] = struct{}{}
+					csi.Nodes++
+				}
+
+				for _, name := range img.Names {
+					refs[key][Repository(name)+"@"+digest] = struct{}{}
+				}
+			}
+		}
+	}
+
+	images := make([]ClusterScanImage, 0, len(byKey))
+	for key, csi := range byKey {
+		if len(refs[key]) == 0 {
+			continue
+		}
+		for ref := range refs[key] {
+			csi.Refs = append(csi.Refs, ref)
+		}
+		sort.Strings(csi.Refs)
+		images = append(images, *csi)
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Digest != images[j].Digest {
+			return images[i].Digest < images[j].Digest
+		}
+		return images[i].Platform < images[j].Platform
+	})
+
+	return images
+}
+
+// Repository returns the repository of an image name, without its tag or
+// digest.
+func Repository(name string) string {
+	name, _, _ = strings.Cut(name, "@")
+	i := strings.LastIndex(name, ":")
+	if i < 0 || strings.Contains(name[i:], "/") {
+		return name
+	}
+	return name[:i]
+}
@@ -5,30 +5,30 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"net/url"
 	"os"
 	"strings"
 	"time"
 
-	"golang.org/x/sys/unix"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
 
 	"github.com/Azure/eraser/api/unversioned"
+	"github.com/Azure/eraser/pkg/pipeline"
 )
 
 const (
 	// unixProtocol is the network protocol of unix socket.
-	unixProtocol             = "unix"
-	PipeMode                 = 0o644
-	ScanErasePath            = "/run/eraser.sh/shared-data/scanErase"
-	CollectScanPath          = "/run/eraser.sh/shared-data/collectScan"
-	EraseCompleteCollectPath = "/run/eraser.sh/shared-data/eraseCompleteCollect"
-	EraseCompleteMessage     = "complete"
-	EraseCompleteScanPath    = "/run/eraser.sh/shared-data/eraseCompleteScan"
+	unixProtocol = "unix"
+	PipeMode     = 0o644
+
+	// TerminationLogPath is where the eraser reports its NodeResult. The
+	// kubelet copies the file into the container's terminated state.
+	TerminationLogPath = "/dev/termination-log"
+	// MaxTerminationMessageSize is the largest message the kubelet will keep.
+	MaxTerminationMessageSize = 4096
 
 	RuntimeDocker     = "docker"
 	RuntimeContainerd = "containerd"
@@ -37,13 +37,45 @@ const (
 	ContainerdPath    = "/run/containerd/containerd.sock"
 	CrioPath          = "/run/crio/crio.sock"
 
+	// EraserStateDir is the directory on each node where the eraser keeps
+	// what it records across runs.
+	EraserStateDir = "/var/lib/eraser"
+
 	EnvEraserContainerRuntime = "ERASER_CONTAINER_RUNTIME"
+	// EnvImageFsCapacity and EnvImageFsAvailable hold the capacity and the
+	// available bytes of the filesystem holding the images of the node, which
+	// disk pressure watermarks are relative to.
+	EnvImageFsCapacity  = "IMAGEFS_CAPACITY"
+	EnvImageFsAvailable = "IMAGEFS_AVAILABLE"
+	// EnvNodeLabels holds the labels of the node as a JSON object, for image
+	// policies that refer to node.labels.
+	EnvNodeLabels = "NODE_LABELS"
+	// ImageExclusionsDir is where the ImageExclusion rules that apply to the
+	// node are mounted from a configmap, as a JSON list of ExclusionRule in
+	// ImageExclusionsFile. A configmap has no limit on the number of rules,
+	// unlike the environment of a container.
+	ImageExclusionsDir  = "/run/eraser.sh/image-exclusions"
+	ImageExclusionsFile = "rules.json"
+	// ReleasedImagesDir is where the quarantined images that may be removed
+	// are mounted from a configmap, as a JSON list of IDs in
+	// ReleasedImagesFile.
+	ReleasedImagesDir  = "/run/eraser.sh/released"
+	ReleasedImagesFile = "images.json"
+	// PullSecretsDir is where the image pull secrets of the manager are
+	// mounted in scanner containers, one directory per secret.
+	PullSecretsDir = "/run/eraser.sh/pull-secrets"
 )
 
 type ExclusionList struct {
 	Excluded []string `json:"excluded"`
 }
 
+// ExclusionRule is an ImageExclusion as passed to the collector and eraser.
+type ExclusionRule struct {
+	Name   string   `json:"name"`
+	Images []string `json:"images"`
+}
+
 var (
 	ErrProtocolNotSupported  = errors.New("protocol not supported")
 	ErrEndpointDeprecated    = errors.New("endpoint is deprecated, please consider using full url format")
@@ -178,6 +210,55 @@ func GetNonRunningImages(runningImages map[string]string, allImages []unversione
 	return nonRunningImages
 }
 
+// GetImageLastUsed maps each image ID to the creation time of the newest
+// container on the node that uses it.
+func GetImageLastUsed(containers []*v1.Container) map[string]time.Time {
+	lastUsed := make(map[string]time.Time)
+	for _, container := range containers {
+		imageID := container.Image.GetImage()
+		created := time.Unix(0, container.CreatedAt)
+		if created.After(lastUsed[imageID]) {
+			lastUsed[imageID] = created
+		}
+	}
+	return lastUsed
+}
+
+// ImageCreatedAt returns the creation time found in a verbose ImageStatus
+// response. containerd and CRI-O both report it in the image spec.
+func ImageCreatedAt(resp *v1.ImageStatusResponse) (time.Time, bool) {
+	var info struct {
+		ImageSpec struct {
+			Created *time.Time `json:"created"`
+		} `json:"imageSpec"`
+	}
+
+	if resp == nil || resp.Info["info"] == "" {
+		return time.Time{}, false
+	}
+
+	if err := json.Unmarshal([]byte(resp.Info["info"]), &info); err != nil || info.ImageSpec.Created == nil {
+		return time.Time{}, false
+	}
+
+	return *info.ImageSpec.Created, true
+}
+
+// GetNonRunningImagesBefore is the time-aware variant of GetNonRunningImages.
+// It only returns images whose entry in times, keyed by image ID, is before
+// cutoff. Images without an entry are left out.
+func GetNonRunningImagesBefore(runningImages map[string]string, allImages []unversioned.Image, idToImageMap map[string]unversioned.Image, times map[string]time.Time, cutoff time.Time) map[string]string {
+	nonRunningImages := GetNonRunningImages(runningImages, allImages, idToImageMap)
+
+	for key, imageID := range nonRunningImages {
+		if t, ok := times[imageID]; !ok || !t.Before(cutoff) {
+			delete(nonRunningImages, key)
+		}
+	}
+
+	return nonRunningImages
+}
+
 func IsExcluded(excluded map[string]struct{}, img string, idToImageMap map[string]unversioned.Image) bool {
 	if len(excluded) == 0 {
 		return false
@@ -294,6 +375,113 @@ func ParseExcluded() (map[string]struct{}, error) {
 	return excludedMap, nil
 }
 
+// ParseExclusionRules returns the ImageExclusion rules in the file at path.
+// There are none if the file does not exist.
+func ParseExclusionRules(path string) ([]ExclusionRule, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []ExclusionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid ImageExclusion rules in %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseReleasedImages returns the IDs of the quarantined images in the file
+// at path. None are released if the file does not exist.
+func ParseReleasedImages(path string) (map[string]struct{}, error) {
+	released := make(map[string]struct{})
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return released, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("invalid released images in %s: %w", path, err)
+	}
+	for _, id := range ids {
+		released[id] = struct{}{}
+	}
+	return released, nil
+}
+
+// ExclusionTracker counts the images each ImageExclusion rule protects.
+type ExclusionTracker struct {
+	rules []exclusionSet
+	// images protected by each rule, so an image is only counted once
+	hits map[string]map[string]struct{}
+}
+
+type exclusionSet struct {
+	name     string
+	excluded map[string]struct{}
+}
+
+// NewExclusionTracker returns a tracker for rules.
+func NewExclusionTracker(rules []ExclusionRule) *ExclusionTracker {
+	t := &ExclusionTracker{hits: make(map[string]map[string]struct{})}
+	for _, r := range rules {
+		set := exclusionSet{name: r.Name, excluded: make(map[string]struct{}, len(r.Images))}
+		for _, img := range r.Images {
+			set.excluded[img] = struct{}{}
+		}
+		t.rules = append(t.rules, set)
+	}
+	return t
+}
+
+// Merge adds the images of every rule to excluded, which may be nil.
+func (t *ExclusionTracker) Merge(excluded map[string]struct{}) map[string]struct{} {
+	if excluded == nil {
+		excluded = make(map[string]struct{})
+	}
+	for _, r := range t.rules {
+		for img := range r.excluded {
+			excluded[img] = struct{}{}
+		}
+	}
+	return excluded
+}
+
+// Record counts img against every rule that excludes it.
+func (t *ExclusionTracker) Record(img string, idToImageMap map[string]unversioned.Image) {
+	if t == nil {
+		return
+	}
+	for _, r := range t.rules {
+		if !IsExcluded(r.excluded, img, idToImageMap) {
+			continue
+		}
+		if t.hits[r.name] == nil {
+			t.hits[r.name] = make(map[string]struct{})
+		}
+		t.hits[r.name][img] = struct{}{}
+	}
+}
+
+// Counts returns the number of images each rule protected, by rule name.
+// Rules that protected nothing are reported with a count of zero.
+func (t *ExclusionTracker) Counts() map[string]int64 {
+	if t == nil || len(t.rules) == 0 {
+		return nil
+	}
+	counts := make(map[string]int64, len(t.rules))
+	for _, r := range t.rules {
+		counts[r.name] = int64(len(t.hits[r.name]))
+	}
+	return counts
+}
+
 func BoolPtr(b bool) *bool {
 	return &b
 }
@@ -332,68 +520,111 @@ func readConfigMap(path string) ([]string, error) {
 	return images, nil
 }
 
-func ReadCollectScanPipe(ctx context.Context) ([]unversioned.Image, error) {
-	timer := time.NewTimer(time.Second)
-	if !timer.Stop() {
-		<-timer.C
-	}
-	defer timer.Stop()
-
-	var f *os.File
-	for {
-		var err error
-
-		f, err = os.OpenFile(CollectScanPath, os.O_RDONLY, 0)
-		if err == nil {
-			break
-		}
-		if !os.IsNotExist(err) {
-			return nil, err
-		}
-
-		timer.Reset(time.Second)
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-timer.C:
-			continue
-		}
-	}
+// Named pipes the containers of ImageJob pods used to talk through.
+//
+// Deprecated: the containers talk through the pipeline service of
+// pkg/pipeline instead. These paths are unused, and will be removed in the
+// next release.
+const (
+	ScanErasePath            = "/run/eraser.sh/shared-data/scanErase"
+	CollectScanPath          = "/run/eraser.sh/shared-data/collectScan"
+	EraseCompleteCollectPath = "/run/eraser.sh/shared-data/eraseCompleteCollect"
+	EraseCompleteMessage     = "complete"
+	EraseCompleteScanPath    = "/run/eraser.sh/shared-data/eraseCompleteScan"
+)
 
-	// json data is list of []eraserv1.Image
-	data, err := io.ReadAll(f)
+// ReadCollectScanPipe returns the images collected on the node, for the
+// scanner named by the environment of its container.
+//
+// Deprecated: use ReceiveImages of the ImageProvider in pkg/scanners/template.
+// This wrapper reads from the pipeline service, and will be removed in the
+// next release.
+func ReadCollectScanPipe(ctx context.Context) ([]unversioned.Image, error) {
+	client, err := pipeline.DialScanner(ctx, pipeline.SocketPath, os.Getenv(pipeline.ScannerNameEnv))
 	if err != nil {
 		return nil, err
 	}
+	defer client.Close()
 
-	allImages := []unversioned.Image{}
-	if err = json.Unmarshal(data, &allImages); err != nil {
-		return nil, err
-	}
-
-	return allImages, nil
+	return client.Images(ctx)
 }
 
+// WriteScanErasePipe sends the images found non-compliant to the eraser, and
+// marks the scan as complete.
+//
+// Deprecated: use SendImages and Finish of the ImageProvider in
+// pkg/scanners/template. This wrapper writes to the pipeline service, and
+// will be removed in the next release.
 func WriteScanErasePipe(vulnerableImages []unversioned.Image) error {
-	data, err := json.Marshal(vulnerableImages)
+	ctx := context.Background()
+	client, err := pipeline.DialScanner(ctx, pipeline.SocketPath, os.Getenv(pipeline.ScannerNameEnv))
 	if err != nil {
 		return err
 	}
+	defer client.Close()
 
-	if err = unix.Mkfifo(ScanErasePath, PipeMode); err != nil {
+	publisher, err := client.PublishVerdicts(ctx)
+	if err != nil {
 		return err
 	}
 
-	file, err := os.OpenFile(ScanErasePath, os.O_WRONLY, 0)
-	if err != nil {
+	verdicts := make([]pipeline.Verdict, 0, len(vulnerableImages))
+	for _, img := range vulnerableImages {
+		verdicts = append(verdicts, pipeline.Verdict{Image: img, Status: pipeline.StatusNonCompliant})
+	}
+	if err := publisher.Send(verdicts...); err != nil {
 		return err
 	}
 
-	if _, err := file.Write(data); err != nil {
+	return publisher.Close()
+}
+
+// WriteNodeResult writes result as JSON to path. Lists are shortened until the
+// message fits in MaxTerminationMessageSize, in which case Truncated is set.
+func WriteNodeResult(path string, result *unversioned.NodeResult) error {
+	data, err := EncodeNodeResult(result, MaxTerminationMessageSize)
+	if err != nil {
 		return err
 	}
 
-	return file.Close()
+	return os.WriteFile(path, data, PipeMode)
+}
+
+// EncodeNodeResult marshals result, dropping entries from its longest list
+// until the encoding is at most limit bytes. Quarantined images are dropped
+// last, since the manager keeps track of them.
+func EncodeNodeResult(result *unversioned.NodeResult, limit int) ([]byte, error) {
+	r := *result
+	for {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) <= limit {
+			return data, nil
+		}
+
+		longest := &r.Removed
+		for _, l := range []*[]string{&r.Running, &r.Excluded, &r.Retained, &r.NotPresent} {
+			if len(*l) > len(*longest) {
+				longest = l
+			}
+		}
+
+		switch {
+		case len(r.Errors) > len(*longest):
+			r.Errors = r.Errors[:len(r.Errors)-1]
+		case len(*longest) > 0:
+			*longest = (*longest)[:len(*longest)-1]
+		case len(r.Quarantined) > 0:
+			r.Quarantined = r.Quarantined[:len(r.Quarantined)-1]
+		default:
+			return nil, fmt.Errorf("node result does not fit in %d bytes", limit)
+		}
+
+		r.Truncated = true
+	}
 }
 
 func ProcessRepoDigests(repoDigests []string) ([]string, []error) {
@@ -1,10 +1,20 @@
 package utils
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	v1 "k8s.io/cri-api/pkg/apis/runtime/v1"
+
+	"github.com/Azure/eraser/api/unversioned"
 )
 
 func TestParseEndpointWithFallBackProtocol(t *testing.T) {
@@ -163,3 +173,263 @@ func TestGetAddressAndDialer(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeNodeResult(t *testing.T) {
+	removed := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		removed = append(removed, fmt.Sprintf("docker.io/library/image%d:latest", i))
+	}
+
+	testCases := []struct {
+		name      string
+		result    unversioned.NodeResult
+		truncated bool
+	}{
+		{
+			name:   "fits",
+			result: unversioned.NodeResult{Node: "node1", Removed: []string{"image1"}, Running: []string{"image2"}},
+		},
+		{
+			name:      "too large",
+			result:    unversioned.NodeResult{Node: "node1", Removed: removed, Excluded: []string{"image2"}},
+			truncated: true,
+		},
+		{
+			name:      "quarantined kept",
+			result:    unversioned.NodeResult{Node: "node1", Removed: removed, Quarantined: removed[:20]},
+			truncated: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		data, err := EncodeNodeResult(&tc.result, MaxTerminationMessageSize)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		if len(data) > MaxTerminationMessageSize {
+			t.Errorf("%s: encoded result is %d bytes", tc.name, len(data))
+		}
+
+		if got := strings.Contains(string(data), `"truncated":true`); got != tc.truncated {
+			t.Errorf("%s: expected truncated=%v, got %s", tc.name, tc.truncated, data)
+		}
+
+		if !strings.Contains(string(data), `"excluded"`) && len(tc.result.Excluded) > 0 {
+			t.Errorf("%s: shorter lists should be kept: %s", tc.name, data)
+		}
+
+		var decoded unversioned.NodeResult
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(decoded.Quarantined) != len(tc.result.Quarantined) {
+			t.Errorf("%s: expected %d quarantined images, got %d", tc.name, len(tc.result.Quarantined), len(decoded.Quarantined))
+		}
+	}
+}
+
+func TestImageCreatedAt(t *testing.T) {
+	created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testCases := []struct {
+		name string
+		resp *v1.ImageStatusResponse
+		ok   bool
+	}{
+		{name: "nil response"},
+		{name: "no info", resp: &v1.ImageStatusResponse{}},
+		{name: "invalid info", resp: &v1.ImageStatusResponse{Info: map[string]string{"info": "{"}}},
+		{name: "created", resp: &v1.ImageStatusResponse{Info: map[string]string{"info": `{"imageSpec":{"created":"2023-01-02T03:04:05Z"}}`}}, ok: true},
+	}
+
+	for _, tc := range testCases {
+		got, ok := ImageCreatedAt(tc.resp)
+		if ok != tc.ok {
+			t.Errorf("%s: expected ok=%v, got %v", tc.name, tc.ok, ok)
+		}
+		if ok && !got.Equal(created) {
+			t.Errorf("%s: expected %v, got %v", tc.name, created, got)
+		}
+	}
+}
+
+func TestExclusionTracker(t *testing.T) {
+	idToImageMap := map[string]unversioned.Image{
+		"sha256:a": {ImageID: "sha256:a", Names: []string{"docker.io/library/nginx:latest"}},
+		"sha256:b": {ImageID: "sha256:b", Names: []string{"docker.io/library/redis:7"}},
+		"sha256:c": {ImageID: "sha256:c", Names: []string{"ghcr.io/azure/test:latest"}},
+	}
+
+	tracker := NewExclusionTracker([]ExclusionRule{
+		{Name: "library", Images: []string{"docker.io/library/*"}},
+		{Name: "redis", Images: []string{"docker.io/library/redis:7"}},
+		{Name: "unused", Images: []string{"quay.io/*"}},
+	})
+
+	excluded := tracker.Merge(map[string]struct{}{"ghcr.io/azure/test:latest": {}})
+	if len(excluded) != 4 {
+		t.Errorf("expected 4 excluded images, got %v", excluded)
+	}
+
+	for _, img := range []string{"sha256:a", "sha256:b", "sha256:b", "sha256:c"} {
+		tracker.Record(img, idToImageMap)
+	}
+
+	want := map[string]int64{"library": 2, "redis": 1, "unused": 0}
+	got := tracker.Counts()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for name, n := range want {
+		if got[name] != n {
+			t.Errorf("%s: expected %d, got %d", name, n, got[name])
+		}
+	}
+
+	var empty *ExclusionTracker
+	empty.Record("sha256:a", idToImageMap)
+	if counts := empty.Counts(); counts != nil {
+		t.Errorf("expected no counts from a nil tracker, got %v", counts)
+	}
+}
+
+func TestParseExclusionRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	testCases := []struct {
+		name  string
+		path  string
+		rules []ExclusionRule
+		err   bool
+	}{
+		{
+			name:  "rules",
+			path:  write("rules.json", `[{"name":"library","images":["docker.io/library/*"]}]`),
+			rules: []ExclusionRule{{Name: "library", Images: []string{"docker.io/library/*"}}},
+		},
+		{
+			name: "no file",
+			path: filepath.Join(dir, "missing.json"),
+		},
+		{
+			name: "invalid",
+			path: write("invalid.json", `{`),
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := ParseExclusionRules(tc.path)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", rules)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rules, tc.rules) {
+				t.Errorf("expected %v, got %v", tc.rules, rules)
+			}
+		})
+	}
+}
+
+func TestParseReleasedImages(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	testCases := []struct {
+		name     string
+		path     string
+		released map[string]struct{}
+		err      bool
+	}{
+		{
+			name:     "images",
+			path:     write("images.json", `["sha256:a","sha256:b"]`),
+			released: map[string]struct{}{"sha256:a": {}, "sha256:b": {}},
+		},
+		{
+			name:     "no file",
+			path:     filepath.Join(dir, "missing.json"),
+			released: map[string]struct{}{},
+		},
+		{
+			name: "invalid",
+			path: write("invalid.json", `{`),
+			err:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			released, err := ParseReleasedImages(tc.path)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", released)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(released, tc.released) {
+				t.Errorf("expected %v, got %v", tc.released, released)
+			}
+		})
+	}
+}
+
+func TestDedupeScanReports(t *testing.T) {
+	nginx := unversioned.Image{
+		ImageID: "sha256:1",
+		Names:   []string{"docker.io/library/nginx:1.23"},
+		Digests: []string{"sha256:a"},
+	}
+	nginxLatest := unversioned.Image{
+		ImageID: "sha256:1",
+		Names:   []string{"docker.io/library/nginx:latest", "localhost:5000/nginx:1.23"},
+		Digests: []string{"sha256:a"},
+	}
+	local := unversioned.Image{ImageID: "sha256:2", Names: []string{"app:dev"}}
+
+	images := DedupeScanReports([]ScanReport{
+		{Node: "node1", Platform: "linux/amd64", Images: []unversioned.Image{nginx, local}},
+		{Node: "node2", Platform: "linux/amd64", Images: []unversioned.Image{nginxLatest}},
+		{Node: "node3", Platform: "linux/arm64", Images: []unversioned.Image{nginx}},
+	})
+
+	if len(images) != 2 {
+		t.Fatalf("expected 2 images, got %+v", images)
+	}
+
+	amd64 := images[0]
+	if amd64.Platform != "linux/amd64" || amd64.Nodes != 2 {
+		t.Errorf("expected linux/amd64 on 2 nodes, got %+v", amd64)
+	}
+	wantRefs := []string{"docker.io/library/nginx@sha256:a", "localhost:5000/nginx@sha256:a"}
+	if strings.Join(amd64.Refs, ",") != strings.Join(wantRefs, ",") {
+		t.Errorf("expected refs %v, got %v", wantRefs, amd64.Refs)
+	}
+
+	if arm64 := images[1]; arm64.Platform != "linux/arm64" || arm64.Nodes != 1 {
+		t.Errorf("expected linux/arm64 on 1 node, got %+v", arm64)
+	}
+}